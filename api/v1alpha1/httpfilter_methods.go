@@ -2,12 +2,22 @@ package v1alpha1
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 
 	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"github.com/kaasops/envoy-xds-controller/internal/protoutil"
 )
 
+// AnnotationHTTPFilterECDS enables delivery of the HttpFilter over Extension Config Discovery,
+// the HttpConnectionManager references the filter via config_discovery instead of inlining its config.
+const AnnotationHTTPFilterECDS = "envoy.kaasops.io/ecds"
+
+// IsECDS returns true if the HttpFilter is delivered over ECDS.
+func (h *HttpFilter) IsECDS() bool {
+	return h.GetAnnotations()[AnnotationHTTPFilterECDS] == "true"
+}
+
 func (h *HttpFilter) UnmarshalV3() ([]*hcmv3.HttpFilter, error) {
 	return h.unmarshalV3()
 }
@@ -21,6 +31,9 @@ func (h *HttpFilter) UnmarshalV3AndValidate() ([]*hcmv3.HttpFilter, error) {
 		if err := httpFilter.ValidateAll(); err != nil {
 			return nil, err
 		}
+		if h.IsECDS() && httpFilter.GetTypedConfig() == nil {
+			return nil, fmt.Errorf("http filter %s has no typed_config, it can't be delivered over ECDS", httpFilter.Name)
+		}
 	}
 	return httpFilters, nil
 }
@@ -48,6 +61,9 @@ func (h *HttpFilter) IsEqual(other *HttpFilter) bool {
 	if h == nil || other == nil {
 		return false
 	}
+	if h.IsECDS() != other.IsECDS() {
+		return false
+	}
 	if len(h.Spec) != len(other.Spec) {
 		return false
 	}
@@ -7,6 +7,15 @@ import (
 	"github.com/kaasops/envoy-xds-controller/internal/protoutil"
 )
 
+// AnnotationListenerVHDS enables delivery of virtual hosts of the listener VirtualServices over VHDS,
+// Envoy fetches only the virtual hosts of domains it receives requests for. VHDS requires delta ADS.
+const AnnotationListenerVHDS = "envoy.kaasops.io/vhds"
+
+// IsVHDS returns true if virtual hosts of the listener are delivered over VHDS.
+func (l *Listener) IsVHDS() bool {
+	return l.GetAnnotations()[AnnotationListenerVHDS] == "true"
+}
+
 func (l *Listener) UnmarshalV3() (*listenerv3.Listener, error) {
 	return l.unmarshalV3()
 }
@@ -40,5 +49,8 @@ func (l *Listener) IsEqual(other *Listener) bool {
 	if l == nil || other == nil || l.Spec == nil || other.Spec == nil || l.Spec.Raw == nil || other.Spec.Raw == nil {
 		return false
 	}
+	if l.IsVHDS() != other.IsVHDS() {
+		return false
+	}
 	return bytes.Equal(l.Spec.Raw, other.Spec.Raw)
 }
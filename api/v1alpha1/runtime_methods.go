@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func (r *Runtime) GetNodeIDs() []string {
+	return parseNodeIDs(r.GetAnnotations()[AnnotationKeyEnvoyKaaSopsIoNodeID])
+}
+
+// UnmarshalValues returns values of the runtime layer, values must be a JSON object.
+func (r *Runtime) UnmarshalValues() (*structpb.Struct, error) {
+	values := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	if r.Spec.Values == nil || len(r.Spec.Values.Raw) == 0 {
+		return values, nil
+	}
+	if err := values.UnmarshalJSON(r.Spec.Values.Raw); err != nil {
+		return nil, fmt.Errorf("runtime values must be a JSON object: %w", err)
+	}
+	return values, nil
+}
+
+func (r *Runtime) Validate() error {
+	if r.Spec.Layer == "" {
+		return errors.New("runtime layer is empty")
+	}
+	if len(r.GetNodeIDs()) == 0 {
+		return fmt.Errorf("runtime has no node IDs, set annotation %s", AnnotationKeyEnvoyKaaSopsIoNodeID)
+	}
+	_, err := r.UnmarshalValues()
+	return err
+}
+
+func (r *Runtime) IsEqual(other *Runtime) bool {
+	if r == nil && other == nil {
+		return true
+	}
+	if r == nil || other == nil {
+		return false
+	}
+	if r.Spec.Layer != other.Spec.Layer {
+		return false
+	}
+	if r.GetAnnotations()[AnnotationKeyEnvoyKaaSopsIoNodeID] != other.GetAnnotations()[AnnotationKeyEnvoyKaaSopsIoNodeID] {
+		return false
+	}
+	if r.Spec.Values == nil && other.Spec.Values == nil {
+		return true
+	}
+	if r.Spec.Values == nil || other.Spec.Values == nil {
+		return false
+	}
+	return bytes.Equal(r.Spec.Values.Raw, other.Spec.Values.Raw)
+}
@@ -0,0 +1,68 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRuntimeValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		layer       string
+		values      string
+		expectedErr bool
+	}{
+		{
+			name:        "valid",
+			annotations: map[string]string{AnnotationKeyEnvoyKaaSopsIoNodeID: "node1, node2"},
+			layer:       "rtds",
+			values:      `{"feature.enabled": true}`,
+		},
+		{
+			name:        "without values",
+			annotations: map[string]string{AnnotationKeyEnvoyKaaSopsIoNodeID: "*"},
+			layer:       "rtds",
+		},
+		{
+			name:        "without layer",
+			annotations: map[string]string{AnnotationKeyEnvoyKaaSopsIoNodeID: "*"},
+			values:      `{"feature.enabled": true}`,
+			expectedErr: true,
+		},
+		{
+			name:        "without node IDs",
+			layer:       "rtds",
+			values:      `{"feature.enabled": true}`,
+			expectedErr: true,
+		},
+		{
+			name:        "values are not an object",
+			annotations: map[string]string{AnnotationKeyEnvoyKaaSopsIoNodeID: "*"},
+			layer:       "rtds",
+			values:      `[1, 2]`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &Runtime{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tc.annotations},
+				Spec:       RuntimeSpec{Layer: tc.layer},
+			}
+			if tc.values != "" {
+				rt.Spec.Values = &runtime.RawExtension{Raw: []byte(tc.values)}
+			}
+			err := rt.Validate()
+			if tc.expectedErr && err == nil {
+				t.Errorf("expected error")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
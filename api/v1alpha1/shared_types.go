@@ -2,6 +2,21 @@ package v1alpha1
 
 const AnnotationSecretDomains = "envoy.kaasops.io/domains"
 
+// AnnotationSecretKind overrides the kind of Envoy secret built from a Kubernetes Secret.
+// If not set, the kind is derived from the Secret type.
+const AnnotationSecretKind = "envoy.kaasops.io/secret-kind"
+
+// AnnotationSkipDependentValidation set to "true" on a Listener, Route, HttpFilter, Policy or AccessLogConfig
+// allows its update even if virtual services using it can't be built anymore.
+const AnnotationSkipDependentValidation = "envoy.kaasops.io/skip-dependent-validation"
+
+const (
+	SecretKindTLSCertificate    = "tls-certificate"
+	SecretKindValidationContext = "validation-context"
+	SecretKindSessionTicketKeys = "session-ticket-keys"
+	SecretKindGeneric           = "generic"
+)
+
 type Message string
 
 type ResourceRef struct {
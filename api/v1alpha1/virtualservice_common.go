@@ -33,6 +33,10 @@ type TlsConfig struct {
 
 	// Find secret with domain in annotation "envoy.kaasops.io/domains"
 	AutoDiscovery *bool `json:"autoDiscovery,omitempty"`
+
+	// ValidationContextRef - secret with CA to verify client certificates (mTLS), client certificates are required if set.
+	// The secret kind must be validation-context or tls-certificate with ca.crt
+	ValidationContextRef *ResourceRef `json:"validationContextRef,omitempty"`
 }
 
 type VirtualServiceRBACSpec struct {
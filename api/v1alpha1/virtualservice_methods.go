@@ -14,8 +14,10 @@ const (
 )
 
 func (vs *VirtualService) GetNodeIDs() []string {
-	annotations := vs.GetAnnotations()
-	nodeIDsAnnotation := annotations[AnnotationKeyEnvoyKaaSopsIoNodeID]
+	return parseNodeIDs(vs.GetAnnotations()[AnnotationKeyEnvoyKaaSopsIoNodeID])
+}
+
+func parseNodeIDs(nodeIDsAnnotation string) []string {
 	if nodeIDsAnnotation == "" {
 		return nil
 	}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kaasops/envoy-xds-controller/internal/xds/bootstrap"
+	"github.com/kelseyhightower/envconfig"
+)
+
+const bootstrapCommand = "bootstrap"
+
+// bootstrapOptions returns the base options of generated Envoy bootstraps,
+// nil if the advertise address of the xDS server isn't configured.
+func bootstrapOptions(cfg *Config) *bootstrap.Options {
+	if cfg.XDS.AdvertiseAddress == "" {
+		return nil
+	}
+	opts := &bootstrap.Options{
+		XDSAddress:        cfg.XDS.AdvertiseAddress,
+		XDSPort:           uint32(cfg.XDS.Port),
+		KeepaliveInterval: cfg.XDS.KeepaliveTime,
+		KeepaliveTimeout:  cfg.XDS.KeepaliveTimeout,
+	}
+	if cfg.XDS.TLSSecretName != "" {
+		opts.TLS = &bootstrap.TLSOptions{CAFile: cfg.Bootstrap.CAFile}
+		if cfg.XDS.RequireClientCert {
+			opts.TLS.CertFile = cfg.Bootstrap.CertFile
+			opts.TLS.KeyFile = cfg.Bootstrap.KeyFile
+		}
+	}
+	return opts
+}
+
+// runBootstrapCommand prints the Envoy bootstrap for a node ID to stdout.
+// Defaults are taken from the controller configuration, so the command can be run inside the controller pod.
+func runBootstrapCommand(args []string) error {
+	var cfg Config
+	if err := envconfig.Process("APP", &cfg); err != nil {
+		return fmt.Errorf("unable to process env var: %w", err)
+	}
+	opts := bootstrapOptions(&cfg)
+	if opts == nil {
+		opts = &bootstrap.Options{XDSPort: uint32(cfg.XDS.Port)}
+	}
+
+	var (
+		format  string
+		tlsMode bool
+		tlsOpts bootstrap.TLSOptions
+		xdsPort uint
+	)
+	if opts.TLS != nil {
+		tlsMode = true
+		tlsOpts = *opts.TLS
+	}
+
+	fs := flag.NewFlagSet(bootstrapCommand, flag.ContinueOnError)
+	fs.StringVar(&opts.NodeID, "node-id", "", "Envoy node ID (required)")
+	fs.StringVar(&opts.NodeCluster, "node-cluster", "", "Envoy node cluster")
+	fs.StringVar(&opts.XDSAddress, "xds-address", opts.XDSAddress, "Address of the xDS server reachable from Envoy")
+	fs.UintVar(&xdsPort, "xds-port", uint(opts.XDSPort), "Port of the xDS server")
+	fs.BoolVar(&tlsMode, "tls", tlsMode, "Connect to the xDS server over TLS")
+	fs.StringVar(&tlsOpts.CAFile, "tls-ca-file", tlsOpts.CAFile, "CA file used to verify the xDS server")
+	fs.StringVar(&tlsOpts.CertFile, "tls-cert-file", tlsOpts.CertFile, "Client certificate file for mTLS")
+	fs.StringVar(&tlsOpts.KeyFile, "tls-key-file", tlsOpts.KeyFile, "Client key file for mTLS")
+	fs.StringVar(&tlsOpts.SNI, "tls-sni", "", "SNI of the xDS server, the xDS address is used if empty")
+	fs.Func("admin-port", "Port of the Envoy admin interface on localhost, disabled if not set", func(s string) error {
+		_, err := fmt.Sscan(s, &opts.AdminPort)
+		return err
+	})
+	fs.StringVar(&opts.StatsdAddress, "statsd-address", "", "Address of the statsd sink, e.g. 127.0.0.1:8125")
+	fs.Uint64Var(&opts.MaxHeapSizeBytes, "max-heap-size-bytes", 0, "Enable the overload manager with the given max heap size")
+	fs.StringVar(&opts.RuntimeLayer, "runtime-layer", "", "Name of the RTDS runtime layer")
+	fs.BoolVar(&opts.DeltaADS, "delta-ads", false, "Use incremental ADS, required for listeners with VHDS")
+	fs.StringVar(&format, "format", "yaml", "Output format, yaml or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts.XDSPort = uint32(xdsPort)
+	opts.TLS = nil
+	if tlsMode {
+		opts.TLS = &tlsOpts
+	}
+
+	b, err := bootstrap.Generate(*opts)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch format {
+	case "yaml":
+		data, err = bootstrap.MarshalYAML(b)
+	case "json":
+		data, err = bootstrap.MarshalJSON(b)
+	default:
+		return fmt.Errorf("unsupported format %q, must be yaml or json", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -20,17 +20,20 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	mgrCache "sigs.k8s.io/controller-runtime/pkg/cache"
 
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/kaasops/envoy-xds-controller/internal/secretprovider"
 	"github.com/kaasops/envoy-xds-controller/internal/store"
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -42,10 +45,15 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
 	"github.com/kelseyhightower/envconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/kaasops/envoy-xds-controller/internal/xds"
 	"github.com/kaasops/envoy-xds-controller/internal/xds/api"
 	"github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/nodeauth"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/proxies"
 	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -83,7 +91,52 @@ type Config struct {
 	WatchNamespaces       []string `default:""                     envconfig:"WATCH_NAMESPACES"`
 	InstallationNamespace string   `default:"envoy-xds-controller" envconfig:"INSTALLATION_NAMESPACE"`
 	XDS                   struct {
-		Port int `default:"9000" envconfig:"XDS_PORT"`
+		Port                 int           `default:"9000"    envconfig:"XDS_PORT"`
+		UnixSocket           string        `default:""        envconfig:"XDS_UNIX_SOCKET"`
+		KeepaliveTime        time.Duration `default:"30s"     envconfig:"XDS_KEEPALIVE_TIME"`
+		KeepaliveTimeout     time.Duration `default:"5s"      envconfig:"XDS_KEEPALIVE_TIMEOUT"`
+		KeepaliveMinTime     time.Duration `default:"30s"     envconfig:"XDS_KEEPALIVE_MIN_TIME"`
+		MaxConcurrentStreams uint32        `default:"1000000" envconfig:"XDS_MAX_CONCURRENT_STREAMS"`
+		DrainTimeout         time.Duration `default:"30s"     envconfig:"XDS_DRAIN_TIMEOUT"`
+		SnapshotHistorySize  int           `default:"10"      envconfig:"XDS_SNAPSHOT_HISTORY_SIZE"`
+
+		TLSSecretName      string `default:""      envconfig:"XDS_TLS_SECRET_NAME"`
+		RequireClientCert  bool   `default:"false" envconfig:"XDS_TLS_REQUIRE_CLIENT_CERT"`
+		AuthorizationRules string `default:""      envconfig:"XDS_AUTHORIZATION_RULES"`
+
+		// AdvertiseAddress is the address of the xDS server used in generated Envoy bootstraps
+		AdvertiseAddress string `default:"" envconfig:"XDS_ADVERTISE_ADDRESS"`
+	}
+	CacheAPI struct {
+		TLSCertFile     string `default:"" envconfig:"CACHE_API_TLS_CERT_FILE"`
+		TLSKeyFile      string `default:"" envconfig:"CACHE_API_TLS_KEY_FILE"`
+		TLSClientCAFile string `default:"" envconfig:"CACHE_API_TLS_CLIENT_CA_FILE"`
+
+		TokenReview          bool     `default:"false" envconfig:"CACHE_API_AUTH_TOKEN_REVIEW"`
+		TokenReviewAudiences []string `default:""      envconfig:"CACHE_API_AUTH_TOKEN_REVIEW_AUDIENCES"`
+		// StaticTokensSecret is the name of the Secret with static API tokens in the installation namespace
+		StaticTokensSecret string `default:""      envconfig:"CACHE_API_AUTH_STATIC_TOKENS_SECRET"`
+		ClientCert         bool   `default:"false" envconfig:"CACHE_API_AUTH_CLIENT_CERT"`
+		// ACLConfigMap is the name of the ConfigMap with ACL rules in the installation namespace, replaces ACL_CONFIG
+		ACLConfigMap string `default:"" envconfig:"CACHE_API_AUTH_ACL_CONFIGMAP"`
+		// Debug enables the debug and profiling endpoints, they are always enabled in development mode
+		Debug bool `default:"false" envconfig:"CACHE_API_DEBUG"`
+		// Management enables the API writing virtual services and objects they reference
+		Management bool `default:"false" envconfig:"CACHE_API_MANAGEMENT"`
+	}
+	Bootstrap struct {
+		CAFile   string `default:"/etc/envoy/xds-certs/ca.crt"  envconfig:"BOOTSTRAP_CA_FILE"`
+		CertFile string `default:"/etc/envoy/xds-certs/tls.crt" envconfig:"BOOTSTRAP_CERT_FILE"`
+		KeyFile  string `default:"/etc/envoy/xds-certs/tls.key" envconfig:"BOOTSTRAP_KEY_FILE"`
+	}
+	Secrets struct {
+		LabelSelector string   `default:"envoy.kaasops.io/secret-type=sds-cached" envconfig:"SECRET_LABEL_SELECTOR"`
+		Annotation    string   `default:""                                        envconfig:"SECRET_ANNOTATION"`
+		Namespaces    []string `default:""                                        envconfig:"SECRET_NAMESPACES"`
+		Referenced    bool     `default:"false"                                   envconfig:"SECRET_REFERENCED"`
+
+		FileProviderDir        string        `default:""    envconfig:"SECRET_FILE_PROVIDER_DIR"`
+		FileProviderSyncPeriod time.Duration `default:"30s" envconfig:"SECRET_FILE_PROVIDER_SYNC_PERIOD"`
 	}
 	Webhook struct {
 		TLSSecretName  string `default:"envoy-xds-controller-webhook-cert"           envconfig:"WEBHOOK_TLS_SECRET_NAME"`
@@ -96,6 +149,21 @@ type Config struct {
 
 // nolint:gocyclo
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == bootstrapCommand {
+		if err := runBootstrapCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == simulateCommand {
+		if err := runSimulateCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
@@ -214,8 +282,31 @@ func main() {
 		os.Exit(1)
 	}
 
-	snapshotCache := cache.NewSnapshotCache()
-	cacheUpdater := updater.NewCacheUpdater(snapshotCache, store.New())
+	secretSelector, err := store.NewSecretSelector(cfg.Secrets.LabelSelector, cfg.Secrets.Annotation, cfg.Secrets.Namespaces, cfg.Secrets.Referenced)
+	if err != nil {
+		setupLog.Error(err, "unable to create secret selector")
+		os.Exit(1)
+	}
+	if !secretSelector.IsEager() && !secretSelector.Referenced {
+		setupLog.Info("secret selector is empty, secrets will not be loaded")
+	}
+
+	cacheStore := store.New()
+	cacheStore.SecretSelector = secretSelector
+
+	var fileSecretProvider *secretprovider.File
+	if cfg.Secrets.FileProviderDir != "" {
+		fileSecretProvider, err = secretprovider.NewFile(cfg.Secrets.FileProviderDir, cfg.Secrets.FileProviderSyncPeriod)
+		if err != nil {
+			setupLog.Error(err, "unable to create file secret provider")
+			os.Exit(1)
+		}
+		setupLog.Info("using file secret provider", "dir", cfg.Secrets.FileProviderDir)
+		cacheStore.SecretProvider = secretprovider.Chain{cacheStore.KubernetesSecretProvider(), fileSecretProvider}
+	}
+
+	snapshotCache := cache.NewSnapshotCache(cache.WithHistorySize(cfg.XDS.SnapshotHistorySize))
+	cacheUpdater := updater.NewCacheUpdater(snapshotCache, cacheStore)
 
 	if err = (&controller.ClusterReconciler{
 		Client:  mgr.GetClient(),
@@ -273,18 +364,27 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Policy")
 		os.Exit(1)
 	}
-	if err = (&controller.VirtualServiceTemplateReconciler{
+	if err = (&controller.RuntimeReconciler{
 		Client:  mgr.GetClient(),
 		Scheme:  mgr.GetScheme(),
 		Updater: cacheUpdater,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "VirtualServiceTemplate")
+		setupLog.Error(err, "unable to create controller", "controller", "Runtime")
 		os.Exit(1)
 	}
-	if err = (&controller.SecretReconciler{
+	if err = (&controller.VirtualServiceTemplateReconciler{
 		Client:  mgr.GetClient(),
 		Scheme:  mgr.GetScheme(),
 		Updater: cacheUpdater,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VirtualServiceTemplate")
+		os.Exit(1)
+	}
+	if err = (&controller.SecretReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Updater:   cacheUpdater,
+		APIReader: mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Secret")
 		os.Exit(1)
@@ -305,6 +405,7 @@ func main() {
 		// Enable Webhook Reconcile for create Certificate
 		webhookReconciler := &controller.WebhookReconciler{
 			Client:                webhookClient,
+			APIReader:             mgr.GetAPIReader(),
 			Scheme:                mgr.GetScheme(),
 			Namespace:             cfg.InstallationNamespace,
 			TLSSecretName:         cfg.Webhook.TLSSecretName,
@@ -328,11 +429,11 @@ func main() {
 			os.Exit(1)
 		}
 
-		if err = webhookenvoyv1alpha1.SetupAccessLogConfigWebhookWithManager(mgr); err != nil {
+		if err = webhookenvoyv1alpha1.SetupAccessLogConfigWebhookWithManager(mgr, cacheUpdater); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "AccessLogConfig")
 			os.Exit(1)
 		}
-		if err = webhookenvoyv1alpha1.SetupListenerWebhookWithManager(mgr); err != nil {
+		if err = webhookenvoyv1alpha1.SetupListenerWebhookWithManager(mgr, cacheUpdater); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Listener")
 			os.Exit(1)
 		}
@@ -340,19 +441,23 @@ func main() {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Cluster")
 			os.Exit(1)
 		}
-		if err = webhookenvoyv1alpha1.SetupRouteWebhookWithManager(mgr); err != nil {
+		if err = webhookenvoyv1alpha1.SetupRouteWebhookWithManager(mgr, cacheUpdater); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Route")
 			os.Exit(1)
 		}
-		if err = webhookenvoyv1alpha1.SetupPolicyWebhookWithManager(mgr); err != nil {
+		if err = webhookenvoyv1alpha1.SetupPolicyWebhookWithManager(mgr, cacheUpdater); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Policy")
 			os.Exit(1)
 		}
-		if err = webhookenvoyv1alpha1.SetupHttpFilterWebhookWithManager(mgr); err != nil {
+		if err = webhookenvoyv1alpha1.SetupRuntimeWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Runtime")
+			os.Exit(1)
+		}
+		if err = webhookenvoyv1alpha1.SetupHttpFilterWebhookWithManager(mgr, cacheUpdater); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "HttpFilter")
 			os.Exit(1)
 		}
-		if err = webhookenvoyv1alpha1.SetupVirtualServiceWebhookWithManager(mgr); err != nil {
+		if err = webhookenvoyv1alpha1.SetupVirtualServiceWebhookWithManager(mgr, cacheUpdater); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "VirtualService")
 			os.Exit(1)
 		}
@@ -375,30 +480,106 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("xds", func(_ *http.Request) error {
+		if !cacheUpdater.IsReady() {
+			return errors.New("xDS snapshots are not built yet")
+		}
+		return nil
+	}); err != nil {
+		setupLog.Error(err, "unable to set up xDS ready check")
+		os.Exit(1)
+	}
+
+	proxyRegistry := proxies.NewRegistry(&test.Callbacks{Debug: true})
+
+	var startXDSServer manager.RunnableFunc = func(ctx context.Context) error {
+		var xdsCallbacks server.Callbacks = proxyRegistry
+		var xdsServerOpts []grpc.ServerOption
+		if cfg.XDS.TLSSecretName != "" {
+			certSource := nodeauth.NewSecretCertificateSource(mgr.GetAPIReader(), types.NamespacedName{
+				Namespace: cfg.InstallationNamespace,
+				Name:      cfg.XDS.TLSSecretName,
+			}, nodeauth.DefaultCertificateRefreshPeriod)
+			tlsConfig, err := certSource.TLSConfig(ctx, cfg.XDS.RequireClientCert)
+			if err != nil {
+				return fmt.Errorf("unable to configure xDS server TLS: %w", err)
+			}
+			xdsServerOpts = append(xdsServerOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		if cfg.XDS.AuthorizationRules != "" {
+			if !cfg.XDS.RequireClientCert {
+				return errors.New("xDS node authorization requires client certificates, set XDS_TLS_REQUIRE_CLIENT_CERT")
+			}
+			authorizer, err := nodeauth.ParseIdentityAuthorizer(cfg.XDS.AuthorizationRules)
+			if err != nil {
+				return err
+			}
+			xdsCallbacks = nodeauth.NewCallbacks(xdsCallbacks, authorizer, log.FromContext(ctx).WithName("xds-authorizer"))
+		}
+
+		srv := server.NewServer(ctx, snapshotCache, xdsCallbacks)
+		return xds.NewServer(srv, xds.ServerConfig{
+			Port:                 cfg.XDS.Port,
+			UnixSocket:           cfg.XDS.UnixSocket,
+			KeepaliveTime:        cfg.XDS.KeepaliveTime,
+			KeepaliveTimeout:     cfg.XDS.KeepaliveTimeout,
+			KeepaliveMinTime:     cfg.XDS.KeepaliveMinTime,
+			MaxConcurrentStreams: cfg.XDS.MaxConcurrentStreams,
+			DrainTimeout:         cfg.XDS.DrainTimeout,
+		}, xdsServerOpts...).Start(ctx)
+	}
 
 	var startServers manager.RunnableFunc = func(ctx context.Context) error {
 		setupServers := log.FromContext(ctx)
 		setupServers.Info("Starting servers")
 
-		if err := cacheUpdater.Init(ctx, mgr.GetClient()); err != nil {
+		// Secrets are read directly from the API server, they are not cached by the manager
+		if err := cacheUpdater.Init(ctx, mgr.GetAPIReader()); err != nil {
 			return fmt.Errorf("unable to init cache updater: %w", err)
 		}
 
-		go func() {
-			srv := server.NewServer(ctx, snapshotCache, &test.Callbacks{Debug: true})
-			if err = xds.RunServer(srv, cfg.XDS.Port); err != nil {
-				setupServers.Error(err, "cannot run xDS server")
-				os.Exit(1)
-			}
-		}()
-
 		if enableCacheAPI {
 			go func() {
 				xdsServerCfg := &api.Config{}
 				xdsServerCfg.EnableDevMode = devMode
+				xdsServerCfg.Bootstrap = bootstrapOptions(&cfg)
+				xdsServerCfg.Proxies = proxyRegistry
+				xdsServerCfg.Updater = cacheUpdater
+				xdsServerCfg.Debug = devMode || cfg.CacheAPI.Debug
+				xdsServerCfg.Management = cfg.CacheAPI.Management
+				if cfg.CacheAPI.Management {
+					xdsServerCfg.UserClients, err = api.NewImpersonatingClients(mgr.GetConfig(), client.Options{
+						Scheme: mgr.GetScheme(),
+						Mapper: mgr.GetRESTMapper(),
+					})
+					if err != nil {
+						setupServers.Error(err, "failed to create clients of the management API")
+						os.Exit(1)
+					}
+				}
 				xdsServerCfg.Auth.Enabled, _ = strconv.ParseBool(os.Getenv("OIDC_ENABLED"))
 				xdsServerCfg.Auth.IssuerURL = os.Getenv("OIDC_ISSUER_URL")
 				xdsServerCfg.Auth.ClientID = os.Getenv("OIDC_CLIENT_ID")
+				xdsServerCfg.Auth.TokenReview = cfg.CacheAPI.TokenReview
+				xdsServerCfg.Auth.TokenReviewAudiences = cfg.CacheAPI.TokenReviewAudiences
+				if cfg.CacheAPI.StaticTokensSecret != "" {
+					xdsServerCfg.Auth.StaticTokensSecret = types.NamespacedName{
+						Namespace: cfg.InstallationNamespace,
+						Name:      cfg.CacheAPI.StaticTokensSecret,
+					}
+				}
+				xdsServerCfg.Auth.ClientCert = cfg.CacheAPI.ClientCert
+				if cfg.CacheAPI.ACLConfigMap != "" {
+					xdsServerCfg.Auth.ACLConfigMap = types.NamespacedName{
+						Namespace: cfg.InstallationNamespace,
+						Name:      cfg.CacheAPI.ACLConfigMap,
+					}
+				}
+				xdsServerCfg.TLS.CertFile = cfg.CacheAPI.TLSCertFile
+				xdsServerCfg.TLS.KeyFile = cfg.CacheAPI.TLSKeyFile
+				xdsServerCfg.TLS.ClientCAFile = cfg.CacheAPI.TLSClientCAFile
+				xdsServerCfg.KubeClient = mgr.GetClient()
+				xdsServerCfg.APIReader = mgr.GetAPIReader()
 				if acl := os.Getenv("ACL_CONFIG"); acl != "" {
 					err = json.Unmarshal([]byte(acl), &xdsServerCfg.Auth.ACL)
 					if err != nil {
@@ -414,51 +595,6 @@ func main() {
 			}()
 		}
 
-		if devMode {
-			go func() {
-				dLog := log.FromContext(ctx).WithName("debug-server")
-
-				http.HandleFunc("/debug/store", func(w http.ResponseWriter, r *http.Request) {
-					data, err := cacheUpdater.GetMarshaledStore()
-					if err != nil {
-						dLog.Error(err, "failed to marshal store")
-						w.WriteHeader(http.StatusInternalServerError)
-						return
-					}
-					_, _ = w.Write(data)
-				})
-				http.HandleFunc("/debug/xds", func(w http.ResponseWriter, r *http.Request) {
-					keys := snapshotCache.GetNodeIDsAsMap()
-					dump := make(map[string]any)
-					for key := range keys {
-						snapshot, _ := snapshotCache.GetSnapshot(key)
-						dump[key] = snapshot
-					}
-					data, err := json.MarshalIndent(dump, "", "\t")
-					if err != nil {
-						dLog.Error(err, "failed to marshal xds")
-						w.WriteHeader(http.StatusInternalServerError)
-						return
-					}
-					_, _ = w.Write(data)
-				})
-				http.HandleFunc("/debug/used-secrets", func(w http.ResponseWriter, r *http.Request) {
-					secrets := cacheUpdater.GetUsedSecrets()
-					m := make(map[string]string, len(secrets))
-					for k, v := range secrets {
-						m[k.String()] = v.String()
-					}
-					data, err := json.MarshalIndent(m, "", "\t")
-					if err != nil {
-						dLog.Error(err, "failed to marshal used secrets")
-						w.WriteHeader(http.StatusInternalServerError)
-						return
-					}
-					_, _ = w.Write(data)
-				})
-				_ = http.ListenAndServe(":4444", nil)
-			}()
-		}
 		return nil
 	}
 
@@ -466,6 +602,24 @@ func main() {
 		setupLog.Error(err, "unable to add startServers to manager")
 		os.Exit(1)
 	}
+	if err = mgr.Add(startXDSServer); err != nil {
+		setupLog.Error(err, "unable to add xDS server to manager")
+		os.Exit(1)
+	}
+
+	if fileSecretProvider != nil {
+		var startFileSecretProvider manager.RunnableFunc = func(ctx context.Context) error {
+			return fileSecretProvider.Start(ctx, func() {
+				if err := cacheUpdater.RebuildCache(ctx); err != nil {
+					log.FromContext(ctx).Error(err, "failed to rebuild cache after secrets change")
+				}
+			})
+		}
+		if err = mgr.Add(startFileSecretProvider); err != nil {
+			setupLog.Error(err, "unable to add file secret provider to manager")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
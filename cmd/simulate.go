@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const simulateCommand = "simulate"
+
+// runSimulateCommand asks the cache API how a request would be routed by a node and prints the result.
+func runSimulateCommand(args []string) error {
+	var (
+		apiAddress string
+		token      string
+		headers    []string
+		query      = url.Values{}
+	)
+
+	fs := flag.NewFlagSet(simulateCommand, flag.ContinueOnError)
+	fs.StringVar(&apiAddress, "api-address", "http://localhost:9999", "Address of the cache API")
+	fs.StringVar(&token, "token", "", "Bearer token of the cache API")
+	for _, param := range []struct{ flag, name, usage string }{
+		{"node-id", "node_id", "Envoy node ID (required)"},
+		{"listener", "listener_name", "Listener name, all listeners are tried if not set"},
+		{"host", "host", "Host (authority) of the request (required)"},
+		{"sni", "sni", "TLS server name, the host without port is used if not set"},
+		{"path", "path", "Path of the request with optional query string"},
+		{"method", "method", "HTTP method of the request"},
+	} {
+		name := param.name
+		fs.Func(param.flag, param.usage, func(s string) error {
+			query.Set(name, s)
+			return nil
+		})
+	}
+	fs.Func("header", "Request header in the name:value format, can be repeated", func(s string) error {
+		if !strings.Contains(s, ":") {
+			return errors.New("header must be in the name:value format")
+		}
+		headers = append(headers, s)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if query.Get("node_id") == "" || query.Get("host") == "" {
+		return errors.New("--node-id and --host are required")
+	}
+	query["header"] = headers
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(apiAddress, "/")+"/api/v1/simulate?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		out.Reset()
+		out.Write(body)
+	}
+	out.WriteByte('\n')
+	if _, err := os.Stdout.Write(out.Bytes()); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cache API responded %s", resp.Status)
+	}
+	return nil
+}
@@ -84,6 +84,9 @@ Generate volumeMounts
 {{- if .Values.webhook.enabled -}}
 {{- $mounts = append $mounts (dict "name" "cert" "mountPath" "/tmp/k8s-webhook-server/serving-certs" "readOnly" true) -}}
 {{- end -}}
+{{- if .Values.cacheAPI.tlsSecretName -}}
+{{- $mounts = append $mounts (dict "name" "cache-api-tls" "mountPath" "/etc/cache-api/tls" "readOnly" true) -}}
+{{- end -}}
 {{- if .Values.extraVolumeMounts -}}
 {{- $mounts = concat $mounts .Values.extraVolumeMounts -}}
 {{- end -}}
@@ -105,6 +108,9 @@ Generate volumes
     ) -}}
 {{- $volumes = append $volumes $certVolume -}}
 {{- end -}}
+{{- if .Values.cacheAPI.tlsSecretName -}}
+{{- $volumes = append $volumes (dict "name" "cache-api-tls" "secret" (dict "secretName" .Values.cacheAPI.tlsSecretName "defaultMode" 420)) -}}
+{{- end -}}
 {{- if .Values.extraVolumes -}}
 {{- $volumes = concat $volumes .Values.extraVolumes -}}
 {{- end -}}
@@ -19,8 +19,11 @@ package controller
 import (
 	"context"
 
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
 	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
 	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
@@ -35,6 +38,9 @@ type SecretReconciler struct {
 	client.Client
 	Scheme  *runtime.Scheme
 	Updater *updater.CacheUpdater
+	// APIReader reads Secrets directly from the API server.
+	// Only metadata of Secrets is cached by the manager, so that data of all Secrets in the cluster is not kept in memory.
+	APIReader client.Reader
 }
 
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
@@ -53,13 +59,18 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	rlog.Info("Reconciling Secret")
 
 	var secret v1.Secret
-	if err := r.Get(ctx, req.NamespacedName, &secret); err != nil {
+	if err := r.APIReader.Get(ctx, req.NamespacedName, &secret); err != nil {
 		if client.IgnoreNotFound(err) != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, r.Updater.DeleteSecret(ctx, req.NamespacedName)
 	}
 
+	if !store.IsSupportedSecret(&secret) || !r.isSelected(&secret) {
+		rlog.Info("Secret is not selected, removing from cache")
+		return ctrl.Result{}, r.Updater.DeleteSecret(ctx, req.NamespacedName)
+	}
+
 	if err := r.Updater.UpsertSecret(ctx, &secret); err != nil {
 		return ctrl.Result{}, err
 	}
@@ -72,28 +83,26 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1.Secret{}).
+		For(&v1.Secret{}, builder.OnlyMetadata).
 		Named("kubernetes-secret").
 		WithEventFilter(predicate.Funcs{
 			CreateFunc: func(e event.CreateEvent) bool {
-				return filterSecret(e.Object.(*v1.Secret))
+				return r.isSelected(e.Object)
 			},
 			UpdateFunc: func(e event.UpdateEvent) bool {
-				return filterSecret(e.ObjectNew.(*v1.Secret))
+				return r.isSelected(e.ObjectOld) || r.isSelected(e.ObjectNew)
 			},
 			DeleteFunc: func(e event.DeleteEvent) bool {
-				return filterSecret(e.Object.(*v1.Secret))
+				return r.isSelected(e.Object)
 			},
 		}).
 		Complete(r)
 }
 
-func filterSecret(s *v1.Secret) bool {
-	if s.Type != v1.SecretTypeOpaque && s.Type != v1.SecretTypeTLS {
-		return false
-	}
-	if _, ok := s.Labels["envoy.kaasops.io/secret-type"]; !ok {
-		return false
+// isSelected checks that the Secret is matched by the secret selector or referenced by a VirtualService.
+func (r *SecretReconciler) isSelected(obj client.Object) bool {
+	if r.Updater.GetSecretSelector().Matches(obj) {
+		return true
 	}
-	return true
+	return r.Updater.IsSecretReferenced(helpers.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()})
 }
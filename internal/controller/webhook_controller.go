@@ -39,7 +39,6 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -58,6 +57,8 @@ const (
 
 type WebhookReconciler struct {
 	client.Client
+	// APIReader reads the TLS secret from the API server, so the manager doesn't cache all Secrets
+	APIReader client.Reader
 	Scheme    *runtime.Scheme
 	Namespace string
 
@@ -82,7 +83,7 @@ func (r *WebhookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	})
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Secret{}, namesMatchingPredicate(r.TLSSecretName)).
+		For(&corev1.Secret{}, builder.OnlyMetadata, namesMatchingPredicate(r.TLSSecretName)).
 		Watches(&admissionregistrationv1.ValidatingWebhookConfiguration{}, enqueueFn, namesMatchingPredicate(r.ValidationWebhookName)).
 		Complete(r)
 }
@@ -92,7 +93,7 @@ func (r *WebhookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	r.Log.Info("Reconciling Webhook")
 
 	certSecret := &corev1.Secret{}
-	if err := r.Client.Get(ctx, req.NamespacedName, certSecret); err != nil {
+	if err := r.APIReader.Get(ctx, req.NamespacedName, certSecret); err != nil {
 		return reconcile.Result{}, err
 	}
 
@@ -121,7 +122,7 @@ func (r *WebhookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 func (r *WebhookReconciler) ReconcileCertificates(ctx context.Context, certSecret *corev1.Secret) error {
 
-	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: certSecret.Namespace, Name: certSecret.Name}, certSecret); err != nil {
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: certSecret.Namespace, Name: certSecret.Name}, certSecret); err != nil {
 		if err := r.Client.Create(ctx, certSecret); err != nil {
 			return fmt.Errorf("failed to create secret: %w", err)
 		}
@@ -150,14 +151,8 @@ func (r *WebhookReconciler) ReconcileCertificates(ctx context.Context, certSecre
 			corev1.ServiceAccountRootCAKey: caCrt.Bytes(),
 		}
 
-		t := &corev1.Secret{ObjectMeta: certSecret.ObjectMeta}
-
-		_, err = controllerutil.CreateOrUpdate(ctx, r.Client, t, func() error {
-			t.Data = certSecret.Data
-
-			return nil
-		})
-		if err != nil {
+		// the secret is read or created above, it is updated without reading through the cached client
+		if err := r.Client.Update(ctx, certSecret); err != nil {
 			return fmt.Errorf("failed to update secret: %w", err)
 		}
 	}
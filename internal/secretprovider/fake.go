@@ -0,0 +1,49 @@
+package secretprovider
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Fake is an in-memory provider for tests.
+type Fake struct {
+	mu      sync.RWMutex
+	secrets map[helpers.NamespacedName]*v1.Secret
+}
+
+func NewFake(secrets ...*v1.Secret) *Fake {
+	f := &Fake{secrets: make(map[helpers.NamespacedName]*v1.Secret, len(secrets))}
+	for _, secret := range secrets {
+		f.Set(secret)
+	}
+	return f
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) GetSecret(_ context.Context, namespace, name string) (*v1.Secret, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	secret, ok := f.secrets[helpers.NamespacedName{Namespace: namespace, Name: name}]
+	if !ok {
+		return nil, NotFound(namespace, name)
+	}
+	return secret, nil
+}
+
+func (f *Fake) Set(secret *v1.Secret) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = secret
+}
+
+func (f *Fake) Delete(namespace, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.secrets, helpers.NamespacedName{Namespace: namespace, Name: name})
+}
@@ -0,0 +1,210 @@
+package secretprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const DefaultFileSyncPeriod = 30 * time.Second
+
+const (
+	caCertKey = "ca.crt"
+	caCRLKey  = "ca.crl"
+)
+
+// File provides secrets from a directory, usually a mounted volume (CSI driver, projected volume, etc.).
+// The directory layout is <dir>/<namespace>/<name>/<key>, where every file is a key of the secret.
+// Secrets with tls.crt and tls.key are served as TLS certificates, secrets with only ca.crt (and ca.crl)
+// as validation contexts, all others as generic secrets.
+// Hidden files are skipped, this covers "..data" symlinks of Kubernetes volumes.
+type File struct {
+	dir        string
+	syncPeriod time.Duration
+
+	mu       sync.RWMutex
+	secrets  map[helpers.NamespacedName]*v1.Secret
+	checksum []byte
+}
+
+func NewFile(dir string, syncPeriod time.Duration) (*File, error) {
+	if syncPeriod <= 0 {
+		syncPeriod = DefaultFileSyncPeriod
+	}
+	f := &File{
+		dir:        dir,
+		syncPeriod: syncPeriod,
+		secrets:    make(map[helpers.NamespacedName]*v1.Secret),
+	}
+	if _, err := f.Load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) Name() string {
+	return "file"
+}
+
+func (f *File) GetSecret(_ context.Context, namespace, name string) (*v1.Secret, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	secret, ok := f.secrets[helpers.NamespacedName{Namespace: namespace, Name: name}]
+	if !ok {
+		return nil, NotFound(namespace, name)
+	}
+	return secret, nil
+}
+
+// Start rereads the directory every sync period and calls onChange if content of secrets is changed.
+func (f *File) Start(ctx context.Context, onChange func()) error {
+	rlog := log.FromContext(ctx).WithName("file-secret-provider").WithValues("dir", f.dir)
+	rlog.Info("Starting file secret provider")
+
+	ticker := time.NewTicker(f.syncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := f.Load()
+			if err != nil {
+				rlog.Error(err, "failed to load secrets")
+				continue
+			}
+			if changed {
+				rlog.Info("Secrets changed")
+				onChange()
+			}
+		}
+	}
+}
+
+// Load reads secrets from the directory and returns true if they are changed since the previous load.
+func (f *File) Load() (bool, error) {
+	secrets := make(map[helpers.NamespacedName]*v1.Secret)
+	hash := sha256.New()
+
+	namespaces, err := readDirNames(f.dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read secrets directory %s: %w", f.dir, err)
+	}
+	for _, namespace := range namespaces {
+		names, err := readDirNames(filepath.Join(f.dir, namespace))
+		if err != nil {
+			return false, err
+		}
+		for _, name := range names {
+			secretDir := filepath.Join(f.dir, namespace, name)
+			keys, err := readFileNames(secretDir)
+			if err != nil {
+				return false, err
+			}
+			if len(keys) == 0 {
+				continue
+			}
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Data:       make(map[string][]byte, len(keys)),
+			}
+			for _, key := range keys {
+				data, err := os.ReadFile(filepath.Join(secretDir, key))
+				if err != nil {
+					return false, fmt.Errorf("failed to read secret %s/%s key %s: %w", namespace, name, key, err)
+				}
+				secret.Data[key] = data
+				_, _ = fmt.Fprintf(hash, "%s/%s/%s:%d:", namespace, name, key, len(data))
+				_, _ = hash.Write(data)
+			}
+			setSecretType(secret)
+			secrets[helpers.NamespacedName{Namespace: namespace, Name: name}] = secret
+		}
+	}
+
+	checksum := hash.Sum(nil)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	changed := !bytes.Equal(f.checksum, checksum)
+	f.secrets = secrets
+	f.checksum = checksum
+	return changed, nil
+}
+
+func setSecretType(secret *v1.Secret) {
+	_, hasCert := secret.Data[v1.TLSCertKey]
+	_, hasKey := secret.Data[v1.TLSPrivateKeyKey]
+	if hasCert && hasKey {
+		secret.Type = v1.SecretTypeTLS
+		return
+	}
+	secret.Type = v1.SecretTypeOpaque
+	if _, hasCA := secret.Data[caCertKey]; hasCA {
+		for key := range secret.Data {
+			if key != caCertKey && key != caCRLKey {
+				return
+			}
+		}
+		secret.Annotations = map[string]string{v1alpha1.AnnotationSecretKind: v1alpha1.SecretKindValidationContext}
+	}
+}
+
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			res = append(res, entry.Name())
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func readFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		// files of mounted volumes are symlinks, so stat them
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() {
+			res = append(res, entry.Name())
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
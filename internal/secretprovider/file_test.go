@@ -0,0 +1,111 @@
+package secretprovider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "ns", "tls", v1.TLSCertKey), "cert")
+	writeFile(t, filepath.Join(dir, "ns", "tls", v1.TLSPrivateKeyKey), "key")
+	writeFile(t, filepath.Join(dir, "ns", "ca", caCertKey), "ca")
+	writeFile(t, filepath.Join(dir, "ns", "generic", "token"), "token")
+	writeFile(t, filepath.Join(dir, "ns", "generic", "..data", "token"), "hidden")
+
+	p, err := NewFile(dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secret, err := p.GetSecret(context.Background(), "ns", "tls")
+	if err != nil || secret.Type != v1.SecretTypeTLS {
+		t.Errorf("expected tls secret, got %v", secret)
+	}
+	secret, err = p.GetSecret(context.Background(), "ns", "ca")
+	if err != nil || secret.Annotations[v1alpha1.AnnotationSecretKind] != v1alpha1.SecretKindValidationContext {
+		t.Errorf("expected validation context secret, got %v", secret)
+	}
+	secret, err = p.GetSecret(context.Background(), "ns", "generic")
+	if err != nil || secret.Type != v1.SecretTypeOpaque || len(secret.Data) != 1 || string(secret.Data["token"]) != "token" {
+		t.Errorf("expected generic secret, got %v", secret)
+	}
+	if _, err := p.GetSecret(context.Background(), "ns", "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected secret not found")
+	}
+
+	changed, err := p.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Errorf("expected no changes")
+	}
+
+	writeFile(t, filepath.Join(dir, "ns", "generic", "token"), "rotated")
+	changed, err = p.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("expected changes")
+	}
+	if secret, _ := p.GetSecret(context.Background(), "ns", "generic"); string(secret.Data["token"]) != "rotated" {
+		t.Errorf("expected rotated token, got %s", secret.Data["token"])
+	}
+}
+
+func TestChain(t *testing.T) {
+	first := NewFake(secretWithData("ns", "a", "first"))
+	second := NewFake(secretWithData("ns", "a", "second"), secretWithData("ns", "b", "second"))
+
+	chain := Chain{first, second}
+	if secret, err := chain.GetSecret(context.Background(), "ns", "a"); err != nil || string(secret.Data["k"]) != "first" {
+		t.Errorf("expected secret from first provider")
+	}
+	if secret, err := chain.GetSecret(context.Background(), "ns", "b"); err != nil || string(secret.Data["k"]) != "second" {
+		t.Errorf("expected secret from second provider")
+	}
+	if _, err := chain.GetSecret(context.Background(), "ns", "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected secret not found, got %v", err)
+	}
+
+	// failures of providers aren't hidden by later providers
+	chain = Chain{failingProvider{}, second}
+	if _, err := chain.GetSecret(context.Background(), "ns", "a"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected provider failure, got %v", err)
+	}
+}
+
+type failingProvider struct{}
+
+func (failingProvider) Name() string {
+	return "failing"
+}
+
+func (failingProvider) GetSecret(context.Context, string, string) (*v1.Secret, error) {
+	return nil, context.DeadlineExceeded
+}
+
+func secretWithData(namespace, name, value string) *v1.Secret {
+	s := &v1.Secret{Data: map[string][]byte{"k": []byte(value)}}
+	s.Namespace = namespace
+	s.Name = name
+	return s
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
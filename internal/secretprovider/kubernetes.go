@@ -0,0 +1,31 @@
+package secretprovider
+
+import (
+	"context"
+
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Kubernetes provides secrets loaded from Kubernetes Secret objects.
+type Kubernetes struct {
+	secrets func() map[helpers.NamespacedName]*v1.Secret
+}
+
+// NewKubernetes creates the provider. The secrets function is called on each lookup,
+// so the provider always sees the current set of secrets.
+func NewKubernetes(secrets func() map[helpers.NamespacedName]*v1.Secret) *Kubernetes {
+	return &Kubernetes{secrets: secrets}
+}
+
+func (k *Kubernetes) Name() string {
+	return "kubernetes"
+}
+
+func (k *Kubernetes) GetSecret(_ context.Context, namespace, name string) (*v1.Secret, error) {
+	secret, ok := k.secrets()[helpers.NamespacedName{Namespace: namespace, Name: name}]
+	if !ok {
+		return nil, NotFound(namespace, name)
+	}
+	return secret, nil
+}
@@ -0,0 +1,55 @@
+package secretprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ErrNotFound is returned by providers which don't have the secret.
+var ErrNotFound = errors.New("secret not found")
+
+// NotFound returns ErrNotFound for the secret.
+func NotFound(namespace, name string) error {
+	return fmt.Errorf("%w: %s/%s", ErrNotFound, namespace, name)
+}
+
+// Provider is a source of secrets served over SDS.
+// Secrets are returned as Kubernetes Secrets, so that all providers share the same
+// conversion to Envoy secrets (see resbuilder) and the same secret kind annotations.
+type Provider interface {
+	// Name returns the name of the provider for logging and debugging.
+	Name() string
+	// GetSecret returns the secret by namespace and name. The error wraps ErrNotFound if the secret is not found,
+	// other errors are failures of the provider, e.g. timeouts of a remote secret store.
+	GetSecret(ctx context.Context, namespace, name string) (*v1.Secret, error)
+}
+
+// Watcher is implemented by providers which can detect changes of secrets by themselves.
+// Start blocks until the context is done and calls onChange after secrets are changed.
+type Watcher interface {
+	Start(ctx context.Context, onChange func()) error
+}
+
+// Chain looks up the secret in providers in order and returns the first found.
+// Lookup stops at the first failure of a provider, so secrets of later providers don't shadow unavailable ones.
+type Chain []Provider
+
+func (c Chain) Name() string {
+	return "chain"
+}
+
+func (c Chain) GetSecret(ctx context.Context, namespace, name string) (*v1.Secret, error) {
+	for _, p := range c {
+		secret, err := p.GetSecret(ctx, namespace, name)
+		if err == nil {
+			return secret, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%s provider: %w", p.Name(), err)
+		}
+	}
+	return nil, NotFound(namespace, name)
+}
@@ -0,0 +1,330 @@
+package store
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Kinds of objects in the reference index.
+const (
+	KindVirtualService         = "VirtualService"
+	KindVirtualServiceTemplate = "VirtualServiceTemplate"
+	KindListener               = "Listener"
+	KindRoute                  = "Route"
+	KindCluster                = "Cluster"
+	KindHTTPFilter             = "HttpFilter"
+	KindPolicy                 = "Policy"
+	KindAccessLogConfig        = "AccessLogConfig"
+	KindSecret                 = "Secret"
+)
+
+var kinds = []string{
+	KindVirtualService, KindVirtualServiceTemplate, KindListener, KindRoute, KindCluster,
+	KindHTTPFilter, KindPolicy, KindAccessLogConfig, KindSecret,
+}
+
+// ParseKind returns the kind matching the name case-insensitively.
+func ParseKind(name string) (string, bool) {
+	for _, kind := range kinds {
+		if strings.EqualFold(kind, name) {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// ObjectKind returns the kind of the object in the reference index.
+func ObjectKind(obj client.Object) (string, bool) {
+	switch obj.(type) {
+	case *v1alpha1.VirtualService:
+		return KindVirtualService, true
+	case *v1alpha1.VirtualServiceTemplate:
+		return KindVirtualServiceTemplate, true
+	case *v1alpha1.Listener:
+		return KindListener, true
+	case *v1alpha1.Route:
+		return KindRoute, true
+	case *v1alpha1.Cluster:
+		return KindCluster, true
+	case *v1alpha1.HttpFilter:
+		return KindHTTPFilter, true
+	case *v1alpha1.Policy:
+		return KindPolicy, true
+	case *v1alpha1.AccessLogConfig:
+		return KindAccessLogConfig, true
+	case *v1.Secret:
+		return KindSecret, true
+	}
+	return "", false
+}
+
+type ObjectKey struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// Referrer is an object which references another object.
+type Referrer struct {
+	ObjectKey
+	// Via is the template, Route, HttpFilter or Listener through which the object is referenced, nil if it is referenced directly
+	Via *ObjectKey `json:"via,omitempty"`
+}
+
+// GetReferrers returns objects referencing the object, sorted by kind, namespace and name.
+func (s *Store) GetReferrers(key ObjectKey) []Referrer {
+	referrers := append([]Referrer(nil), s.References[key]...)
+	sort.Slice(referrers, func(i, j int) bool {
+		return referrerLess(referrers[i], referrers[j])
+	})
+	return referrers
+}
+
+// UpdateReferences rebuilds the reverse index of references between objects of the store.
+// Virtual services are indexed as referrers of everything they use, directly, through their templates
+// and through Routes, HttpFilters and Listeners.
+func (s *Store) UpdateReferences() {
+	index := make(map[ObjectKey][]Referrer)
+	// referrers are deduplicated by value of Via, not by pointer
+	type seenKey struct {
+		key, referrer, via ObjectKey
+	}
+	seen := make(map[seenKey]struct{})
+	add := func(key ObjectKey, referrer Referrer) {
+		k := seenKey{key: key, referrer: referrer.ObjectKey}
+		if referrer.Via != nil {
+			k.via = *referrer.Via
+		}
+		if _, ok := seen[k]; ok {
+			return
+		}
+		seen[k] = struct{}{}
+		index[key] = append(index[key], referrer)
+	}
+
+	// references of shared objects, they are used by virtual services transitively
+	nested := make(map[ObjectKey][]ObjectKey)
+	for nn, route := range s.Routes {
+		key := ObjectKey{Kind: KindRoute, Namespace: nn.Namespace, Name: nn.Name}
+		for _, spec := range route.Spec {
+			if spec != nil {
+				nested[key] = append(nested[key], s.clusterKeys(spec.Raw)...)
+			}
+		}
+	}
+	for nn, hf := range s.HTTPFilters {
+		key := ObjectKey{Kind: KindHTTPFilter, Namespace: nn.Namespace, Name: nn.Name}
+		for _, spec := range hf.Spec {
+			if spec != nil {
+				nested[key] = append(nested[key], s.clusterKeys(spec.Raw)...)
+				nested[key] = append(nested[key], secretKeys(spec.Raw)...)
+			}
+		}
+	}
+	for nn, listener := range s.Listeners {
+		key := ObjectKey{Kind: KindListener, Namespace: nn.Namespace, Name: nn.Name}
+		if listener.Spec != nil {
+			nested[key] = append(nested[key], s.clusterKeys(listener.Spec.Raw)...)
+		}
+	}
+	for key, refs := range nested {
+		for _, ref := range refs {
+			add(ref, Referrer{ObjectKey: key})
+		}
+	}
+
+	for nn, vst := range s.VirtualServiceTemplates {
+		key := ObjectKey{Kind: KindVirtualServiceTemplate, Namespace: nn.Namespace, Name: nn.Name}
+		for _, ref := range s.specReferences(&vst.Spec.VirtualServiceCommonSpec, nn.Namespace) {
+			add(ref, Referrer{ObjectKey: key})
+		}
+	}
+
+	for nn, vs := range s.VirtualServices {
+		key := ObjectKey{Kind: KindVirtualService, Namespace: nn.Namespace, Name: nn.Name}
+		direct := s.specReferences(&vs.Spec.VirtualServiceCommonSpec, vs.Namespace)
+		directSet := make(map[ObjectKey]struct{}, len(direct))
+		for _, ref := range direct {
+			directSet[ref] = struct{}{}
+		}
+		all := direct
+
+		if vs.Spec.Template != nil {
+			templateKey := ObjectKey{
+				Kind:      KindVirtualServiceTemplate,
+				Namespace: helpers.GetNamespace(vs.Spec.Template.Namespace, vs.Namespace),
+				Name:      vs.Spec.Template.Name,
+			}
+			add(templateKey, Referrer{ObjectKey: key})
+			if vst := s.VirtualServiceTemplates[helpers.NamespacedName{Namespace: templateKey.Namespace, Name: templateKey.Name}]; vst != nil {
+				vsCopy := vs.DeepCopy()
+				if err := vsCopy.FillFromTemplate(vst, vsCopy.Spec.TemplateOptions...); err == nil {
+					all = s.specReferences(&vsCopy.Spec.VirtualServiceCommonSpec, vs.Namespace)
+				}
+			}
+			for _, ref := range all {
+				if _, ok := directSet[ref]; !ok {
+					add(ref, Referrer{ObjectKey: key, Via: &templateKey})
+				}
+			}
+		}
+		for _, ref := range direct {
+			add(ref, Referrer{ObjectKey: key})
+		}
+
+		for _, ref := range all {
+			via := ref
+			for _, nestedRef := range nested[ref] {
+				add(nestedRef, Referrer{ObjectKey: key, Via: &via})
+			}
+		}
+	}
+
+	s.References = index
+}
+
+// specReferences returns objects referenced by the spec of a virtual service or a template.
+func (s *Store) specReferences(spec *v1alpha1.VirtualServiceCommonSpec, namespace string) []ObjectKey {
+	var refs []ObjectKey
+	ref := func(kind string, r *v1alpha1.ResourceRef) {
+		if r != nil {
+			refs = append(refs, ObjectKey{Kind: kind, Namespace: helpers.GetNamespace(r.Namespace, namespace), Name: r.Name})
+		}
+	}
+
+	ref(KindListener, spec.Listener)
+	ref(KindAccessLogConfig, spec.AccessLogConfig)
+	for _, r := range spec.AdditionalRoutes {
+		ref(KindRoute, r)
+	}
+	for _, r := range spec.AdditionalHttpFilters {
+		ref(KindHTTPFilter, r)
+	}
+	if spec.RBAC != nil {
+		for _, r := range spec.RBAC.AdditionalPolicies {
+			ref(KindPolicy, r)
+		}
+	}
+	if spec.TlsConfig != nil {
+		ref(KindSecret, spec.TlsConfig.SecretRef)
+		ref(KindSecret, spec.TlsConfig.ValidationContextRef)
+		if spec.TlsConfig.SecretRef == nil && spec.TlsConfig.AutoDiscovery != nil && spec.VirtualHost != nil {
+			refs = append(refs, s.autoDiscoveredSecretKeys(spec.VirtualHost.Raw)...)
+		}
+	}
+	if spec.VirtualHost != nil {
+		refs = append(refs, s.clusterKeys(spec.VirtualHost.Raw)...)
+	}
+	for _, hf := range spec.HTTPFilters {
+		if hf != nil {
+			refs = append(refs, s.clusterKeys(hf.Raw)...)
+			refs = append(refs, secretKeys(hf.Raw)...)
+		}
+	}
+	return refs
+}
+
+// clusterKeys returns Clusters referenced by name in the raw Envoy config.
+func (s *Store) clusterKeys(raw []byte) []ObjectKey {
+	var keys []ObjectKey
+	for _, name := range findClusterNames(raw) {
+		if cl := s.SpecClusters[name]; cl != nil {
+			keys = append(keys, ObjectKey{Kind: KindCluster, Namespace: cl.Namespace, Name: cl.Name})
+		}
+	}
+	return keys
+}
+
+// autoDiscoveredSecretKeys returns Secrets chosen by tlsConfig.autoDiscovery for domains of the raw virtual host.
+func (s *Store) autoDiscoveredSecretKeys(rawVirtualHost []byte) []ObjectKey {
+	var virtualHost struct {
+		Domains []string `json:"domains"`
+	}
+	if err := json.Unmarshal(rawVirtualHost, &virtualHost); err != nil {
+		return nil
+	}
+	var keys []ObjectKey
+	for _, domain := range virtualHost.Domains {
+		if secret, ok := s.GetDomainSecret(domain); ok {
+			keys = append(keys, ObjectKey{Kind: KindSecret, Namespace: secret.Namespace, Name: secret.Name})
+		}
+	}
+	return keys
+}
+
+func secretKeys(raw []byte) []ObjectKey {
+	var keys []ObjectKey
+	for _, nn := range findSDSSecretNames(raw) {
+		keys = append(keys, ObjectKey{Kind: KindSecret, Namespace: nn.Namespace, Name: nn.Name})
+	}
+	return keys
+}
+
+// findClusterNames returns values of cluster fields and names of weighted clusters in the raw Envoy config.
+func findClusterNames(raw []byte) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil
+	}
+	var res []string
+	var walk func(data any)
+	walk = func(data any) {
+		switch value := data.(type) {
+		case map[string]any:
+			if name, ok := value["cluster"].(string); ok {
+				res = append(res, name)
+			}
+			weighted, ok := value["weighted_clusters"].(map[string]any)
+			if !ok {
+				weighted, _ = value["weightedClusters"].(map[string]any)
+			}
+			if clusters, ok := weighted["clusters"].([]any); ok {
+				for _, cl := range clusters {
+					if c, ok := cl.(map[string]any); ok {
+						if name, ok := c["name"].(string); ok {
+							res = append(res, name)
+						}
+					}
+				}
+			}
+			for _, v := range value {
+				walk(v)
+			}
+		case []any:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(data)
+	return res
+}
+
+func referrerLess(a, b Referrer) bool {
+	if a.ObjectKey != b.ObjectKey {
+		return objectKeyLess(a.ObjectKey, b.ObjectKey)
+	}
+	if a.Via == nil || b.Via == nil {
+		return a.Via == nil && b.Via != nil
+	}
+	return objectKeyLess(*a.Via, *b.Via)
+}
+
+func objectKeyLess(a, b ObjectKey) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestUpdateReferences(t *testing.T) {
+	s := New()
+	autoDiscovery := true
+	s.VirtualServiceTemplates[helpers.NamespacedName{Namespace: "ns", Name: "tmpl"}] = &v1alpha1.VirtualServiceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tmpl"},
+		Spec: v1alpha1.VirtualServiceTemplateSpec{VirtualServiceCommonSpec: v1alpha1.VirtualServiceCommonSpec{
+			Listener:         &v1alpha1.ResourceRef{Name: "https"},
+			AdditionalRoutes: []*v1alpha1.ResourceRef{{Name: "r1"}},
+		}},
+	}
+	s.VirtualServices[helpers.NamespacedName{Namespace: "ns", Name: "vs1"}] = &v1alpha1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "vs1"},
+		Spec: v1alpha1.VirtualServiceSpec{
+			Template: &v1alpha1.ResourceRef{Name: "tmpl"},
+			VirtualServiceCommonSpec: v1alpha1.VirtualServiceCommonSpec{
+				AdditionalHttpFilters: []*v1alpha1.ResourceRef{{Name: "oauth"}},
+				VirtualHost:           &runtime.RawExtension{Raw: []byte(`{"domains":["www.example.com"]}`)},
+				TlsConfig:             &v1alpha1.TlsConfig{AutoDiscovery: &autoDiscovery},
+			},
+		},
+	}
+	s.Routes[helpers.NamespacedName{Namespace: "ns", Name: "r1"}] = &v1alpha1.Route{
+		Spec: []*runtime.RawExtension{{Raw: []byte(`{"route":{"weighted_clusters":{"clusters":[{"name":"backend","weight":1}]}}}`)}},
+	}
+	s.HTTPFilters[helpers.NamespacedName{Namespace: "ns", Name: "oauth"}] = &v1alpha1.HttpFilter{
+		Spec: []*runtime.RawExtension{{Raw: []byte(`{"typed_config":{"config":{"token_endpoint":{"cluster":"backend"}},"credentials":{"token_secret":{"name":"ns/oauth-token","sds_config":{"ads":{}}}}}}`)}},
+	}
+	s.SpecClusters["backend"] = &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "backend-cluster"}}
+	s.Secrets[helpers.NamespacedName{Namespace: "certs", Name: "wildcard"}] = &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "certs",
+		Name:        "wildcard",
+		Annotations: map[string]string{v1alpha1.AnnotationSecretDomains: "*.example.com"},
+	}}
+	s.UpdateDomainSecretsMap()
+	s.UpdateReferences()
+
+	vs := ObjectKey{Kind: KindVirtualService, Namespace: "ns", Name: "vs1"}
+	tmpl := ObjectKey{Kind: KindVirtualServiceTemplate, Namespace: "ns", Name: "tmpl"}
+	route := ObjectKey{Kind: KindRoute, Namespace: "ns", Name: "r1"}
+	httpFilter := ObjectKey{Kind: KindHTTPFilter, Namespace: "ns", Name: "oauth"}
+
+	testCases := []struct {
+		object   ObjectKey
+		expected []Referrer
+	}{
+		{
+			object:   tmpl,
+			expected: []Referrer{{ObjectKey: vs}},
+		},
+		{
+			object:   ObjectKey{Kind: KindListener, Namespace: "ns", Name: "https"},
+			expected: []Referrer{{ObjectKey: vs, Via: &tmpl}, {ObjectKey: tmpl}},
+		},
+		{
+			object:   route,
+			expected: []Referrer{{ObjectKey: vs, Via: &tmpl}, {ObjectKey: tmpl}},
+		},
+		{
+			object:   ObjectKey{Kind: KindCluster, Namespace: "ns", Name: "backend-cluster"},
+			expected: []Referrer{{ObjectKey: httpFilter}, {ObjectKey: route}, {ObjectKey: vs, Via: &httpFilter}, {ObjectKey: vs, Via: &route}},
+		},
+		{
+			object:   ObjectKey{Kind: KindSecret, Namespace: "ns", Name: "oauth-token"},
+			expected: []Referrer{{ObjectKey: httpFilter}, {ObjectKey: vs, Via: &httpFilter}},
+		},
+		{
+			// chosen by tlsConfig.autoDiscovery for the wildcard domain
+			object:   ObjectKey{Kind: KindSecret, Namespace: "certs", Name: "wildcard"},
+			expected: []Referrer{{ObjectKey: vs}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.object.Kind, func(t *testing.T) {
+			if got := s.GetReferrers(tc.object); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
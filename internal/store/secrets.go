@@ -0,0 +1,289 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const DefaultSecretLabelSelector = "envoy.kaasops.io/secret-type=sds-cached"
+
+// SecretSelector defines which Kubernetes Secrets are loaded into the store.
+// LabelSelector, Annotation and Namespaces are combined with AND and select Secrets eagerly.
+// If Referenced is set, Secrets referenced by VirtualServices are fetched on demand,
+// even if they are not matched by other rules.
+type SecretSelector struct {
+	LabelSelector labels.Selector
+	Annotation    string
+	Namespaces    []string
+	Referenced    bool
+}
+
+func DefaultSecretSelector() *SecretSelector {
+	selector, _ := labels.Parse(DefaultSecretLabelSelector)
+	return &SecretSelector{LabelSelector: selector}
+}
+
+func NewSecretSelector(labelSelector, annotation string, namespaces []string, referenced bool) (*SecretSelector, error) {
+	s := &SecretSelector{
+		Annotation: strings.TrimSpace(annotation),
+		Referenced: referenced,
+	}
+	if labelSelector = strings.TrimSpace(labelSelector); labelSelector != "" {
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret label selector %q: %w", labelSelector, err)
+		}
+		s.LabelSelector = selector
+	}
+	for _, ns := range namespaces {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			s.Namespaces = append(s.Namespaces, ns)
+		}
+	}
+	return s, nil
+}
+
+// IsEager returns true if at least one rule for eager selection is configured.
+func (s *SecretSelector) IsEager() bool {
+	return s.LabelSelector != nil || s.Annotation != "" || len(s.Namespaces) > 0
+}
+
+// Matches checks the Secret (or its metadata) against eager selection rules.
+func (s *SecretSelector) Matches(obj metav1.Object) bool {
+	if !s.IsEager() {
+		return false
+	}
+	if s.LabelSelector != nil && !s.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	if s.Annotation != "" {
+		if _, ok := obj.GetAnnotations()[s.Annotation]; !ok {
+			return false
+		}
+	}
+	if len(s.Namespaces) > 0 && !s.inNamespaces(obj.GetNamespace()) {
+		return false
+	}
+	return true
+}
+
+func (s *SecretSelector) inNamespaces(namespace string) bool {
+	for _, ns := range s.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSupportedSecret checks that the Secret can be converted to Envoy secret.
+func IsSupportedSecret(secret *v1.Secret) bool {
+	if _, ok := secret.Annotations[v1alpha1.AnnotationSecretKind]; ok {
+		return true
+	}
+	return secret.Type == v1.SecretTypeOpaque || secret.Type == v1.SecretTypeTLS || secret.Type == v1.SecretTypeBasicAuth
+}
+
+func (s *Store) listSecrets(ctx context.Context, cl client.Reader) ([]v1.Secret, error) {
+	selector := s.SecretSelector
+	if !selector.IsEager() {
+		return nil, nil
+	}
+
+	listOpts := &client.ListOptions{LabelSelector: selector.LabelSelector}
+	namespaces := selector.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var result []v1.Secret
+	for _, ns := range namespaces {
+		var secrets v1.SecretList
+		opts := *listOpts
+		opts.Namespace = ns
+		if err := cl.List(ctx, &secrets, &opts); err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets.Items {
+			if selector.Matches(&secret) && IsSupportedSecret(&secret) {
+				result = append(result, secret)
+			}
+		}
+	}
+	return result, nil
+}
+
+// SyncReferencedSecrets fetches Secrets referenced by VirtualServices which are missing in the store
+// and drops Secrets which are neither selected nor referenced anymore.
+// Secrets which are not found or not supported are fetched again only after ForgetMissingSecret.
+// Returns the set of referenced Secrets.
+func (s *Store) SyncReferencedSecrets(ctx context.Context, cl client.Reader) (map[helpers.NamespacedName]struct{}, error) {
+	if !s.SecretSelector.Referenced {
+		return nil, nil
+	}
+
+	referenced := s.GetReferencedSecrets()
+	if s.missingSecrets == nil {
+		s.missingSecrets = make(map[helpers.NamespacedName]struct{})
+	}
+
+	var errs []error
+	for nn := range referenced {
+		if _, ok := s.Secrets[nn]; ok {
+			continue
+		}
+		if _, ok := s.missingSecrets[nn]; ok {
+			continue
+		}
+		if cl == nil {
+			continue
+		}
+		var secret v1.Secret
+		if err := cl.Get(ctx, types.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				s.missingSecrets[nn] = struct{}{}
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to get secret %s: %w", nn.String(), err))
+			continue
+		}
+		if !IsSupportedSecret(&secret) {
+			s.missingSecrets[nn] = struct{}{}
+			continue
+		}
+		s.Secrets[nn] = &secret
+	}
+
+	for nn := range s.missingSecrets {
+		if _, ok := referenced[nn]; !ok {
+			delete(s.missingSecrets, nn)
+		}
+	}
+	for nn, secret := range s.Secrets {
+		if _, ok := referenced[nn]; ok {
+			continue
+		}
+		if !s.SecretSelector.Matches(secret) {
+			delete(s.Secrets, nn)
+		}
+	}
+
+	s.UpdateDomainSecretsMap()
+
+	return referenced, multierr.Combine(errs...)
+}
+
+// ForgetMissingSecret must be called when the Secret is changed, so it is fetched again if it was missing.
+func (s *Store) ForgetMissingSecret(nn helpers.NamespacedName) {
+	delete(s.missingSecrets, nn)
+}
+
+// GetReferencedSecrets returns Secrets referenced by VirtualServices (directly or via templates)
+// in tlsConfig.secretRef and in sds configs of http filters.
+func (s *Store) GetReferencedSecrets() map[helpers.NamespacedName]struct{} {
+	res := make(map[helpers.NamespacedName]struct{})
+	for _, vs := range s.VirtualServices {
+		s.addVirtualServiceSecrets(res, vs)
+	}
+	return res
+}
+
+// GetVirtualServiceSecrets returns Secrets referenced by the virtual service, the template is applied if it exists.
+func (s *Store) GetVirtualServiceSecrets(vs *v1alpha1.VirtualService) map[helpers.NamespacedName]struct{} {
+	res := make(map[helpers.NamespacedName]struct{})
+	s.addVirtualServiceSecrets(res, vs)
+	return res
+}
+
+func (s *Store) addVirtualServiceSecrets(res map[helpers.NamespacedName]struct{}, vs *v1alpha1.VirtualService) {
+	spec := vs.Spec.VirtualServiceCommonSpec
+	if vs.Spec.Template != nil {
+		vst := s.VirtualServiceTemplates[helpers.NamespacedName{Namespace: helpers.GetNamespace(vs.Spec.Template.Namespace, vs.Namespace), Name: vs.Spec.Template.Name}]
+		if vst != nil {
+			vsCopy := vs.DeepCopy()
+			if err := vsCopy.FillFromTemplate(vst, vsCopy.Spec.TemplateOptions...); err == nil {
+				spec = vsCopy.Spec.VirtualServiceCommonSpec
+			}
+		}
+	}
+
+	if spec.TlsConfig != nil {
+		for _, ref := range []*v1alpha1.ResourceRef{spec.TlsConfig.SecretRef, spec.TlsConfig.ValidationContextRef} {
+			if ref != nil {
+				res[helpers.NamespacedName{Namespace: helpers.GetNamespace(ref.Namespace, vs.Namespace), Name: ref.Name}] = struct{}{}
+			}
+		}
+	}
+
+	for _, httpFilter := range spec.HTTPFilters {
+		if httpFilter == nil {
+			continue
+		}
+		for _, nn := range findSDSSecretNames(httpFilter.Raw) {
+			res[nn] = struct{}{}
+		}
+	}
+	for _, ref := range spec.AdditionalHttpFilters {
+		hf := s.HTTPFilters[helpers.NamespacedName{Namespace: helpers.GetNamespace(ref.Namespace, vs.Namespace), Name: ref.Name}]
+		if hf == nil {
+			continue
+		}
+		for _, filter := range hf.Spec {
+			if filter == nil {
+				continue
+			}
+			for _, nn := range findSDSSecretNames(filter.Raw) {
+				res[nn] = struct{}{}
+			}
+		}
+	}
+}
+
+func findSDSSecretNames(raw []byte) []helpers.NamespacedName {
+	if len(raw) == 0 {
+		return nil
+	}
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil
+	}
+	var res []helpers.NamespacedName
+	var walk func(data any)
+	walk = func(data any) {
+		switch value := data.(type) {
+		case map[string]any:
+			_, hasSDSConfig := value["sds_config"]
+			if !hasSDSConfig {
+				_, hasSDSConfig = value["sdsConfig"]
+			}
+			if hasSDSConfig {
+				if name, ok := value["name"].(string); ok {
+					if namespace, name, err := helpers.SplitNamespacedName(name); err == nil {
+						res = append(res, helpers.NamespacedName{Namespace: namespace, Name: name})
+					}
+				}
+			}
+			for _, v := range value {
+				walk(v)
+			}
+		case []any:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(data)
+	return res
+}
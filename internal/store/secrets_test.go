@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestSecretSelectorMatches(t *testing.T) {
+	testCases := []struct {
+		name     string
+		selector *SecretSelector
+		meta     metav1.ObjectMeta
+		expected bool
+	}{
+		{
+			name:     "default selector matches label",
+			selector: DefaultSecretSelector(),
+			meta:     metav1.ObjectMeta{Namespace: "a", Labels: map[string]string{"envoy.kaasops.io/secret-type": "sds-cached"}},
+			expected: true,
+		},
+		{
+			name:     "default selector ignores other label value",
+			selector: DefaultSecretSelector(),
+			meta:     metav1.ObjectMeta{Namespace: "a", Labels: map[string]string{"envoy.kaasops.io/secret-type": "webhook"}},
+			expected: false,
+		},
+		{
+			name:     "annotation and namespace",
+			selector: &SecretSelector{Annotation: "cert-manager.io/certificate-name", Namespaces: []string{"a"}},
+			meta:     metav1.ObjectMeta{Namespace: "a", Annotations: map[string]string{"cert-manager.io/certificate-name": "x"}},
+			expected: true,
+		},
+		{
+			name:     "annotation and wrong namespace",
+			selector: &SecretSelector{Annotation: "cert-manager.io/certificate-name", Namespaces: []string{"a"}},
+			meta:     metav1.ObjectMeta{Namespace: "b", Annotations: map[string]string{"cert-manager.io/certificate-name": "x"}},
+			expected: false,
+		},
+		{
+			name:     "only referenced",
+			selector: &SecretSelector{Referenced: true},
+			meta:     metav1.ObjectMeta{Namespace: "a", Labels: map[string]string{"envoy.kaasops.io/secret-type": "sds-cached"}},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.selector.Matches(&tc.meta); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetReferencedSecrets(t *testing.T) {
+	s := New()
+	other := "other"
+	s.VirtualServices[helpers.NamespacedName{Namespace: "ns", Name: "vs1"}] = &v1alpha1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "vs1"},
+		Spec: v1alpha1.VirtualServiceSpec{VirtualServiceCommonSpec: v1alpha1.VirtualServiceCommonSpec{
+			TlsConfig:             &v1alpha1.TlsConfig{SecretRef: &v1alpha1.ResourceRef{Name: "tls"}, ValidationContextRef: &v1alpha1.ResourceRef{Name: "ca"}},
+			AdditionalHttpFilters: []*v1alpha1.ResourceRef{{Name: "oauth", Namespace: &other}},
+		}},
+	}
+	s.HTTPFilters[helpers.NamespacedName{Namespace: "other", Name: "oauth"}] = &v1alpha1.HttpFilter{
+		Spec: []*runtime.RawExtension{{Raw: []byte(`{"typed_config":{"credentials":{"token_secret":{"name":"other/oauth-token","sds_config":{"ads":{}}}}}}`)}},
+	}
+
+	referenced := s.GetReferencedSecrets()
+	for _, nn := range []helpers.NamespacedName{{Namespace: "ns", Name: "tls"}, {Namespace: "ns", Name: "ca"}, {Namespace: "other", Name: "oauth-token"}} {
+		if _, ok := referenced[nn]; !ok {
+			t.Errorf("expected secret %s to be referenced", nn.String())
+		}
+	}
+	if len(referenced) != 3 {
+		t.Errorf("expected 3 referenced secrets, got %d", len(referenced))
+	}
+}
+
+func TestSyncReferencedSecretsCachesMissing(t *testing.T) {
+	s := New()
+	s.SecretSelector = &SecretSelector{Referenced: true}
+	s.VirtualServices[helpers.NamespacedName{Namespace: "ns", Name: "vs1"}] = &v1alpha1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "vs1"},
+		Spec: v1alpha1.VirtualServiceSpec{VirtualServiceCommonSpec: v1alpha1.VirtualServiceCommonSpec{
+			TlsConfig: &v1alpha1.TlsConfig{SecretRef: &v1alpha1.ResourceRef{Name: "tls"}},
+		}},
+	}
+
+	gets := 0
+	cl := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			gets++
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.SyncReferencedSecrets(ctx, cl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gets != 1 {
+		t.Errorf("missing secret must be fetched once, got %d requests", gets)
+	}
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tls"}, Type: v1.SecretTypeTLS}
+	if err := cl.Create(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	s.ForgetMissingSecret(helpers.NamespacedName{Namespace: "ns", Name: "tls"})
+	if _, err := s.SyncReferencedSecrets(ctx, cl); err != nil {
+		t.Fatal(err)
+	}
+	if gets != 2 || s.Secrets[helpers.NamespacedName{Namespace: "ns", Name: "tls"}] == nil {
+		t.Errorf("secret must be fetched after it is changed, got %d requests", gets)
+	}
+}
@@ -6,8 +6,8 @@ import (
 
 	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
 	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/secretprovider"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -21,8 +21,23 @@ type Store struct {
 	Listeners               map[helpers.NamespacedName]*v1alpha1.Listener
 	AccessLogs              map[helpers.NamespacedName]*v1alpha1.AccessLogConfig
 	Policies                map[helpers.NamespacedName]*v1alpha1.Policy
+	Runtimes                map[helpers.NamespacedName]*v1alpha1.Runtime
 	DomainToSecretMap       map[string]v1.Secret
 	Secrets                 map[helpers.NamespacedName]*v1.Secret
+	// References is the reverse index of references between objects, see UpdateReferences
+	References map[ObjectKey][]Referrer
+
+	SecretSelector *SecretSelector
+	// SecretProvider is used for lookup of secrets served over SDS.
+	// By default, only Kubernetes Secrets from the store are used.
+	SecretProvider secretprovider.Provider
+
+	// missingSecrets are referenced Secrets which are not found or not supported,
+	// they aren't fetched by SyncReferencedSecrets until the Secret is changed, see ForgetMissingSecret
+	missingSecrets map[helpers.NamespacedName]struct{}
+
+	// shared contains kinds of objects which maps are shared with another store, see View
+	shared map[string]struct{}
 }
 
 func New() *Store {
@@ -35,14 +50,25 @@ func New() *Store {
 		HTTPFilters:             make(map[helpers.NamespacedName]*v1alpha1.HttpFilter),
 		Listeners:               make(map[helpers.NamespacedName]*v1alpha1.Listener),
 		Policies:                make(map[helpers.NamespacedName]*v1alpha1.Policy),
+		Runtimes:                make(map[helpers.NamespacedName]*v1alpha1.Runtime),
 		Secrets:                 make(map[helpers.NamespacedName]*v1.Secret),
+		SecretSelector:          DefaultSecretSelector(),
 	}
+	store.SecretProvider = store.KubernetesSecretProvider()
 	store.UpdateDomainSecretsMap()
 	store.UpdateSpecClusters()
+	store.UpdateReferences()
 	return store
 }
 
-func (s *Store) Fill(ctx context.Context, cl client.Client) error {
+// KubernetesSecretProvider returns the provider of Kubernetes Secrets loaded into the store.
+func (s *Store) KubernetesSecretProvider() secretprovider.Provider {
+	return secretprovider.NewKubernetes(func() map[helpers.NamespacedName]*v1.Secret {
+		return s.Secrets
+	})
+}
+
+func (s *Store) Fill(ctx context.Context, cl client.Reader) error {
 	var accessLogConfigs v1alpha1.AccessLogConfigList
 	if err := cl.List(ctx, &accessLogConfigs); err != nil {
 		return err
@@ -75,14 +101,13 @@ func (s *Store) Fill(ctx context.Context, cl client.Client) error {
 	if err := cl.List(ctx, &policies); err != nil {
 		return err
 	}
-
-	var secrets v1.SecretList
-	requirement, err := labels.NewRequirement("envoy.kaasops.io/secret-type", "==", []string{"sds-cached"})
-	if err != nil {
+	var runtimes v1alpha1.RuntimeList
+	if err := cl.List(ctx, &runtimes); err != nil {
 		return err
 	}
-	labelSelector := labels.NewSelector().Add(*requirement)
-	if err := cl.List(ctx, &secrets, &client.ListOptions{LabelSelector: labelSelector}); err != nil {
+
+	secrets, err := s.listSecrets(ctx, cl)
+	if err != nil {
 		return err
 	}
 
@@ -94,8 +119,9 @@ func (s *Store) Fill(ctx context.Context, cl client.Client) error {
 	s.Listeners = make(map[helpers.NamespacedName]*v1alpha1.Listener, len(listeners.Items))
 	s.AccessLogs = make(map[helpers.NamespacedName]*v1alpha1.AccessLogConfig, len(accessLogConfigs.Items))
 	s.Policies = make(map[helpers.NamespacedName]*v1alpha1.Policy, len(policies.Items))
-	s.Secrets = make(map[helpers.NamespacedName]*v1.Secret, len(secrets.Items))
-	s.DomainToSecretMap = make(map[string]v1.Secret, len(secrets.Items))
+	s.Runtimes = make(map[helpers.NamespacedName]*v1alpha1.Runtime, len(runtimes.Items))
+	s.Secrets = make(map[helpers.NamespacedName]*v1.Secret, len(secrets))
+	s.DomainToSecretMap = make(map[string]v1.Secret, len(secrets))
 	s.SpecClusters = make(map[string]*v1alpha1.Cluster, len(clusters.Items))
 
 	for _, vs := range virtualServices.Items {
@@ -123,10 +149,15 @@ func (s *Store) Fill(ctx context.Context, cl client.Client) error {
 	for _, policy := range policies.Items {
 		s.Policies[helpers.NamespacedName{Namespace: policy.Namespace, Name: policy.Name}] = &policy
 	}
-	for _, secret := range secrets.Items {
+	for _, runtime := range runtimes.Items {
+		s.Runtimes[helpers.NamespacedName{Namespace: runtime.Namespace, Name: runtime.Name}] = &runtime
+	}
+	for _, secret := range secrets {
 		s.Secrets[helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = &secret
 	}
 	s.UpdateDomainSecretsMap()
+	s.UpdateReferences()
+	_, err = s.SyncReferencedSecrets(ctx, cl)
 	return err
 }
 
@@ -149,6 +180,25 @@ func (s *Store) UpdateDomainSecretsMap() {
 	s.DomainToSecretMap = m
 }
 
+// GetDomainSecret returns the Secret for the domain used by tlsConfig.autoDiscovery,
+// the Secret of the wildcard domain is used if the domain has no Secret.
+func (s *Store) GetDomainSecret(domain string) (v1.Secret, bool) {
+	if secret, ok := s.DomainToSecretMap[domain]; ok {
+		return secret, true
+	}
+	secret, ok := s.DomainToSecretMap[wildcardDomain(domain)]
+	return secret, ok
+}
+
+func wildcardDomain(domain string) string {
+	parts := strings.Split(domain, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	parts[0] = "*"
+	return strings.Join(parts, ".")
+}
+
 func (s *Store) UpdateSpecClusters() {
 	m := make(map[string]*v1alpha1.Cluster)
 
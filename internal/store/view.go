@@ -0,0 +1,77 @@
+package store
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/secretprovider"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// View returns a copy-on-write view of the store. The view shares maps and objects with the store,
+// a map is copied when an object is put into the view for the first time, so the store isn't changed by the view.
+// The store must not be changed while the view is used.
+func (s *Store) View() *Store {
+	view := *s
+	view.shared = make(map[string]struct{}, len(kinds))
+	for _, kind := range kinds {
+		view.shared[kind] = struct{}{}
+	}
+	view.shared[kindRuntime] = struct{}{}
+	return &view
+}
+
+// kindRuntime isn't indexed by references, it is used only to track maps shared by views
+const kindRuntime = "Runtime"
+
+// Put adds the object to the store or replaces the object with the same namespace and name.
+// References aren't updated, see UpdateReferences.
+func (s *Store) Put(obj client.Object) error {
+	key := helpers.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	switch o := obj.(type) {
+	case *v1alpha1.VirtualService:
+		s.VirtualServices = put(s, KindVirtualService, s.VirtualServices, key, o)
+	case *v1alpha1.VirtualServiceTemplate:
+		s.VirtualServiceTemplates = put(s, KindVirtualServiceTemplate, s.VirtualServiceTemplates, key, o)
+	case *v1alpha1.Listener:
+		s.Listeners = put(s, KindListener, s.Listeners, key, o)
+	case *v1alpha1.Route:
+		s.Routes = put(s, KindRoute, s.Routes, key, o)
+	case *v1alpha1.Cluster:
+		s.Clusters = put(s, KindCluster, s.Clusters, key, o)
+		s.UpdateSpecClusters()
+	case *v1alpha1.HttpFilter:
+		s.HTTPFilters = put(s, KindHTTPFilter, s.HTTPFilters, key, o)
+	case *v1alpha1.Policy:
+		s.Policies = put(s, KindPolicy, s.Policies, key, o)
+	case *v1alpha1.AccessLogConfig:
+		s.AccessLogs = put(s, KindAccessLogConfig, s.AccessLogs, key, o)
+	case *v1alpha1.Runtime:
+		s.Runtimes = put(s, kindRuntime, s.Runtimes, key, o)
+	case *v1.Secret:
+		if _, ok := s.shared[KindSecret]; ok {
+			// the secret provider of the store doesn't see secrets of the view, they are looked up first
+			s.SecretProvider = secretprovider.Chain{s.KubernetesSecretProvider(), s.SecretProvider}
+		}
+		s.Secrets = put(s, KindSecret, s.Secrets, key, o)
+		s.UpdateDomainSecretsMap()
+	default:
+		return fmt.Errorf("unsupported object type %T", obj)
+	}
+	return nil
+}
+
+func put[V any](s *Store, kind string, m map[helpers.NamespacedName]V, key helpers.NamespacedName, obj V) map[helpers.NamespacedName]V {
+	if _, ok := s.shared[kind]; ok || m == nil {
+		m = maps.Clone(m)
+		if m == nil {
+			m = make(map[helpers.NamespacedName]V)
+		}
+		delete(s.shared, kind)
+	}
+	m[key] = obj
+	return m
+}
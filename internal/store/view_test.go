@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestView(t *testing.T) {
+	s := New()
+	listenerKey := helpers.NamespacedName{Namespace: "ns", Name: "https"}
+	listener := &v1alpha1.Listener{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "https"}}
+	s.Listeners[listenerKey] = listener
+
+	view := s.View()
+	updated := &v1alpha1.Listener{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "https", Generation: 2}}
+	if err := view.Put(updated); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "backend"},
+		Spec:       &runtime.RawExtension{Raw: []byte(`{"name":"backend"}`)},
+	}
+	if err := view.Put(cluster); err != nil {
+		t.Fatal(err)
+	}
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tls"}, Type: v1.SecretTypeTLS}
+	if err := view.Put(secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := view.Put(&v1.ConfigMap{}); err == nil {
+		t.Error("expected error for unsupported objects")
+	}
+
+	if view.Listeners[listenerKey] != updated {
+		t.Error("view must contain the updated listener")
+	}
+	if view.SpecClusters["backend"] != cluster {
+		t.Error("spec clusters of the view must be updated")
+	}
+	if s.Listeners[listenerKey] != listener {
+		t.Error("store listener must not be changed")
+	}
+	if len(s.Clusters) != 0 || len(s.SpecClusters) != 0 {
+		t.Error("store clusters must not be changed")
+	}
+	if got, err := view.SecretProvider.GetSecret(context.Background(), "ns", "tls"); err != nil || got != secret {
+		t.Errorf("secret provider of the view must return the put secret, got %v, %v", got, err)
+	}
+	if _, err := s.SecretProvider.GetSecret(context.Background(), "ns", "tls"); err == nil {
+		t.Error("store secrets must not be changed")
+	}
+
+	// the copied map of the view is changed in place
+	second := &v1alpha1.Listener{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "http"}}
+	if err := view.Put(second); err != nil {
+		t.Fatal(err)
+	}
+	if len(view.Listeners) != 2 || len(s.Listeners) != 1 {
+		t.Errorf("expected 2 listeners in the view and 1 in the store, got %d and %d", len(view.Listeners), len(s.Listeners))
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -36,9 +38,9 @@ import (
 var accesslogconfiglog = logf.Log.WithName("accesslogconfig-resource")
 
 // SetupAccessLogConfigWebhookWithManager registers the webhook for AccessLogConfig in the manager.
-func SetupAccessLogConfigWebhookWithManager(mgr ctrl.Manager) error {
+func SetupAccessLogConfigWebhookWithManager(mgr ctrl.Manager, cacheUpdater *updater.CacheUpdater) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&envoyv1alpha1.AccessLogConfig{}).
-		WithValidator(&AccessLogConfigCustomValidator{Client: mgr.GetClient()}).
+		WithValidator(&AccessLogConfigCustomValidator{Client: mgr.GetClient(), cacheUpdater: cacheUpdater}).
 		Complete()
 }
 
@@ -55,7 +57,8 @@ func SetupAccessLogConfigWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type AccessLogConfigCustomValidator struct {
-	Client client.Client
+	Client       client.Client
+	cacheUpdater *updater.CacheUpdater
 }
 
 var _ webhook.CustomValidator = &AccessLogConfigCustomValidator{}
@@ -83,7 +86,7 @@ func (v *AccessLogConfigCustomValidator) ValidateUpdate(ctx context.Context, old
 	if _, err := accesslogconfig.UnmarshalAndValidateV3(); err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return validateDependentVirtualServices(ctx, v.cacheUpdater, accesslogconfig)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type AccessLogConfig.
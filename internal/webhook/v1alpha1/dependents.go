@@ -0,0 +1,35 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/multierr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	envoyv1alpha1 "github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+)
+
+// validateDependentVirtualServices rebuilds virtual services using the updated object and rejects the update
+// if some of them can't be built. With the skip annotation, failures are returned as warnings.
+func validateDependentVirtualServices(ctx context.Context, cacheUpdater *updater.CacheUpdater, obj client.Object) (admission.Warnings, error) {
+	// the store isn't complete until the first snapshots are built
+	if cacheUpdater == nil || !cacheUpdater.IsReady() {
+		return nil, nil
+	}
+	err := cacheUpdater.ValidateDependentVirtualServices(ctx, obj)
+	if err == nil {
+		return nil, nil
+	}
+	if obj.GetAnnotations()[envoyv1alpha1.AnnotationSkipDependentValidation] == "true" {
+		var warnings admission.Warnings
+		for _, e := range multierr.Errors(err) {
+			warnings = append(warnings, e.Error())
+		}
+		return warnings, nil
+	}
+	return nil, fmt.Errorf("update breaks virtual services, set annotation %s=true to apply it anyway: %w",
+		envoyv1alpha1.AnnotationSkipDependentValidation, err)
+}
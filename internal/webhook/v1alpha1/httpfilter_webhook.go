@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -36,9 +38,9 @@ import (
 var httpfilterlog = logf.Log.WithName("httpfilter-resource")
 
 // SetupHttpFilterWebhookWithManager registers the webhook for HttpFilter in the manager.
-func SetupHttpFilterWebhookWithManager(mgr ctrl.Manager) error {
+func SetupHttpFilterWebhookWithManager(mgr ctrl.Manager, cacheUpdater *updater.CacheUpdater) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&envoyv1alpha1.HttpFilter{}).
-		WithValidator(&HttpFilterCustomValidator{Client: mgr.GetClient()}).
+		WithValidator(&HttpFilterCustomValidator{Client: mgr.GetClient(), cacheUpdater: cacheUpdater}).
 		Complete()
 }
 
@@ -55,7 +57,8 @@ func SetupHttpFilterWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type HttpFilterCustomValidator struct {
-	Client client.Client
+	Client       client.Client
+	cacheUpdater *updater.CacheUpdater
 }
 
 var _ webhook.CustomValidator = &HttpFilterCustomValidator{}
@@ -87,7 +90,7 @@ func (v *HttpFilterCustomValidator) ValidateUpdate(ctx context.Context, oldObj,
 		return nil, err
 	}
 
-	return nil, nil
+	return validateDependentVirtualServices(ctx, v.cacheUpdater, httpfilter)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type HttpFilter.
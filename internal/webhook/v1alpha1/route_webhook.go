@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -36,9 +38,9 @@ import (
 var routelog = logf.Log.WithName("route-resource")
 
 // SetupRouteWebhookWithManager registers the webhook for Route in the manager.
-func SetupRouteWebhookWithManager(mgr ctrl.Manager) error {
+func SetupRouteWebhookWithManager(mgr ctrl.Manager, cacheUpdater *updater.CacheUpdater) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&envoyv1alpha1.Route{}).
-		WithValidator(&RouteCustomValidator{Client: mgr.GetClient()}).
+		WithValidator(&RouteCustomValidator{Client: mgr.GetClient(), cacheUpdater: cacheUpdater}).
 		Complete()
 }
 
@@ -55,7 +57,8 @@ func SetupRouteWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type RouteCustomValidator struct {
-	Client client.Client
+	Client       client.Client
+	cacheUpdater *updater.CacheUpdater
 }
 
 var _ webhook.CustomValidator = &RouteCustomValidator{}
@@ -87,7 +90,7 @@ func (v *RouteCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newOb
 		return nil, err
 	}
 
-	return nil, nil
+	return validateDependentVirtualServices(ctx, v.cacheUpdater, route)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Route.
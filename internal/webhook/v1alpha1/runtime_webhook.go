@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	envoyv1alpha1 "github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var runtimelog = logf.Log.WithName("runtime-resource")
+
+// SetupRuntimeWebhookWithManager registers the webhook for Runtime in the manager.
+func SetupRuntimeWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&envoyv1alpha1.Runtime{}).
+		WithValidator(&RuntimeCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-envoy-kaasops-io-v1alpha1-runtime,mutating=false,failurePolicy=fail,sideEffects=None,groups=envoy.kaasops.io,resources=runtimes,verbs=create;update,versions=v1alpha1,name=vruntime-v1alpha1.envoy.kaasops.io,admissionReviewVersions=v1
+
+// RuntimeCustomValidator struct is responsible for validating the Runtime resource
+// when it is created or updated.
+type RuntimeCustomValidator struct{}
+
+var _ webhook.CustomValidator = &RuntimeCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Runtime.
+func (v *RuntimeCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	rt, ok := obj.(*envoyv1alpha1.Runtime)
+	if !ok {
+		return nil, fmt.Errorf("expected a Runtime object but got %T", obj)
+	}
+	runtimelog.Info("Validation for Runtime upon creation", "name", rt.GetName())
+
+	return nil, rt.Validate()
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Runtime.
+func (v *RuntimeCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	rt, ok := newObj.(*envoyv1alpha1.Runtime)
+	if !ok {
+		return nil, fmt.Errorf("expected a Runtime object for the newObj but got %T", newObj)
+	}
+	runtimelog.Info("Validation for Runtime upon update", "name", rt.GetName())
+
+	return nil, rt.Validate()
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Runtime.
+func (v *RuntimeCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	envoyv1alpha1 "github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+)
+
+func TestValidateVirtualServiceReferencedSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := envoyv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	listener := &envoyv1alpha1.Listener{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "https"},
+		Spec: &runtime.RawExtension{Raw: []byte(`{"name":"https","address":{"socket_address":{"address":"0.0.0.0","port_value":443}},` +
+			`"listener_filters":[{"name":"envoy.filters.listener.tls_inspector","typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.listener.tls_inspector.v3.TlsInspector"}}]}`)},
+	}
+	// the secret isn't selected by labels and isn't referenced by stored virtual services
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tls"},
+		Type:       v1.SecretTypeTLS,
+		Data:       map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key")},
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(listener, secret).Build()
+
+	s := store.New()
+	s.SecretSelector = &store.SecretSelector{Referenced: true}
+	cacheUpdater := updater.NewCacheUpdater(cache.NewSnapshotCache(), s)
+	validator := &VirtualServiceCustomValidator{Client: cl, cacheUpdater: cacheUpdater}
+
+	vs := &envoyv1alpha1.VirtualService{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "web",
+		Annotations: map[string]string{envoyv1alpha1.AnnotationKeyEnvoyKaaSopsIoNodeID: "node1"},
+	}}
+	vs.Spec.Listener = &envoyv1alpha1.ResourceRef{Name: "https"}
+	vs.Spec.VirtualHost = &runtime.RawExtension{Raw: []byte(`{"name":"web","domains":["example.com"],"routes":[{"match":{"prefix":"/"},"direct_response":{"status":200}}]}`)}
+	vs.Spec.HTTPFilters = []*runtime.RawExtension{{Raw: []byte(`{"name":"envoy.filters.http.router","typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"}}`)}}
+	vs.Spec.TlsConfig = &envoyv1alpha1.TlsConfig{SecretRef: &envoyv1alpha1.ResourceRef{Name: "tls"}}
+
+	_, err := validator.ValidateCreate(context.Background(), vs)
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("expected retryable error before the cache is ready, got %v", err)
+	}
+
+	if err := cacheUpdater.Init(context.Background(), cl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := validator.ValidateCreate(context.Background(), vs); err != nil {
+		t.Errorf("virtual service referencing the secret must be valid: %v", err)
+	}
+	if len(s.Secrets) != 0 {
+		t.Error("store must not be changed by validation")
+	}
+
+	vs.Spec.TlsConfig.SecretRef.Name = "missing"
+	if _, err := validator.ValidateCreate(context.Background(), vs); err == nil {
+		t.Error("expected error for the missing secret")
+	}
+}
@@ -20,10 +20,10 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/kaasops/envoy-xds-controller/internal/store"
-	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -38,9 +38,9 @@ import (
 var virtualservicelog = logf.Log.WithName("virtualservice-resource")
 
 // SetupVirtualServiceWebhookWithManager registers the webhook for VirtualService in the manager.
-func SetupVirtualServiceWebhookWithManager(mgr ctrl.Manager) error {
+func SetupVirtualServiceWebhookWithManager(mgr ctrl.Manager, cacheUpdater *updater.CacheUpdater) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&envoyv1alpha1.VirtualService{}).
-		WithValidator(&VirtualServiceCustomValidator{Client: mgr.GetClient()}).
+		WithValidator(&VirtualServiceCustomValidator{Client: mgr.GetClient(), cacheUpdater: cacheUpdater}).
 		Complete()
 }
 
@@ -57,7 +57,8 @@ func SetupVirtualServiceWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type VirtualServiceCustomValidator struct {
-	Client client.Client
+	Client       client.Client
+	cacheUpdater *updater.CacheUpdater
 }
 
 var _ webhook.CustomValidator = &VirtualServiceCustomValidator{}
@@ -97,16 +98,12 @@ func (v *VirtualServiceCustomValidator) ValidateDelete(ctx context.Context, obj
 	return nil, nil
 }
 
+// validateVirtualService builds resources of the virtual service with the store of the cache updater.
+// Until the first snapshots are built, the store isn't complete and the request is rejected as unavailable,
+// so the API server client retries it.
 func (v *VirtualServiceCustomValidator) validateVirtualService(ctx context.Context, vs *envoyv1alpha1.VirtualService) error {
-	if len(vs.GetNodeIDs()) == 0 {
-		return fmt.Errorf("nodeIDs is required")
+	if v.cacheUpdater == nil || !v.cacheUpdater.IsReady() {
+		return apierrors.NewServiceUnavailable("controller cache is not ready yet, retry later")
 	}
-	s := store.New()
-	if err := s.Fill(ctx, v.Client); err != nil {
-		return err
-	}
-	if _, _, err := resbuilder.BuildResources(vs, s); err != nil {
-		return err
-	}
-	return nil
+	return v.cacheUpdater.ValidateVirtualService(ctx, vs)
 }
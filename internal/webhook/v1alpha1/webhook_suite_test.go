@@ -128,25 +128,28 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupAccessLogConfigWebhookWithManager(mgr)
+	err = SetupAccessLogConfigWebhookWithManager(mgr, cacheUpdater)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupListenerWebhookWithManager(mgr)
+	err = SetupListenerWebhookWithManager(mgr, cacheUpdater)
 	Expect(err).NotTo(HaveOccurred())
 
 	err = SetupClusterWebhookWithManager(mgr, cacheUpdater)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupRouteWebhookWithManager(mgr)
+	err = SetupRouteWebhookWithManager(mgr, cacheUpdater)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupPolicyWebhookWithManager(mgr)
+	err = SetupPolicyWebhookWithManager(mgr, cacheUpdater)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupHttpFilterWebhookWithManager(mgr)
+	err = SetupRuntimeWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupVirtualServiceWebhookWithManager(mgr)
+	err = SetupHttpFilterWebhookWithManager(mgr, cacheUpdater)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupVirtualServiceWebhookWithManager(mgr, cacheUpdater)
 	Expect(err).NotTo(HaveOccurred())
 
 	err = SetupVirtualServiceTemplateWebhookWithManager(mgr)
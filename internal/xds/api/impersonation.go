@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/handlers"
+)
+
+// NewImpersonatingClients returns the factory of clients impersonating users of the management API,
+// so their writes are authorized by Kubernetes RBAC. The service account of the config must be allowed to impersonate them.
+// Clients share the transport of the config, opts should contain the scheme and the REST mapper to avoid discovery per client.
+func NewImpersonatingClients(config *rest.Config, opts client.Options) (handlers.ClientFactory, error) {
+	base, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport: %w", err)
+	}
+	return func(user string, groups []string) (client.Client, error) {
+		if user == "" {
+			return nil, errors.New("user name is required for impersonation")
+		}
+		userOpts := opts
+		userOpts.HTTPClient = &http.Client{
+			Transport: transport.NewImpersonatingRoundTripper(
+				transport.ImpersonationConfig{UserName: user, Groups: groups}, base),
+			Timeout: config.Timeout,
+		}
+		return client.New(config, userOpts)
+	}, nil
+}
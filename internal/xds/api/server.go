@@ -1,9 +1,17 @@
 package api
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
 	"time"
 
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
 	ginzap "github.com/gin-contrib/zap"
 	"go.uber.org/zap"
 
@@ -14,6 +22,9 @@ import (
 	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
 
 	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/handlers"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/bootstrap"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/proxies"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
 
 	docs "github.com/kaasops/envoy-xds-controller/docs/cacheRestAPI"
 	swaggerFiles "github.com/swaggo/files"
@@ -23,11 +34,45 @@ import (
 type Config struct {
 	EnableDevMode bool
 	Auth          struct {
+		// Enabled enables authentication of OIDC ID tokens issued by IssuerURL
 		Enabled   bool
 		IssuerURL string
 		ClientID  string
-		ACL       map[string][]string
+		// TokenReview enables authentication of Kubernetes tokens with TokenReview, KubeClient and audiences or the ACL must be set
+		TokenReview          bool
+		TokenReviewAudiences []string
+		// StaticTokensSecret is the Secret with static API tokens, disabled if the name is empty. APIReader must be set
+		StaticTokensSecret types.NamespacedName
+		// ClientCert enables authentication by client certificates verified by TLS.ClientCAFile
+		ClientCert bool
+		// ACL maps groups of all authenticators to node IDs
+		ACL map[string][]string
+		// ACLConfigMap is the ConfigMap with ACL rules replacing ACL, disabled if the name is empty. APIReader must be set
+		ACLConfigMap types.NamespacedName
+	}
+	// TLS enables HTTPS if the certificate is set
+	TLS struct {
+		CertFile     string
+		KeyFile      string
+		ClientCAFile string
 	}
+	// KubeClient is used for token reviews
+	KubeClient client.Client
+	// APIReader reads the static tokens Secret and the ACL ConfigMap bypassing the cache
+	APIReader client.Reader
+	// Bootstrap contains the base options of the Envoy bootstrap served by the API, disabled if nil
+	Bootstrap *bootstrap.Options
+	// Proxies is the registry of connected proxies, disabled if nil
+	Proxies *proxies.Registry
+	// Updater is the cache updater, endpoints describing objects behind resources are disabled if nil
+	Updater *updater.CacheUpdater
+	// Debug enables the debug endpoints with the controller store and runtime profiles, an authenticator must be set
+	Debug bool
+	// Management enables the management API writing virtual services and objects they reference,
+	// UserClients, an authenticator and the ACL configmap must be set
+	Management bool
+	// UserClients return clients impersonating users of the management API
+	UserClients handlers.ClientFactory
 }
 
 type Client struct {
@@ -60,6 +105,7 @@ func (c *Client) Run(port int, cacheAPIScheme, cacheAPIAddr string) error {
 	server.Use(ginzap.RecoveryWithZap(c.logger, true))
 
 	// TODO: Fix CORS policy (don't enable for all origins)
+	// While all origins are allowed, methods changing the state aren't allowed to cross-origin requests.
 	server.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET"},
@@ -68,15 +114,37 @@ func (c *Client) Run(port int, cacheAPIScheme, cacheAPIAddr string) error {
 		MaxAge:           12 * time.Hour,
 	}))
 
-	if c.cfg.Auth.Enabled {
-		authMiddleware, err := middlewares.NewAuth(c.cfg.Auth.IssuerURL, c.cfg.Auth.ClientID, c.cfg.Auth.ACL, c.cfg.EnableDevMode)
+	authenticators, err := c.authenticators()
+	if err != nil {
+		return err
+	}
+	if len(authenticators) > 0 {
+		acl, err := c.acl()
 		if err != nil {
-			return fmt.Errorf("failed to create auth middleware: %w", err)
+			return err
 		}
+		authMiddleware := middlewares.NewAuth(authenticators, acl, c.cfg.EnableDevMode)
 		server.Use(authMiddleware.HandlerFunc)
 	}
 
-	handlers.RegisterRoutes(server, c.Cache)
+	opts := handlers.Options{
+		Bootstrap: c.cfg.Bootstrap,
+		Proxies:   c.cfg.Proxies,
+		Updater:   c.cfg.Updater,
+		Debug:     c.cfg.Debug,
+	}
+	if opts.Debug && len(authenticators) == 0 {
+		// the store, used secrets and profiles must not be open to anyone reaching the port
+		c.logger.Warn("debug endpoints are disabled, they require an authenticator")
+		opts.Debug = false
+	}
+	if c.cfg.Management {
+		if err := c.checkManagement(len(authenticators) > 0); err != nil {
+			return err
+		}
+		opts.UserClients = c.cfg.UserClients
+	}
+	handlers.RegisterRoutes(server, c.Cache, opts)
 
 	// Register swagger
 	docs.SwaggerInfo.Schemes = []string{cacheAPIScheme}
@@ -85,9 +153,90 @@ func (c *Client) Run(port int, cacheAPIScheme, cacheAPIAddr string) error {
 	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 
 	// Run server
-	if err := server.Run(fmt.Sprintf(":%d", port)); err != nil {
-		return err
+	addr := fmt.Sprintf(":%d", port)
+	if c.cfg.TLS.CertFile == "" {
+		return server.Run(addr)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.cfg.TLS.ClientCAFile != "" {
+		caData, err := os.ReadFile(c.cfg.TLS.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA: %w", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(caData) {
+			return fmt.Errorf("failed to parse client CA %s", c.cfg.TLS.ClientCAFile)
+		}
+		// clients without certificates are authenticated by other methods
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return httpServer.ListenAndServeTLS(c.cfg.TLS.CertFile, c.cfg.TLS.KeyFile)
+}
+
+// acl returns the provider of ACL rules, rules from the ConfigMap replace the static ACL.
+func (c *Client) acl() (middlewares.ACLProvider, error) {
+	if c.cfg.Auth.ACLConfigMap.Name == "" {
+		return middlewares.NewStaticACL(c.cfg.Auth.ACL), nil
+	}
+	if c.cfg.APIReader == nil {
+		return nil, errors.New("ACL configmap requires the API reader")
 	}
+	return middlewares.NewConfigMapACL(c.cfg.APIReader, c.cfg.Auth.ACLConfigMap, middlewares.DefaultACLRefreshPeriod), nil
+}
 
+// checkManagement returns an error if writes of the management API wouldn't be restricted to authenticated users
+// with ACL rules granting write access.
+func (c *Client) checkManagement(hasAuthenticators bool) error {
+	if c.cfg.UserClients == nil {
+		return errors.New("management API requires user clients")
+	}
+	if !hasAuthenticators {
+		return errors.New("management API requires an authenticator")
+	}
+	// only rules of the ACL configmap grant write access
+	if c.cfg.Auth.ACLConfigMap.Name == "" {
+		return errors.New("management API requires the ACL configmap")
+	}
 	return nil
 }
+
+// authenticators returns configured authenticators, static tokens are tried first
+// as they don't require requests to other services.
+func (c *Client) authenticators() ([]middlewares.Authenticator, error) {
+	var authenticators []middlewares.Authenticator
+	if c.cfg.Auth.ClientCert {
+		if c.cfg.TLS.ClientCAFile == "" {
+			return nil, errors.New("client certificate authentication requires the TLS client CA")
+		}
+		authenticators = append(authenticators, middlewares.ClientCertAuthenticator{})
+	}
+	if c.cfg.Auth.StaticTokensSecret.Name != "" {
+		if c.cfg.APIReader == nil {
+			return nil, errors.New("static tokens authentication requires the API reader")
+		}
+		authenticators = append(authenticators, middlewares.NewStaticTokenAuthenticator(
+			c.cfg.APIReader, c.cfg.Auth.StaticTokensSecret, middlewares.DefaultStaticTokensRefreshPeriod))
+	}
+	if c.cfg.Auth.Enabled {
+		authenticators = append(authenticators, middlewares.NewOIDCAuthenticator(
+			c.cfg.Auth.IssuerURL, c.cfg.Auth.ClientID, middlewares.DefaultOIDCRetryInterval))
+	}
+	if c.cfg.Auth.TokenReview {
+		if c.cfg.KubeClient == nil {
+			return nil, errors.New("token review authentication requires the Kubernetes client")
+		}
+		// any token of the cluster, e.g. of every service account, would be accepted with access to all nodes
+		if len(c.cfg.Auth.TokenReviewAudiences) == 0 && len(c.cfg.Auth.ACL) == 0 && c.cfg.Auth.ACLConfigMap.Name == "" {
+			return nil, errors.New("token review authentication requires audiences or the ACL")
+		}
+		authenticators = append(authenticators, middlewares.NewTokenReviewAuthenticator(
+			c.cfg.KubeClient, c.cfg.Auth.TokenReviewAudiences, middlewares.DefaultTokenReviewCacheTTL))
+	}
+	return authenticators, nil
+}
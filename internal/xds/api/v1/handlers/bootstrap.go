@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/bootstrap"
+)
+
+// getBootstrap generates the Envoy bootstrap for a specific node ID.
+// @Summary Get Envoy bootstrap for a specific node ID.
+// @Description The node ID doesn't have to exist in xDS cache, so the bootstrap can be generated before the first Envoy connects.
+// @Tags bootstrap
+// @Accept json
+// @Produce plain
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Param node_cluster query string false "Node cluster" format(string) example("edge") required(false) allowEmptyValue(true)
+// @Param admin_port query integer false "Port of the admin interface, disabled if not set" example(9901) required(false)
+// @Param statsd_address query string false "Address of the statsd sink" format(string) example("127.0.0.1:8125") required(false)
+// @Param max_heap_size_bytes query integer false "Max heap size for the overload manager, disabled if not set" example(1073741824) required(false)
+// @Param runtime_layer query string false "Name of the RTDS runtime layer" format(string) example("rtds") required(false)
+// @Param delta_ads query boolean false "Use incremental ADS, required for listeners with VHDS" required(false)
+// @Param format query string false "Output format" Enums(yaml, json) required(false)
+// @Success 200 {string} string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/bootstrap [get]
+func (h *handler) getBootstrap(ctx *gin.Context) {
+	if h.bootstrap == nil {
+		ctx.JSON(404, gin.H{"error": "bootstrap generation is not configured"})
+		return
+	}
+
+	params, err := h.getParamsForBootstrapRequests(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	nodeID := params[nodeIDParamName][0]
+	if v, exists := ctx.Get(middlewares.AvailableNodeIDs); exists {
+		if _, ok := v.(map[string]struct{})[nodeID]; !ok {
+			ctx.JSON(403, gin.H{"error": "node_id is not available", "node_id": nodeID})
+			return
+		}
+	}
+
+	opts := *h.bootstrap
+	opts.NodeID = nodeID
+	opts.NodeCluster = params[nodeClusterParamName][0]
+	opts.StatsdAddress = params[statsdAddressParamName][0]
+	opts.RuntimeLayer = params[runtimeLayerParamName][0]
+	if v := params[adminPortParamName][0]; v != "" {
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", adminPortParamName, err)})
+			return
+		}
+		opts.AdminPort = uint32(port)
+	}
+	if v := params[deltaADSParamName][0]; v != "" {
+		opts.DeltaADS, err = strconv.ParseBool(v)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", deltaADSParamName, err)})
+			return
+		}
+	}
+	if v := params[maxHeapSizeParamName][0]; v != "" {
+		opts.MaxHeapSizeBytes, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", maxHeapSizeParamName, err)})
+			return
+		}
+	}
+
+	b, err := bootstrap.Generate(opts)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch params[formatParamName][0] {
+	case "", "yaml":
+		data, err := bootstrap.MarshalYAML(b)
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.Data(200, "application/yaml", data)
+	case "json":
+		data, err := bootstrap.MarshalJSON(b)
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.Data(200, "application/json", data)
+	default:
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("unsupported %s, must be yaml or json", formatParamName)})
+	}
+}
+
+func (h *handler) getParamsForBootstrapRequests(queryValues url.Values) (map[string][]string, error) {
+	qParams := []getParam{
+		{name: nodeIDParamName, required: true, onlyOne: true},
+		{name: nodeClusterParamName, onlyOne: true},
+		{name: adminPortParamName, onlyOne: true},
+		{name: statsdAddressParamName, onlyOne: true},
+		{name: maxHeapSizeParamName, onlyOne: true},
+		{name: runtimeLayerParamName, onlyOne: true},
+		{name: deltaADSParamName, onlyOne: true},
+		{name: formatParamName, onlyOne: true},
+	}
+
+	return h.getParams(queryValues, qParams)
+}
@@ -40,7 +40,7 @@ func (h *handler) getClusters(ctx *gin.Context) {
 	var response GetClustersResponse
 
 	if params[clustersParamName][0] != "" {
-		cluster, err := h.getClusterByName(params[nodeIDParamName][0], params[clustersParamName][0])
+		cluster, err := h.getClusterByName(ctx, params[nodeIDParamName][0], params[clustersParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -51,7 +51,7 @@ func (h *handler) getClusters(ctx *gin.Context) {
 		return
 	}
 
-	clusters, err := h.getClustersAll(params[nodeIDParamName][0])
+	clusters, err := h.getClustersAll(ctx, params[nodeIDParamName][0])
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// getConfigDump retrieves the snapshot of a specific node ID in the Envoy config dump format.
+// @Summary Get the snapshot of a specific node ID as envoy.admin.v3.ConfigDump with dynamic listeners, clusters, route configurations and secrets. Resources are limited by the ACL and secrets are redacted to its level.
+// @Tags configDump
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/configDump [get]
+func (h *handler) getConfigDump(ctx *gin.Context) {
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: nodeIDParamName, required: true, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check node_id exist in cache
+	nodeID := params[nodeIDParamName][0]
+	if !slices.Contains(h.getAvailableNodeIDs(ctx), nodeID) {
+		ctx.JSON(400, gin.H{"error": "node_id not found in cache", "node_id": nodeID})
+		return
+	}
+
+	dump, err := h.cache.GetConfigDump(nodeID, h.getViewOptions(ctx, nodeID))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := protojson.MarshalOptions{UseProtoNames: true, Indent: "  "}.Marshal(dump)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Data(200, "application/json", data)
+}
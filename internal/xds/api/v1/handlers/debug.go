@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+	"strings"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+)
+
+// requireFullAccess responds 403 if the request has no access to all resources of all node IDs.
+func (h *handler) requireFullAccess(ctx *gin.Context) bool {
+	if !middlewares.HasFullAccess(ctx) {
+		ctx.JSON(403, gin.H{"error": "access to all node IDs is required"})
+		return false
+	}
+	return true
+}
+
+// getDebugStore retrieves objects of the controller store.
+// @Summary Get objects of the controller store, values of Secrets are redacted. Requires access to all node IDs
+// @Tags debug
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/debug/store [get]
+func (h *handler) getDebugStore(ctx *gin.Context) {
+	if !h.requireFullAccess(ctx) {
+		return
+	}
+	if h.updater == nil {
+		ctx.JSON(404, gin.H{"error": "store is disabled"})
+		return
+	}
+	data, err := h.updater.GetMarshaledStore()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Data(200, "application/json", data)
+}
+
+// getDebugUsedSecrets retrieves Secrets used by virtual services.
+// @Summary Get Secrets used by virtual services. Requires access to all node IDs
+// @Tags debug
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/debug/used-secrets [get]
+func (h *handler) getDebugUsedSecrets(ctx *gin.Context) {
+	if !h.requireFullAccess(ctx) {
+		return
+	}
+	if h.updater == nil {
+		ctx.JSON(404, gin.H{"error": "store is disabled"})
+		return
+	}
+	secrets := h.updater.GetUsedSecrets()
+	response := make(map[string]string, len(secrets))
+	for k, v := range secrets {
+		response[k.String()] = v.String()
+	}
+	ctx.JSON(200, response)
+}
+
+// getDebugXDS retrieves snapshots of available node IDs.
+// @Summary Get snapshots of available node IDs, limited by the ACL and with secrets redacted to its level
+// @Tags debug
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/debug/xds [get]
+func (h *handler) getDebugXDS(ctx *gin.Context) {
+	response := make(map[string]cache.ResourceSnapshot)
+	for _, nodeID := range h.getAvailableNodeIDs(ctx) {
+		snapshot, err := h.getSnapshotView(ctx, nodeID)
+		if err != nil {
+			continue
+		}
+		response[nodeID] = snapshot
+	}
+	ctx.JSON(200, response)
+}
+
+// getDebugGoroutines retrieves stacks of all goroutines.
+// @Summary Get stacks of all goroutines as text. Requires access to all node IDs
+// @Tags debug
+// @Produce plain
+// @Success 200 {string} string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/debug/goroutines [get]
+func (h *handler) getDebugGoroutines(ctx *gin.Context) {
+	if !h.requireFullAccess(ctx) {
+		return
+	}
+	ctx.Header("Content-Type", "text/plain; charset=utf-8")
+	ctx.Status(200)
+	_ = runtimepprof.Lookup("goroutine").WriteTo(ctx.Writer, 2)
+}
+
+// getDebugPprof serves runtime profiles in the format of net/http/pprof.
+// @Summary Get runtime profiles, the index of profiles without a name. Requires access to all node IDs
+// @Tags debug
+// @Produce octet-stream
+// @Param profile path string false "Profile name" example("heap")
+// @Success 200 {string} string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/debug/pprof/{profile} [get]
+func (h *handler) getDebugPprof(ctx *gin.Context) {
+	if !h.requireFullAccess(ctx) {
+		return
+	}
+	// pprof.Index resolves profiles by the /debug/pprof/ prefix, which doesn't match the API path
+	switch name := strings.TrimPrefix(ctx.Param("profile"), "/"); name {
+	case "":
+		pprof.Index(ctx.Writer, ctx.Request)
+	case "cmdline":
+		pprof.Cmdline(ctx.Writer, ctx.Request)
+	case "profile":
+		pprof.Profile(ctx.Writer, ctx.Request)
+	case "symbol":
+		pprof.Symbol(ctx.Writer, ctx.Request)
+	case "trace":
+		pprof.Trace(ctx.Writer, ctx.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
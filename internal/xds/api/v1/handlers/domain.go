@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"net/url"
 	"slices"
-	"strings"
 
 	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
@@ -12,6 +11,7 @@ import (
 	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/simulator"
 )
 
 type getDomainLocationResponse struct {
@@ -60,13 +60,13 @@ func (h *handler) getDomainLocations(ctx *gin.Context) {
 	var listeners []*listenerv3.Listener
 
 	if params[listenerParamName][0] == "" {
-		listeners, err = h.getListenersAll(params[nodeIDParamName][0])
+		listeners, err = h.getListenersAll(ctx, params[nodeIDParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
 		}
 	} else {
-		listener, err := h.getListenerByName(params[nodeIDParamName][0], params[listenerParamName][0])
+		listener, err := h.getListenerByName(ctx, params[nodeIDParamName][0], params[listenerParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -87,7 +87,7 @@ func (h *handler) getDomainLocations(ctx *gin.Context) {
 
 		location.FilterChain = filterChain.Name
 
-		filter, err := h.findFilterByDomain(params[nodeIDParamName][0], filterChain, domainName)
+		filter, err := h.findFilterByDomain(ctx, params[nodeIDParamName][0], filterChain, domainName)
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -144,13 +144,13 @@ func (h *handler) getDomains(ctx *gin.Context) {
 	var listeners []*listenerv3.Listener
 
 	if params[listenerParamName][0] == "" {
-		listeners, err = h.getListenersAll(params[nodeIDParamName][0])
+		listeners, err = h.getListenersAll(ctx, params[nodeIDParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
 		}
 	} else {
-		listener, err := h.getListenerByName(params[nodeIDParamName][0], params[listenerParamName][0])
+		listener, err := h.getListenerByName(ctx, params[nodeIDParamName][0], params[listenerParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -179,83 +179,12 @@ func (h *handler) getDomains(ctx *gin.Context) {
 // getFilterChainForDomainByServerName returns filter chain for domain by server names
 // if filter chain don't have Filter Chain Match - ignored
 func (h *handler) getFilterChainForDomainByServerName(listener *listenerv3.Listener, domain string) *listenerv3.FilterChain {
-	var lastFindServerName string
-
-	var findFilterChain *listenerv3.FilterChain
-
-	for _, filterChain := range listener.FilterChains {
-		if filterChain.FilterChainMatch == nil {
-			continue
-		}
-
-		serversNames := filterChain.FilterChainMatch.GetServerNames()
-
-		if len(serversNames) > 0 {
-			findServerName := findServerNameForDomain(serversNames, domain)
-			if findServerName == "" {
-				continue
-			}
-			if len(findServerName) >= len(lastFindServerName) {
-				findFilterChain = filterChain
-				lastFindServerName = findServerName
-			}
-		}
-	}
-
-	return findFilterChain
-}
-
-// findServerNameForDomain checks if server name exist in server names list
-// ServerName can be wildcard (https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/listener/v3/listener_components.proto#config-listener-v3-filterchainmatch)
-func findServerNameForDomain(serverNames []string, domain string) string {
-	var findServerName string
-
-	domainParts := strings.Split(domain, ".")
-
-	// Reverse domain parts
-	for i, j := 0, len(domainParts)-1; i < j; i, j = i+1, j-1 {
-		domainParts[i], domainParts[j] = domainParts[j], domainParts[i]
-	}
-
-L1:
-	for _, serverName := range serverNames {
-		serverNameParts := strings.Split(serverName, ".")
-
-		if len(serverNameParts) > len(domainParts) {
-			continue
-		}
-
-		// Reverse server name parts
-		for i, j := 0, len(serverNameParts)-1; i < j; i, j = i+1, j-1 {
-			serverNameParts[i], serverNameParts[j] = serverNameParts[j], serverNameParts[i]
-		}
-
-		// Check if server name parts equal domain parts
-		for i, serverNamePart := range serverNameParts {
-			if serverNameParts[i] == "*" {
-				if len(serverName) >= len(findServerName) {
-					findServerName = serverName
-				}
-			}
-			if serverNamePart != domainParts[i] {
-				continue L1
-			}
-
-			if i == len(serverNameParts)-1 {
-				if len(serverName) >= len(findServerName) {
-					findServerName = serverName
-				}
-			}
-		}
-
-	}
-
-	return findServerName
+	return simulator.FilterChainByServerName(listener, domain)
 }
 
 // findFilterByDomain returns filter for domain
 // If filter not HTTP Connection Manager - ignored
-func (h *handler) findFilterByDomain(nodeid string, filterChain *listenerv3.FilterChain, domain string) (*listenerv3.Filter, error) {
+func (h *handler) findFilterByDomain(ctx *gin.Context, nodeid string, filterChain *listenerv3.FilterChain, domain string) (*listenerv3.Filter, error) {
 	for _, filter := range filterChain.Filters {
 		hcmConfig := resourcev3.GetHTTPConnectionManager(filter)
 		// Skip if filter not HttpConnectionManager
@@ -266,7 +195,7 @@ func (h *handler) findFilterByDomain(nodeid string, filterChain *listenerv3.Filt
 		switch hcmConfig.RouteSpecifier.(type) {
 		case *hcmv3.HttpConnectionManager_Rds:
 			RDSName := hcmConfig.GetRds().GetRouteConfigName()
-			routeConfigurations, err := h.getRouteConfigurationByName(nodeid, RDSName)
+			routeConfigurations, err := h.getRouteConfigurationByName(ctx, nodeid, RDSName)
 			if err != nil {
 				return nil, err
 			}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+)
+
+// eventsKeepAliveInterval is the interval of comments sent to keep idle event streams open through proxies.
+const eventsKeepAliveInterval = 30 * time.Second
+
+// getEvents streams changes of snapshots as Server-Sent Events.
+// @Summary Stream changes of snapshots as Server-Sent Events, the event name is the change type (set, clear, pin, unpin)
+// @Description The stream ends with the overflow event if the client doesn't read events fast enough and misses some of them.
+// @Tags events
+// @Produce text/event-stream
+// @Param node_id query string false "Node ID" format(string) example("node-id-1") required(false)
+// @Success 200 {object} xdscache.SnapshotEvent
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/events [get]
+func (h *handler) getEvents(ctx *gin.Context) {
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: nodeIDParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	nodeID := params[nodeIDParamName][0]
+
+	// nil if all node IDs are available
+	var availableNodeIDs map[string]struct{}
+	if v, exists := ctx.Get(middlewares.AvailableNodeIDs); exists {
+		availableNodeIDs = v.(map[string]struct{})
+	}
+	if _, ok := availableNodeIDs[nodeID]; nodeID != "" && availableNodeIDs != nil && !ok {
+		ctx.JSON(403, gin.H{"error": "node_id is not available", "node_id": nodeID})
+		return
+	}
+
+	events, cancel := h.cache.Subscribe(xdscache.DefaultEventBufferSize)
+	defer cancel()
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case event, ok := <-events:
+			if !ok {
+				// the subscription is canceled only after the stream ends, so events were missed
+				ctx.SSEvent(xdscache.EventOverflow, gin.H{"error": "events were missed, resubscribe and resync"})
+				return false
+			}
+			if nodeID != "" && event.NodeID != nodeID {
+				return true
+			}
+			if _, ok := availableNodeIDs[event.NodeID]; availableNodeIDs != nil && !ok {
+				return true
+			}
+			event.Cause = availableCause(ctx, event.Cause, event.CauseNamespace)
+			ctx.SSEvent(event.Type, availableEvent(event, middlewares.NodeAccess(ctx, event.NodeID)))
+			return true
+		}
+	})
+}
+
+// availableEvent returns the event without resource types unavailable to the access.
+func availableEvent(event xdscache.SnapshotEvent, access *middlewares.Access) xdscache.SnapshotEvent {
+	if access == nil || access.ResourceTypes == nil {
+		return event
+	}
+	changedTypes := make([]string, 0, len(event.ChangedTypes))
+	for _, typeURL := range event.ChangedTypes {
+		if access.AllowsType(typeURL) {
+			changedTypes = append(changedTypes, typeURL)
+		}
+	}
+	versions := make(map[string]string, len(event.Versions))
+	for typeURL, version := range event.Versions {
+		if access.AllowsType(typeURL) {
+			versions[typeURL] = version
+		}
+	}
+	event.ChangedTypes, event.Versions = changedTypes, versions
+	return event
+}
+
+// availableCause returns the cause of snapshot changes, empty if it is a change of an object
+// in a namespace the user can't read, so names of objects of other namespaces aren't disclosed.
+func availableCause(ctx *gin.Context, cause, namespace string) string {
+	if namespace != "" && !middlewares.CanReadNamespace(ctx, namespace) {
+		return ""
+	}
+	return cause
+}
@@ -293,7 +293,7 @@ func (h *handler) getRequestFilters(ctx *gin.Context) ([]*listenerv3.Filter, err
 		return nil, fmt.Errorf("node_id not found in cache. node_id: %v", params[nodeIDParamName][0])
 	}
 
-	listener, err := h.getListenerByName(params[nodeIDParamName][0], params[listenerParamName][0])
+	listener, err := h.getListenerByName(ctx, params[nodeIDParamName][0], params[listenerParamName][0])
 	if err != nil {
 		return nil, err
 	}
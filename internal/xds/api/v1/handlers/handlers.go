@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/bootstrap"
 	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/proxies"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // @version 1.0
@@ -13,19 +17,42 @@ import (
 
 type handler struct {
 	cache *xdscache.SnapshotCache
+	// bootstrap contains the base options of the generated Envoy bootstrap, nil if disabled
+	bootstrap *bootstrap.Options
+	proxies   *proxies.Registry
+	updater   *updater.CacheUpdater
+	// userClients return clients reading and writing objects of the management API as users, nil if disabled
+	userClients ClientFactory
+}
+
+// ClientFactory returns a client acting as the user with the groups.
+type ClientFactory func(user string, groups []string) (client.Client, error)
+
+// Options contain optional dependencies of handlers, the related endpoints respond 404 if not set.
+type Options struct {
+	Bootstrap *bootstrap.Options
+	Proxies   *proxies.Registry
+	Updater   *updater.CacheUpdater
+	// Debug enables the debug endpoints with the controller store and runtime profiles
+	Debug bool
+	// UserClients enable the management API writing virtual services and objects they reference as authenticated users
+	UserClients ClientFactory
 }
 
 var (
 	version = "/api/v1"
 )
 
-func RegisterRoutes(r *gin.Engine, cache *xdscache.SnapshotCache) {
-	h := &handler{cache: cache}
+func RegisterRoutes(r *gin.Engine, cache *xdscache.SnapshotCache, opts Options) {
+	h := &handler{cache: cache, bootstrap: opts.Bootstrap, proxies: opts.Proxies, updater: opts.Updater, userClients: opts.UserClients}
 
 	routes := r.Group(version)
 
 	routes.GET("/nodeIDs", h.getNodeIDs)
 
+	// ********** Get connected proxies **********
+	routes.GET("/proxies", h.getProxies)
+
 	// ********** Get Listeners **********
 	// Get Listeners
 	routes.GET("/listeners", h.getListeners)
@@ -58,4 +85,48 @@ func RegisterRoutes(r *gin.Engine, cache *xdscache.SnapshotCache) {
 	// ********** Get Domain info **********
 	routes.GET("/domainLocations", h.getDomainLocations)
 	routes.GET("/domains", h.getDomains)
+
+	// ********** Get Envoy config dump **********
+	routes.GET("/configDump", h.getConfigDump)
+
+	// ********** Simulate a request **********
+	routes.GET("/simulate", h.simulate)
+
+	// ********** Evaluate RBAC rules **********
+	routes.GET("/rbac", h.evaluateRBAC)
+
+	// ********** Get provenance of resources **********
+	routes.GET("/provenance", h.getProvenance)
+
+	// ********** Get referrers of objects **********
+	routes.GET("/references", h.getReferences)
+
+	// ********** Stream snapshot changes **********
+	routes.GET("/events", h.getEvents)
+
+	// ********** Snapshot history **********
+	routes.GET("/snapshots", h.getSnapshotHistory)
+	routes.GET("/snapshots/diff", h.getSnapshotDiff)
+	routes.POST("/snapshots/pin", h.pinSnapshot)
+	routes.DELETE("/snapshots/pin", h.unpinSnapshot)
+
+	// ********** Manage virtual services and objects they reference **********
+	routes.GET("/objects/:kind/:namespace/:name", h.getObject)
+	routes.POST("/objects/:kind/:namespace", h.createObject)
+	routes.PUT("/objects/:kind/:namespace/:name", h.updateObject)
+	routes.DELETE("/objects/:kind/:namespace/:name", h.deleteObject)
+
+	// ********** Get Envoy bootstrap **********
+	routes.GET("/bootstrap", h.getBootstrap)
+
+	// ********** Debug and profiling **********
+	if opts.Debug {
+		debug := routes.Group("/debug")
+		debug.GET("/store", h.getDebugStore)
+		debug.GET("/xds", h.getDebugXDS)
+		debug.GET("/used-secrets", h.getDebugUsedSecrets)
+		debug.GET("/goroutines", h.getDebugGoroutines)
+		debug.GET("/pprof/*profile", h.getDebugPprof)
+		debug.POST("/pprof/*profile", h.getDebugPprof)
+	}
 }
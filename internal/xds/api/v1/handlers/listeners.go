@@ -49,7 +49,7 @@ func (h *handler) getListeners(ctx *gin.Context) {
 		return
 	}
 
-	listeners, err := h.cache.GetListeners(params[nodeIDParamName][0])
+	listeners, err := h.getListenersAll(ctx, params[nodeIDParamName][0])
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/multierr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+)
+
+// fieldManager is the field manager of objects written by the management API, suffixed with the user name.
+const fieldManager = "envoy-xds-controller-api"
+
+// managedKinds are kinds of objects managed by the management API by their plural names.
+// Listeners and templates are shared by all virtual services and are not managed.
+var managedKinds = map[string]func() client.Object{
+	"virtualservices":  func() client.Object { return &v1alpha1.VirtualService{} },
+	"routes":           func() client.Object { return &v1alpha1.Route{} },
+	"clusters":         func() client.Object { return &v1alpha1.Cluster{} },
+	"httpfilters":      func() client.Object { return &v1alpha1.HttpFilter{} },
+	"accesslogconfigs": func() client.Object { return &v1alpha1.AccessLogConfig{} },
+	"policies":         func() client.Object { return &v1alpha1.Policy{} },
+}
+
+// ValidationCause is a problem of a field of the object.
+type ValidationCause struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ObjectResponse struct {
+	DryRun bool          `json:"dry_run"`
+	Object client.Object `json:"object"`
+}
+
+// getObject retrieves a managed object.
+// @Summary Get a virtual service or an object referenced by virtual services. Requires write access to the namespace
+// @Tags objects
+// @Produce json
+// @Param kind path string true "Kind" Enums(virtualservices, routes, clusters, httpfilters, accesslogconfigs, policies)
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Name"
+// @Success 200 {object} ObjectResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/objects/{kind}/{namespace}/{name} [get]
+func (h *handler) getObject(ctx *gin.Context) {
+	current, ok := h.getCurrentObject(ctx)
+	if !ok || !h.authorizeObject(ctx, current) {
+		return
+	}
+	ctx.JSON(200, ObjectResponse{Object: current})
+}
+
+// createObject creates a managed object.
+// @Summary Create a virtual service or an object referenced by virtual services
+// @Description Virtual services are validated by building their resources before the object is written,
+// @Description dry_run validates the object without writing it.
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param kind path string true "Kind" Enums(virtualservices, routes, clusters, httpfilters, accesslogconfigs, policies)
+// @Param namespace path string true "Namespace"
+// @Param dry_run query bool false "Validate without writing"
+// @Success 200 {object} ObjectResponse
+// @Success 201 {object} ObjectResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Router /api/v1/objects/{kind}/{namespace} [post]
+func (h *handler) createObject(ctx *gin.Context) {
+	dryRun, ok := h.getDryRun(ctx)
+	if !ok {
+		return
+	}
+	obj, ok := h.decodeObject(ctx)
+	if !ok || !h.authorizeObject(ctx, obj) || !h.validateObject(ctx, obj) {
+		return
+	}
+
+	c, ok := h.userClient(ctx)
+	if !ok {
+		return
+	}
+	opts := []client.CreateOption{fieldOwner(ctx)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := c.Create(ctx.Request.Context(), obj, opts...); err != nil {
+		kubeError(ctx, err)
+		return
+	}
+	code := 201
+	if dryRun {
+		code = 200
+	}
+	ctx.JSON(code, ObjectResponse{DryRun: dryRun, Object: obj})
+}
+
+// updateObject replaces a managed object.
+// @Summary Update a virtual service or an object referenced by virtual services
+// @Description Virtual services are validated by building their resources before the object is written,
+// @Description dry_run validates the object without writing it. The current resource version is used if it isn't set.
+// @Tags objects
+// @Accept json
+// @Produce json
+// @Param kind path string true "Kind" Enums(virtualservices, routes, clusters, httpfilters, accesslogconfigs, policies)
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Name"
+// @Param dry_run query bool false "Validate without writing"
+// @Success 200 {object} ObjectResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]interface{}
+// @Router /api/v1/objects/{kind}/{namespace}/{name} [put]
+func (h *handler) updateObject(ctx *gin.Context) {
+	dryRun, ok := h.getDryRun(ctx)
+	if !ok {
+		return
+	}
+	obj, ok := h.decodeObject(ctx)
+	if !ok {
+		return
+	}
+	current, ok := h.getCurrentObject(ctx)
+	// the user must be allowed to manage both versions, so virtual services can't be moved to other node IDs
+	if !ok || !h.authorizeObject(ctx, current) || !h.authorizeObject(ctx, obj) || !h.validateObject(ctx, obj) {
+		return
+	}
+	if obj.GetResourceVersion() == "" {
+		obj.SetResourceVersion(current.GetResourceVersion())
+	}
+
+	c, ok := h.userClient(ctx)
+	if !ok {
+		return
+	}
+	opts := []client.UpdateOption{fieldOwner(ctx)}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := c.Update(ctx.Request.Context(), obj, opts...); err != nil {
+		kubeError(ctx, err)
+		return
+	}
+	ctx.JSON(200, ObjectResponse{DryRun: dryRun, Object: obj})
+}
+
+// deleteObject deletes a managed object.
+// @Summary Delete a virtual service or an object referenced by virtual services, dry_run checks the deletion without deleting
+// @Tags objects
+// @Produce json
+// @Param kind path string true "Kind" Enums(virtualservices, routes, clusters, httpfilters, accesslogconfigs, policies)
+// @Param namespace path string true "Namespace"
+// @Param name path string true "Name"
+// @Param dry_run query bool false "Check without deleting"
+// @Success 200 {object} ObjectResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/objects/{kind}/{namespace}/{name} [delete]
+func (h *handler) deleteObject(ctx *gin.Context) {
+	dryRun, ok := h.getDryRun(ctx)
+	if !ok {
+		return
+	}
+	current, ok := h.getCurrentObject(ctx)
+	if !ok || !h.authorizeObject(ctx, current) {
+		return
+	}
+
+	c, ok := h.userClient(ctx)
+	if !ok {
+		return
+	}
+	resourceVersion := current.GetResourceVersion()
+	opts := []client.DeleteOption{client.Preconditions{ResourceVersion: &resourceVersion}}
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := c.Delete(ctx.Request.Context(), current, opts...); err != nil {
+		kubeError(ctx, err)
+		return
+	}
+	ctx.JSON(200, ObjectResponse{DryRun: dryRun, Object: current})
+}
+
+// newObject returns an empty object of the kind from the path, responds 404 if the kind isn't managed.
+func (h *handler) newObject(ctx *gin.Context) (client.Object, bool) {
+	if h.userClients == nil {
+		ctx.JSON(404, gin.H{"error": "management API is disabled"})
+		return nil, false
+	}
+	newObj, ok := managedKinds[ctx.Param(kindParamName)]
+	if !ok {
+		ctx.JSON(404, gin.H{"error": "unknown kind", "kind": ctx.Param(kindParamName)})
+		return nil, false
+	}
+	return newObj(), true
+}
+
+// decodeObject decodes the object from the request body, the namespace and name are set from the path.
+func (h *handler) decodeObject(ctx *gin.Context) (client.Object, bool) {
+	obj, ok := h.newObject(ctx)
+	if !ok {
+		return nil, false
+	}
+	decoder := json.NewDecoder(ctx.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("failed to decode object: %v", err)})
+		return nil, false
+	}
+
+	if namespace := ctx.Param(namespaceParamName); obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	} else if obj.GetNamespace() != namespace {
+		ctx.JSON(400, gin.H{"error": "namespace of the object doesn't match the path", "namespace": obj.GetNamespace()})
+		return nil, false
+	}
+	if name := ctx.Param(nameParamName); name != "" && obj.GetName() == "" {
+		obj.SetName(name)
+	} else if name != "" && obj.GetName() != name {
+		ctx.JSON(400, gin.H{"error": "name of the object doesn't match the path", "name": obj.GetName()})
+		return nil, false
+	}
+	return obj, true
+}
+
+// getCurrentObject returns the object from the path, responds 404 if it doesn't exist.
+func (h *handler) getCurrentObject(ctx *gin.Context) (client.Object, bool) {
+	obj, ok := h.newObject(ctx)
+	if !ok {
+		return nil, false
+	}
+	c, ok := h.userClient(ctx)
+	if !ok {
+		return nil, false
+	}
+	key := types.NamespacedName{Namespace: ctx.Param(namespaceParamName), Name: ctx.Param(nameParamName)}
+	if err := c.Get(ctx.Request.Context(), key, obj); err != nil {
+		kubeError(ctx, err)
+		return nil, false
+	}
+	return obj, true
+}
+
+// userClient returns the client acting as the authenticated user, so Kubernetes RBAC applies to objects
+// of all managed kinds, not only to virtual services validated with the ACL and by the admission webhook.
+func (h *handler) userClient(ctx *gin.Context) (client.Client, bool) {
+	v, exists := ctx.Get(middlewares.AuthIdentity)
+	if !exists {
+		ctx.JSON(401, gin.H{"error": "authentication is required"})
+		return nil, false
+	}
+	identity := v.(*middlewares.Identity)
+	c, err := h.userClients(identity.Name, identity.Groups)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": fmt.Sprintf("failed to create client of the user: %v", err)})
+		return nil, false
+	}
+	return c, true
+}
+
+// authorizeObject responds 403 if the user may not manage the object. Virtual services require write access
+// to all their node IDs, other objects require write access to their namespace on any node ID.
+func (h *handler) authorizeObject(ctx *gin.Context, obj client.Object) bool {
+	if vs, ok := obj.(*v1alpha1.VirtualService); ok {
+		for _, nodeID := range vs.GetNodeIDs() {
+			access := middlewares.NodeAccess(ctx, nodeID)
+			if access == nil || !access.Write || !access.AllowsNamespace(vs.Namespace) {
+				ctx.JSON(403, gin.H{"error": "no write access to node_id", "node_id": nodeID, "namespace": vs.Namespace})
+				return false
+			}
+		}
+	}
+	if !middlewares.CanWriteNamespace(ctx, obj.GetNamespace()) {
+		ctx.JSON(403, gin.H{"error": "no write access to namespace", "namespace": obj.GetNamespace()})
+		return false
+	}
+	return true
+}
+
+// validateObject builds resources of virtual services with objects of the store and responds 422 on errors.
+// Other kinds are validated by the admission webhook when they are written.
+func (h *handler) validateObject(ctx *gin.Context, obj client.Object) bool {
+	vs, ok := obj.(*v1alpha1.VirtualService)
+	if !ok || h.updater == nil {
+		return true
+	}
+	err := h.updater.ValidateVirtualService(ctx.Request.Context(), vs)
+	if err == nil {
+		return true
+	}
+	causes := make([]ValidationCause, 0)
+	for _, err := range multierr.Errors(err) {
+		causes = append(causes, ValidationCause{Field: "spec", Message: err.Error()})
+	}
+	ctx.JSON(422, gin.H{"error": "invalid virtual service", "reason": "Invalid", "causes": causes})
+	return false
+}
+
+func (h *handler) getDryRun(ctx *gin.Context) (bool, bool) {
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: dryRunParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return false, false
+	}
+	if params[dryRunParamName][0] == "" {
+		return false, true
+	}
+	dryRun, err := strconv.ParseBool(params[dryRunParamName][0])
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", dryRunParamName, err)})
+		return false, false
+	}
+	return dryRun, true
+}
+
+// fieldOwner returns the field manager with the authenticated user, so managed fields show who changed the object.
+func fieldOwner(ctx *gin.Context) client.FieldOwner {
+	manager := fieldManager
+	if v, exists := ctx.Get(middlewares.AuthIdentity); exists {
+		manager += ":" + v.(*middlewares.Identity).Name
+	}
+	// field managers are limited to 128 characters
+	if len(manager) > 128 {
+		manager = manager[:128]
+	}
+	return client.FieldOwner(manager)
+}
+
+// kubeError responds with the status of the Kubernetes API error, including causes of invalid objects.
+func kubeError(ctx *gin.Context, err error) {
+	var apiStatus apierrors.APIStatus
+	if !errors.As(err, &apiStatus) {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	status := apiStatus.Status()
+	response := gin.H{"error": status.Message, "reason": status.Reason}
+	if status.Details != nil && len(status.Details.Causes) > 0 {
+		causes := make([]ValidationCause, 0, len(status.Details.Causes))
+		for _, cause := range status.Details.Causes {
+			causes = append(causes, ValidationCause{Field: cause.Field, Message: cause.Message})
+		}
+		response["causes"] = causes
+	}
+	code := int(status.Code)
+	if code == 0 {
+		code = 500
+	}
+	ctx.JSON(code, response)
+}
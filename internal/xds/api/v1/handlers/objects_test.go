@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+)
+
+type testAuthenticator struct {
+	identity *middlewares.Identity
+}
+
+func (a testAuthenticator) Authenticate(*http.Request) (*middlewares.Identity, error) {
+	return a.identity, nil
+}
+
+func newTestVirtualService(namespace, name, nodeIDs string) *v1alpha1.VirtualService {
+	return &v1alpha1.VirtualService{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   namespace,
+		Name:        name,
+		Annotations: map[string]string{v1alpha1.AnnotationKeyEnvoyKaaSopsIoNodeID: nodeIDs},
+	}}
+}
+
+// newObjectsTestRouter returns the router of the management API for the user of team-a, who can write
+// objects of namespace a on node-a and read them on node-b. Objects are written with the fake client.
+func newObjectsTestRouter(t *testing.T, objs ...client.Object) (*gin.Engine, client.Client, *[]string) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	var users []string
+	acl := middlewares.StaticACL{
+		{Groups: []string{"team-a"}, NodeIDs: []string{"node-a"}, Namespaces: []string{"a"}, Write: true},
+		{Groups: []string{"team-a"}, NodeIDs: []string{"node-b"}, Namespaces: []string{"a"}},
+	}
+	identity := &middlewares.Identity{Name: "alice", Groups: []string{"team-a"}}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middlewares.NewAuth([]middlewares.Authenticator{testAuthenticator{identity: identity}}, acl, false).HandlerFunc)
+	RegisterRoutes(r, xdscache.NewSnapshotCache(), Options{
+		UserClients: func(user string, _ []string) (client.Client, error) {
+			users = append(users, user)
+			return cl, nil
+		},
+	})
+	return r, cl, &users
+}
+
+func doObjectRequest(t *testing.T, r *gin.Engine, method, target string, obj client.Object) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	if obj != nil {
+		if err := json.NewEncoder(&body).Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, &body))
+	return w
+}
+
+func TestObjectsAuthorization(t *testing.T) {
+	route := func(namespace string) client.Object {
+		return &v1alpha1.Route{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "api"}}
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		obj    client.Object
+		code   int
+	}{
+		{
+			name:   "create virtual service",
+			method: http.MethodPost,
+			target: "/api/v1/objects/virtualservices/a",
+			obj:    newTestVirtualService("a", "new", "node-a"),
+			code:   201,
+		},
+		{
+			name:   "create virtual service in other namespace",
+			method: http.MethodPost,
+			target: "/api/v1/objects/virtualservices/b",
+			obj:    newTestVirtualService("b", "new", "node-a"),
+			code:   403,
+		},
+		{
+			name:   "create virtual service of read only node ID",
+			method: http.MethodPost,
+			target: "/api/v1/objects/virtualservices/a",
+			obj:    newTestVirtualService("a", "new", "node-a,node-b"),
+			code:   403,
+		},
+		{
+			name:   "create virtual service of unavailable node ID",
+			method: http.MethodPost,
+			target: "/api/v1/objects/virtualservices/a",
+			obj:    newTestVirtualService("a", "new", "node-c"),
+			code:   403,
+		},
+		{
+			name:   "update virtual service",
+			method: http.MethodPut,
+			target: "/api/v1/objects/virtualservices/a/web",
+			obj:    newTestVirtualService("a", "web", "node-a"),
+			code:   200,
+		},
+		{
+			name:   "move virtual service to read only node ID",
+			method: http.MethodPut,
+			target: "/api/v1/objects/virtualservices/a/web",
+			obj:    newTestVirtualService("a", "web", "node-b"),
+			code:   403,
+		},
+		{
+			name:   "update virtual service in other namespace",
+			method: http.MethodPut,
+			target: "/api/v1/objects/virtualservices/b/web",
+			obj:    newTestVirtualService("b", "web", "node-a"),
+			code:   403,
+		},
+		{
+			name:   "delete virtual service in other namespace",
+			method: http.MethodDelete,
+			target: "/api/v1/objects/virtualservices/b/web",
+			code:   403,
+		},
+		{
+			name:   "create route",
+			method: http.MethodPost,
+			target: "/api/v1/objects/routes/a",
+			obj:    route("a"),
+			code:   201,
+		},
+		{
+			name:   "create route in other namespace",
+			method: http.MethodPost,
+			target: "/api/v1/objects/routes/b",
+			obj:    route("b"),
+			code:   403,
+		},
+		{
+			name:   "namespace of the object doesn't match the path",
+			method: http.MethodPost,
+			target: "/api/v1/objects/routes/a",
+			obj:    route("b"),
+			code:   400,
+		},
+		{
+			name:   "unknown kind",
+			method: http.MethodPost,
+			target: "/api/v1/objects/listeners/a",
+			obj:    &v1alpha1.Listener{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "https"}},
+			code:   404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, users := newObjectsTestRouter(t, newTestVirtualService("a", "web", "node-a"), newTestVirtualService("b", "web", "node-a"))
+			w := doObjectRequest(t, r, tt.method, tt.target, tt.obj)
+			if w.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			for _, user := range *users {
+				if user != "alice" {
+					t.Errorf("objects must be written as the authenticated user, got %q", user)
+				}
+			}
+		})
+	}
+}
+
+func TestObjectsDryRun(t *testing.T) {
+	r, cl, _ := newObjectsTestRouter(t, newTestVirtualService("a", "web", "node-a"))
+
+	w := doObjectRequest(t, r, http.MethodPost, "/api/v1/objects/virtualservices/a?dry_run=true", newTestVirtualService("a", "new", "node-a"))
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || !response.DryRun {
+		t.Errorf("expected dry_run in the response, got %s", w.Body.String())
+	}
+	err := cl.Get(context.Background(), types.NamespacedName{Namespace: "a", Name: "new"}, &v1alpha1.VirtualService{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("object must not be created with dry_run, got %v", err)
+	}
+
+	w = doObjectRequest(t, r, http.MethodDelete, "/api/v1/objects/virtualservices/a/web?dry_run=true", nil)
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := cl.Get(context.Background(), types.NamespacedName{Namespace: "a", Name: "web"}, &v1alpha1.VirtualService{}); err != nil {
+		t.Errorf("object must not be deleted with dry_run, got %v", err)
+	}
+
+	w = doObjectRequest(t, r, http.MethodPost, "/api/v1/objects/virtualservices/a?dry_run=maybe", newTestVirtualService("a", "new", "node-a"))
+	if w.Code != 400 {
+		t.Errorf("expected 400 for invalid dry_run, got %d", w.Code)
+	}
+}
+
+func TestObjectsKubeErrors(t *testing.T) {
+	r, _, _ := newObjectsTestRouter(t, newTestVirtualService("a", "web", "node-a"))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		obj    client.Object
+		code   int
+		reason metav1.StatusReason
+	}{
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			target: "/api/v1/objects/virtualservices/a/missing",
+			code:   404,
+			reason: metav1.StatusReasonNotFound,
+		},
+		{
+			name:   "already exists",
+			method: http.MethodPost,
+			target: "/api/v1/objects/virtualservices/a",
+			obj:    newTestVirtualService("a", "web", "node-a"),
+			code:   409,
+			reason: metav1.StatusReasonAlreadyExists,
+		},
+		{
+			name:   "conflict",
+			method: http.MethodPut,
+			target: "/api/v1/objects/virtualservices/a/web",
+			obj: func() client.Object {
+				vs := newTestVirtualService("a", "web", "node-a")
+				vs.ResourceVersion = "1"
+				return vs
+			}(),
+			code:   409,
+			reason: metav1.StatusReasonConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doObjectRequest(t, r, tt.method, tt.target, tt.obj)
+			if w.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			var response struct {
+				Reason metav1.StatusReason `json:"reason"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Reason != tt.reason {
+				t.Errorf("expected reason %s, got %s", tt.reason, response.Reason)
+			}
+		})
+	}
+}
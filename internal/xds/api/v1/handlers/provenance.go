@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
+)
+
+type ResourceProvenance struct {
+	TypeURL string `json:"type_url"`
+	Name    string `json:"name"`
+	// Origins are virtual services which produced the resource with objects it was built from
+	Origins []resbuilder.ResourceOrigin `json:"origins"`
+}
+
+type GetProvenanceResponse struct {
+	Resources []ResourceProvenance `json:"resources"`
+}
+
+// getProvenance retrieves objects which produced resources of a specific node ID.
+// @Summary Get virtual services, templates and other objects which produced resources of a specific node ID
+// @Tags provenance
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Param type_url query string false "Resource type URL" format(string) example("type.googleapis.com/envoy.config.cluster.v3.Cluster") required(false)
+// @Param resource_name query string false "Resource name" format(string) example("default/cluster-1") required(false)
+// @Success 200 {object} GetProvenanceResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/provenance [get]
+func (h *handler) getProvenance(ctx *gin.Context) {
+	if h.updater == nil {
+		ctx.JSON(404, gin.H{"error": "provenance is disabled"})
+		return
+	}
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: nodeIDParamName, required: true, onlyOne: true},
+		{name: typeURLParamName, onlyOne: true},
+		{name: resourceNameParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check node_id exist in cache
+	nodeID := params[nodeIDParamName][0]
+	if !slices.Contains(h.getAvailableNodeIDs(ctx), nodeID) {
+		ctx.JSON(400, gin.H{"error": "node_id not found in cache", "node_id": nodeID})
+		return
+	}
+
+	provenance, ok := h.updater.GetProvenance(nodeID)
+	if !ok {
+		ctx.JSON(404, gin.H{"error": "provenance of node_id not found", "node_id": nodeID})
+		return
+	}
+
+	typeURL, resourceName := params[typeURLParamName][0], params[resourceNameParamName][0]
+	include := h.getViewOptions(ctx, nodeID).Include
+	response := GetProvenanceResponse{Resources: []ResourceProvenance{}}
+	for t, resources := range provenance {
+		if typeURL != "" && t != typeURL {
+			continue
+		}
+		for name, origins := range resources {
+			if resourceName != "" && name != resourceName {
+				continue
+			}
+			if include != nil && !include(t, name) {
+				continue
+			}
+			response.Resources = append(response.Resources, ResourceProvenance{TypeURL: t, Name: name, Origins: origins})
+		}
+	}
+	sort.Slice(response.Resources, func(i, j int) bool {
+		if response.Resources[i].TypeURL != response.Resources[j].TypeURL {
+			return response.Resources[i].TypeURL < response.Resources[j].TypeURL
+		}
+		return response.Resources[i].Name < response.Resources[j].Name
+	})
+	ctx.JSON(200, response)
+}
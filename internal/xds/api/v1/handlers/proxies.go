@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/proxies"
+)
+
+type GetProxiesResponse struct {
+	Proxies []ProxyStatus `json:"proxies"`
+	// UnconsumedNodeIDs are node IDs with snapshots, but without connected proxies
+	UnconsumedNodeIDs []string `json:"unconsumed_node_ids"`
+}
+
+type ProxyStatus struct {
+	proxies.Proxy
+	// HasSnapshot is false if there is no snapshot for the node ID of the proxy
+	HasSnapshot bool `json:"has_snapshot"`
+	// Stale is true if any ACKed version of the proxy differs from the snapshot version
+	Stale bool `json:"stale"`
+}
+
+// getProxies retrieves the connected proxies.
+// @Summary Get connected proxies with ACKed versions
+// @Tags proxy
+// @Accept json
+// @Produce json
+// @Param node_id query string false "Node ID" format(string) example("node-id-1") required(false) allowEmptyValue(true)
+// @Success 200 {object} GetProxiesResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/proxies [get]
+func (h *handler) getProxies(ctx *gin.Context) {
+	if h.proxies == nil {
+		ctx.JSON(404, gin.H{"error": "proxy registry is not configured"})
+		return
+	}
+
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: nodeIDParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	nodeIDFilter := params[nodeIDParamName][0]
+
+	var availableNodeIDs map[string]struct{}
+	if v, exists := ctx.Get(middlewares.AvailableNodeIDs); exists {
+		availableNodeIDs = v.(map[string]struct{})
+	}
+
+	response := GetProxiesResponse{
+		Proxies:           []ProxyStatus{},
+		UnconsumedNodeIDs: []string{},
+	}
+	connected := make(map[string]struct{})
+	for _, proxy := range h.proxies.List() {
+		if availableNodeIDs != nil {
+			if _, ok := availableNodeIDs[proxy.NodeID]; !ok {
+				continue
+			}
+		}
+		if nodeIDFilter != "" && proxy.NodeID != nodeIDFilter {
+			continue
+		}
+		connected[proxy.NodeID] = struct{}{}
+
+		status := ProxyStatus{Proxy: proxy}
+		if snapshot, err := h.cache.GetSnapshot(proxy.NodeID); err == nil {
+			status.HasSnapshot = true
+			for typeURL, version := range proxy.AckedVersions {
+				if snapshot.GetVersion(typeURL) != version {
+					status.Stale = true
+					break
+				}
+			}
+		}
+		response.Proxies = append(response.Proxies, status)
+	}
+
+	for _, nodeID := range h.getAvailableNodeIDs(ctx) {
+		if nodeIDFilter != "" && nodeID != nodeIDFilter {
+			continue
+		}
+		if _, ok := connected[nodeID]; !ok {
+			response.UnconsumedNodeIDs = append(response.UnconsumedNodeIDs, nodeID)
+		}
+	}
+
+	ctx.JSON(200, response)
+}
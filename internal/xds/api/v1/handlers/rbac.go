@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/simulator"
+)
+
+// evaluateRBAC evaluates RBAC filters of a specific node ID for a request.
+// @Summary Evaluate RBAC rules of a specific node ID for a request. Returns the decision of each RBAC filter and the matching policy.
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Param domain_name query string true "Domain name (host) of the request" format(string) example("example.com") required(true) allowEmptyValue(false)
+// @Param listener_name query string false "Listener name, all listeners are tried if not set" format(string) example("default/https") required(false)
+// @Param sni query string false "TLS server name, the domain without port if not set" format(string) example("example.com") required(false)
+// @Param path query string false "Path with optional query string" format(string) example("/admin") required(false)
+// @Param method query string false "HTTP method" format(string) example("GET") required(false)
+// @Param header query []string false "Request header in the name:value format" collectionFormat(multi) required(false)
+// @Param source_ip query string false "Address of the downstream client" format(string) example("10.0.0.1") required(false)
+// @Param direct_remote_ip query string false "Address of the peer of the connection, source_ip if not set" format(string) example("10.0.0.1") required(false)
+// @Param destination_ip query string false "Destination address of the connection" format(string) example("10.0.0.2") required(false)
+// @Param destination_port query int false "Destination port of the connection" example(443) required(false)
+// @Param principal query string false "URI SAN, DNS SAN or subject of the client certificate" format(string) example("spiffe://cluster.local/ns/default/sa/client") required(false)
+// @Success 200 {object} simulator.RBACResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/v1/rbac [get]
+func (h *handler) evaluateRBAC(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	params, err := h.getParams(query, []getParam{
+		{name: nodeIDParamName, required: true, onlyOne: true},
+		{name: domainParamName, required: true, onlyOne: true},
+		{name: listenerParamName, onlyOne: true},
+		{name: sniParamName, onlyOne: true},
+		{name: pathParamName, onlyOne: true},
+		{name: methodParamName, onlyOne: true},
+		{name: sourceIPParamName, onlyOne: true},
+		{name: directRemoteIPParamName, onlyOne: true},
+		{name: destinationIPParamName, onlyOne: true},
+		{name: destinationPortParamName, onlyOne: true},
+		{name: principalParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check node_id exist in cache
+	nodeID := params[nodeIDParamName][0]
+	if !slices.Contains(h.getAvailableNodeIDs(ctx), nodeID) {
+		ctx.JSON(400, gin.H{"error": "node_id not found in cache", "node_id": nodeID})
+		return
+	}
+
+	param := func(name string) string {
+		if v := params[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	req := simulator.RBACRequest{
+		Request: simulator.Request{
+			Listener: param(listenerParamName),
+			Host:     params[domainParamName][0],
+			SNI:      param(sniParamName),
+			Path:     param(pathParamName),
+			Method:   param(methodParamName),
+		},
+		SourceIP:       param(sourceIPParamName),
+		DirectRemoteIP: param(directRemoteIPParamName),
+		DestinationIP:  param(destinationIPParamName),
+		Principal:      param(principalParamName),
+	}
+	if port := param(destinationPortParamName); port != "" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "invalid destination_port", "destination_port": port})
+			return
+		}
+		req.DestinationPort = uint32(p)
+	}
+	if req.Headers, err = parseHeaders(query[headerParamName]); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, err := h.getSnapshotView(ctx, nodeID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := simulator.EvaluateRBAC(snapshot, req)
+	if err != nil {
+		if errors.Is(err, simulator.ErrNoMatch) {
+			ctx.JSON(404, gin.H{"error": err.Error(), "trace": result.Trace})
+			return
+		}
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(200, result)
+}
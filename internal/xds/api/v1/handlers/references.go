@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+)
+
+type GetReferencesResponse struct {
+	Object store.ObjectKey `json:"object"`
+	// Referrers are objects using the object, directly or via the object in the via field.
+	// The namespace and the name of the via object are empty if its namespace isn't available
+	Referrers []store.Referrer `json:"referrers"`
+	// NodeIDs are node IDs with snapshots built from the object
+	NodeIDs []string `json:"node_ids"`
+}
+
+// getReferences retrieves objects referencing a specific object.
+// @Summary Get virtual services, templates and other objects referencing a specific object and affected node IDs
+// @Tags references
+// @Accept json
+// @Produce json
+// @Param kind query string true "Kind of the object" format(string) example("Cluster") required(true) allowEmptyValue(false)
+// @Param namespace query string true "Namespace of the object" format(string) example("default") required(true) allowEmptyValue(false)
+// @Param name query string true "Name of the object" format(string) example("cluster-1") required(true) allowEmptyValue(false)
+// @Success 200 {object} GetReferencesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/references [get]
+func (h *handler) getReferences(ctx *gin.Context) {
+	if h.updater == nil {
+		ctx.JSON(404, gin.H{"error": "references are disabled"})
+		return
+	}
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: kindParamName, required: true, onlyOne: true},
+		{name: namespaceParamName, required: true, onlyOne: true},
+		{name: nameParamName, required: true, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	kind, ok := store.ParseKind(params[kindParamName][0])
+	if !ok {
+		ctx.JSON(400, gin.H{"error": "unknown kind", "kind": params[kindParamName][0]})
+		return
+	}
+
+	key := store.ObjectKey{Kind: kind, Namespace: params[namespaceParamName][0], Name: params[nameParamName][0]}
+	referrers, nodeIDs := h.updater.GetReferences(key)
+
+	// only referrers of namespaces and node IDs available to the user are returned
+	availableNodeIDs := h.getAvailableNodeIDs(ctx)
+	response := GetReferencesResponse{Object: key, Referrers: make([]store.Referrer, 0, len(referrers)), NodeIDs: make([]string, 0, len(nodeIDs))}
+	for _, referrer := range referrers {
+		if !middlewares.CanReadNamespace(ctx, referrer.Namespace) {
+			continue
+		}
+		if referrer.Via != nil && !middlewares.CanReadNamespace(ctx, referrer.Via.Namespace) {
+			// the kind is kept, so the referrer isn't shown as a direct one
+			referrer.Via = &store.ObjectKey{Kind: referrer.Via.Kind}
+		}
+		response.Referrers = append(response.Referrers, referrer)
+	}
+	for _, nodeID := range nodeIDs {
+		if slices.Contains(availableNodeIDs, nodeID) {
+			response.NodeIDs = append(response.NodeIDs, nodeID)
+		}
+	}
+	ctx.JSON(200, response)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
+)
+
+func TestGetReferencesOfOtherNamespaces(t *testing.T) {
+	// the virtual service of namespace a uses the cluster of namespace a through the route of namespace b
+	s := store.New()
+	s.Clusters[helpers.NamespacedName{Namespace: "a", Name: "backend"}] = &v1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "backend"},
+		Spec:       &runtime.RawExtension{Raw: []byte(`{"name":"backend"}`)},
+	}
+	s.UpdateSpecClusters()
+	s.Routes[helpers.NamespacedName{Namespace: "b", Name: "api"}] = &v1alpha1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "api"},
+		Spec:       []*runtime.RawExtension{{Raw: []byte(`{"match":{"prefix":"/"},"route":{"cluster":"backend"}}`)}},
+	}
+	vs := newTestVirtualService("a", "web", "node-a")
+	routeNamespace := "b"
+	vs.Spec.AdditionalRoutes = []*v1alpha1.ResourceRef{{Name: "api", Namespace: &routeNamespace}}
+	s.VirtualServices[helpers.NamespacedName{Namespace: "a", Name: "web"}] = vs
+	s.UpdateReferences()
+
+	acl := middlewares.StaticACL{{Groups: []string{"team-a"}, NodeIDs: []string{"node-a"}, Namespaces: []string{"a"}}}
+	identity := &middlewares.Identity{Name: "alice", Groups: []string{"team-a"}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middlewares.NewAuth([]middlewares.Authenticator{testAuthenticator{identity: identity}}, acl, false).HandlerFunc)
+	RegisterRoutes(r, xdscache.NewSnapshotCache(), Options{Updater: updater.NewCacheUpdater(xdscache.NewSnapshotCache(), s)})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/references?kind=Cluster&namespace=a&name=backend", nil))
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response GetReferencesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := []store.Referrer{{
+		ObjectKey: store.ObjectKey{Kind: store.KindVirtualService, Namespace: "a", Name: "web"},
+		Via:       &store.ObjectKey{Kind: store.KindRoute},
+	}}
+	if !reflect.DeepEqual(response.Referrers, expected) {
+		t.Errorf("expected referrers %+v, got %+v", expected, response.Referrers)
+	}
+	if referrers := s.GetReferrers(store.ObjectKey{Kind: store.KindCluster, Namespace: "a", Name: "backend"}); len(referrers) != 2 || referrers[1].Via == nil || referrers[1].Via.Name != "api" {
+		t.Errorf("referrers of the store must not be changed, got %+v", referrers)
+	}
+}
@@ -42,7 +42,7 @@ func (h *handler) getRouteConfigurations(ctx *gin.Context) {
 
 	// If param name set, return only one route configuration
 	if params[routeConfigurationParamName][0] != "" {
-		rc, err := h.getRouteConfigurationByName(params[nodeIDParamName][0], params[routeConfigurationParamName][0])
+		rc, err := h.getRouteConfigurationByName(ctx, params[nodeIDParamName][0], params[routeConfigurationParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -53,7 +53,7 @@ func (h *handler) getRouteConfigurations(ctx *gin.Context) {
 		return
 	}
 
-	rcs, err := h.getRouteConfigurationsAll(params[nodeIDParamName][0])
+	rcs, err := h.getRouteConfigurationsAll(ctx, params[nodeIDParamName][0])
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
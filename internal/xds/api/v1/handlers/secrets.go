@@ -13,8 +13,10 @@ import (
 )
 
 const (
-	TypeTLSCertificate = "tls_certificate"
-	TypeGenericSecret  = "generic_secret"
+	TypeTLSCertificate    = "tls_certificate"
+	TypeGenericSecret     = "generic_secret"
+	TypeValidationContext = "validation_context"
+	TypeSessionTicketKeys = "session_ticket_keys"
 )
 
 type GetSecretsResponse struct {
@@ -49,7 +51,7 @@ func (h *handler) getSecrets(ctx *gin.Context) {
 
 	// If param name set, return only one route configuration
 	if params[secretParamName][0] != "" {
-		secret, err := h.getSecretByName(params[nodeIDParamName][0], params[secretParamName][0])
+		secret, err := h.getSecretByName(ctx, params[nodeIDParamName][0], params[secretParamName][0])
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -60,7 +62,7 @@ func (h *handler) getSecrets(ctx *gin.Context) {
 		return
 	}
 
-	secrets, err := h.getSecretsAll(params[nodeIDParamName][0], true)
+	secrets, err := h.getSecretsAll(ctx, params[nodeIDParamName][0])
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -92,18 +94,20 @@ func (h *handler) getParamsFoSecretRequests(queryValues url.Values) (map[string]
 }
 
 type secretResponse struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-	Type      string `json:"type"`
-	Certs     []struct {
-		SerialNumber string   `json:"serialNumber"`
-		Subject      string   `json:"subject"`
-		NotBefore    string   `json:"notBefore"`
-		NotAfter     string   `json:"notAfter"`
-		Issuer       string   `json:"issuer"`
-		Raw          string   `json:"raw"`
-		DNSNames     []string `json:"dnsNames"`
-	} `json:"certs,omitempty"`
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace"`
+	Type      string         `json:"type"`
+	Certs     []certResponse `json:"certs,omitempty"`
+}
+
+type certResponse struct {
+	SerialNumber string   `json:"serialNumber"`
+	Subject      string   `json:"subject"`
+	NotBefore    string   `json:"notBefore"`
+	NotAfter     string   `json:"notAfter"`
+	Issuer       string   `json:"issuer"`
+	Raw          string   `json:"raw"`
+	DNSNames     []string `json:"dnsNames"`
 }
 
 func (h *handler) getSecretByNamespacedName(ctx *gin.Context) {
@@ -119,7 +123,7 @@ func (h *handler) getSecretByNamespacedName(ctx *gin.Context) {
 	notFound := true
 
 	for _, nodeID := range nodeIDs {
-		secrets, err := h.getSecretsAll(nodeID, false)
+		secrets, err := h.getSecretsAll(ctx, nodeID)
 		if err != nil {
 			ctx.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -133,6 +137,16 @@ func (h *handler) getSecretByNamespacedName(ctx *gin.Context) {
 				switch secret.Type.(type) {
 				case *tlsv3.Secret_GenericSecret:
 					response.Type = TypeGenericSecret
+				case *tlsv3.Secret_SessionTicketKeys:
+					response.Type = TypeSessionTicketKeys
+				case *tlsv3.Secret_ValidationContext:
+					response.Type = TypeValidationContext
+					certs, err := parsePEM(secret.GetValidationContext().GetTrustedCa().GetInlineBytes())
+					if err != nil {
+						ctx.JSON(500, gin.H{"error": err.Error()})
+						return
+					}
+					response.Certs = append(response.Certs, makeCertsResponse(certs)...)
 				case *tlsv3.Secret_TlsCertificate:
 					response.Type = TypeTLSCertificate
 					certs, err := parsePEM(secret.GetTlsCertificate().CertificateChain.GetInlineBytes())
@@ -140,26 +154,7 @@ func (h *handler) getSecretByNamespacedName(ctx *gin.Context) {
 						ctx.JSON(500, gin.H{"error": err.Error()})
 						return
 					}
-					for _, cert := range certs {
-						response.Certs = append(response.Certs, struct {
-							SerialNumber string   `json:"serialNumber"`
-							Subject      string   `json:"subject"`
-							NotBefore    string   `json:"notBefore"`
-							NotAfter     string   `json:"notAfter"`
-							Issuer       string   `json:"issuer"`
-							Raw          string   `json:"raw"`
-							DNSNames     []string `json:"dnsNames"`
-						}{
-							SerialNumber: cert.SerialNumber.String(),
-							Subject:      cert.Subject.String(),
-							NotBefore:    cert.NotBefore.String(),
-							NotAfter:     cert.NotAfter.String(),
-							Issuer:       cert.Issuer.String(),
-							Raw:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
-							DNSNames:     cert.DNSNames,
-						},
-						)
-					}
+					response.Certs = append(response.Certs, makeCertsResponse(certs)...)
 				}
 				notFound = false
 				break
@@ -175,6 +170,22 @@ func (h *handler) getSecretByNamespacedName(ctx *gin.Context) {
 	ctx.JSON(200, response)
 }
 
+func makeCertsResponse(certs []*x509.Certificate) []certResponse {
+	res := make([]certResponse, 0, len(certs))
+	for _, cert := range certs {
+		res = append(res, certResponse{
+			SerialNumber: cert.SerialNumber.String(),
+			Subject:      cert.Subject.String(),
+			NotBefore:    cert.NotBefore.String(),
+			NotAfter:     cert.NotAfter.String(),
+			Issuer:       cert.Issuer.String(),
+			Raw:          string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+			DNSNames:     cert.DNSNames,
+		})
+	}
+	return res
+}
+
 func parsePEM(data []byte) ([]*x509.Certificate, error) {
 	certs := make([]*x509.Certificate, 0)
 	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
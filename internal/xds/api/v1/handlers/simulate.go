@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/simulator"
+)
+
+// simulate shows how a request would be routed by a specific node ID.
+// @Summary Simulate a request against the snapshot of a specific node ID. Returns the filter chain, virtual host and route matching the request, the resulting cluster, rewrites and http filters applied.
+// @Tags simulate
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Param listener_name query string false "Listener name, all listeners are tried if not set" format(string) example("default/https") required(false)
+// @Param host query string true "Host (authority) header" format(string) example("example.com") required(true) allowEmptyValue(false)
+// @Param sni query string false "TLS server name, the host without port if not set" format(string) example("example.com") required(false)
+// @Param path query string false "Path with optional query string" format(string) example("/api/v1?x=1") required(false)
+// @Param method query string false "HTTP method" format(string) example("GET") required(false)
+// @Param header query []string false "Request header in the name:value format" collectionFormat(multi) required(false)
+// @Success 200 {object} simulator.Result
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/v1/simulate [get]
+func (h *handler) simulate(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	params, err := h.getParams(query, []getParam{
+		{name: nodeIDParamName, required: true, onlyOne: true},
+		{name: listenerParamName, onlyOne: true},
+		{name: hostParamName, required: true, onlyOne: true},
+		{name: sniParamName, onlyOne: true},
+		{name: pathParamName, onlyOne: true},
+		{name: methodParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check node_id exist in cache
+	nodeID := params[nodeIDParamName][0]
+	if !slices.Contains(h.getAvailableNodeIDs(ctx), nodeID) {
+		ctx.JSON(400, gin.H{"error": "node_id not found in cache", "node_id": nodeID})
+		return
+	}
+
+	req := simulator.Request{
+		Host: params[hostParamName][0],
+	}
+	if v := params[listenerParamName]; len(v) > 0 {
+		req.Listener = v[0]
+	}
+	if v := params[sniParamName]; len(v) > 0 {
+		req.SNI = v[0]
+	}
+	if v := params[pathParamName]; len(v) > 0 {
+		req.Path = v[0]
+	}
+	if v := params[methodParamName]; len(v) > 0 {
+		req.Method = v[0]
+	}
+	if req.Headers, err = parseHeaders(query[headerParamName]); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, err := h.getSnapshotView(ctx, nodeID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := simulator.Simulate(snapshot, req)
+	if err != nil {
+		if errors.Is(err, simulator.ErrNoMatch) {
+			ctx.JSON(404, gin.H{"error": err.Error(), "trace": result.Trace})
+			return
+		}
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(200, result)
+}
+
+// parseHeaders parses request headers in the name:value format.
+func parseHeaders(values []string) (map[string]string, error) {
+	headers := make(map[string]string, len(values))
+	for _, header := range values {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("header %q must be in the name:value format", header)
+		}
+		headers[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
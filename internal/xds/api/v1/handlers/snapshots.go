@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+)
+
+type SnapshotHistoryEntry struct {
+	Revision  int64     `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+	Cause     string    `json:"cause,omitempty"`
+	// Versions are versions of resources by type URL
+	Versions map[string]string `json:"versions"`
+}
+
+type GetSnapshotHistoryResponse struct {
+	// PinnedRevision is the revision served to the node, 0 if the node is not pinned
+	PinnedRevision int64                  `json:"pinned_revision"`
+	History        []SnapshotHistoryEntry `json:"history"`
+}
+
+type GetSnapshotDiffResponse struct {
+	From  int64                   `json:"from"`
+	To    int64                   `json:"to"`
+	Diffs []xdscache.ResourceDiff `json:"diffs"`
+}
+
+// getSnapshotHistory retrieves the snapshot history for a specific node ID.
+// @Summary Get snapshot history for a specific node ID
+// @Tags snapshot
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Success 200 {object} GetSnapshotHistoryResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/snapshots [get]
+func (h *handler) getSnapshotHistory(ctx *gin.Context) {
+	nodeID, ok := h.getSnapshotNodeID(ctx)
+	if !ok {
+		return
+	}
+
+	entries, pinned := h.cache.GetHistory(nodeID)
+	opts := h.getViewOptions(ctx, nodeID)
+	response := GetSnapshotHistoryResponse{
+		PinnedRevision: pinned,
+		History:        make([]SnapshotHistoryEntry, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		response.History = append(response.History, SnapshotHistoryEntry{
+			Revision:  entry.Revision,
+			Timestamp: entry.Timestamp,
+			Cause:     availableCause(ctx, entry.Cause, entry.CauseNamespace),
+			Versions:  snapshotVersions(xdscache.NewSnapshotView(entry.Snapshot, opts)),
+		})
+	}
+	ctx.JSON(200, response)
+}
+
+// getSnapshotDiff retrieves differences of resources between two snapshots of a specific node ID.
+// @Summary Get differences of resources between two snapshots of a specific node ID
+// @Tags snapshot
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Param from query integer true "Revision of the base snapshot" example(1) required(true)
+// @Param to query integer false "Revision of the compared snapshot, the latest by default" example(2) required(false)
+// @Success 200 {object} GetSnapshotDiffResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/snapshots/diff [get]
+func (h *handler) getSnapshotDiff(ctx *gin.Context) {
+	nodeID, ok := h.getSnapshotNodeID(ctx)
+	if !ok {
+		return
+	}
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: fromRevisionParamName, required: true, onlyOne: true},
+		{name: toRevisionParamName, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := strconv.ParseInt(params[fromRevisionParamName][0], 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", fromRevisionParamName, err)})
+		return
+	}
+	var to int64
+	if v := params[toRevisionParamName][0]; v != "" {
+		if to, err = strconv.ParseInt(v, 10, 64); err != nil {
+			ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", toRevisionParamName, err)})
+			return
+		}
+	} else if entries, _ := h.cache.GetHistory(nodeID); len(entries) > 0 {
+		to = entries[len(entries)-1].Revision
+	}
+
+	fromEntry, err := h.cache.GetHistoryEntry(nodeID, from)
+	if err != nil {
+		ctx.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	toEntry, err := h.cache.GetHistoryEntry(nodeID, to)
+	if err != nil {
+		ctx.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	// resources are limited by the current access, including resources of the previous snapshots
+	opts := h.getViewOptions(ctx, nodeID)
+	diffs, err := xdscache.DiffSnapshots(xdscache.NewSnapshotView(fromEntry.Snapshot, opts), xdscache.NewSnapshotView(toEntry.Snapshot, opts))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if diffs == nil {
+		diffs = []xdscache.ResourceDiff{}
+	}
+	ctx.JSON(200, GetSnapshotDiffResponse{From: from, To: to, Diffs: diffs})
+}
+
+// pinSnapshot pins a specific node ID to a snapshot from the history.
+// @Summary Serve a snapshot from the history to a specific node ID until the pin is released
+// @Tags snapshot
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Param revision query integer true "Revision of the snapshot" example(1) required(true)
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/snapshots/pin [post]
+func (h *handler) pinSnapshot(ctx *gin.Context) {
+	nodeID, ok := h.getSnapshotNodeID(ctx)
+	if !ok {
+		return
+	}
+	if !h.requireNodeWriteAccess(ctx, nodeID) {
+		return
+	}
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: revisionParamName, required: true, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	revision, err := strconv.ParseInt(params[revisionParamName][0], 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("invalid %s: %v", revisionParamName, err)})
+		return
+	}
+
+	if err := h.cache.Pin(ctx, nodeID, revision); err != nil {
+		if errors.Is(err, xdscache.ErrSnapshotNotFound) {
+			ctx.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"node_id": nodeID, "pinned_revision": revision})
+}
+
+// unpinSnapshot releases the pin of a specific node ID.
+// @Summary Release the pin of a specific node ID and serve the latest snapshot
+// @Tags snapshot
+// @Accept json
+// @Produce json
+// @Param node_id query string true "Node ID" format(string) example("node-id-1") required(true) allowEmptyValue(false)
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/v1/snapshots/pin [delete]
+func (h *handler) unpinSnapshot(ctx *gin.Context) {
+	nodeID, ok := h.getSnapshotNodeID(ctx)
+	if !ok {
+		return
+	}
+	if !h.requireNodeWriteAccess(ctx, nodeID) {
+		return
+	}
+	if err := h.cache.Unpin(ctx, nodeID); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"node_id": nodeID})
+}
+
+// requireNodeWriteAccess responds 403 if the request may not change snapshots served to the node ID.
+func (h *handler) requireNodeWriteAccess(ctx *gin.Context, nodeID string) bool {
+	if !middlewares.CanWriteNode(ctx, nodeID) {
+		ctx.JSON(403, gin.H{"error": "write access to all resources of the node ID is required"})
+		return false
+	}
+	return true
+}
+
+// getSnapshotNodeID returns the node ID from query parameters, if it is available.
+func (h *handler) getSnapshotNodeID(ctx *gin.Context) (string, bool) {
+	params, err := h.getParams(ctx.Request.URL.Query(), []getParam{
+		{name: nodeIDParamName, required: true, onlyOne: true},
+	})
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return "", false
+	}
+	nodeID := params[nodeIDParamName][0]
+	if !slices.Contains(h.getAvailableNodeIDs(ctx), nodeID) {
+		ctx.JSON(400, gin.H{"error": "node_id not found in cache", "node_id": nodeID})
+		return "", false
+	}
+	return nodeID, true
+}
+
+func snapshotVersions(snapshot cache.ResourceSnapshot) map[string]string {
+	versions := make(map[string]string)
+	for i := types.ResponseType(0); i < types.UnknownType; i++ {
+		typeURL, err := cache.GetResponseTypeURL(i)
+		if err != nil {
+			continue
+		}
+		if len(snapshot.GetResources(typeURL)) > 0 {
+			versions[typeURL] = snapshot.GetVersion(typeURL)
+		}
+	}
+	return versions
+}
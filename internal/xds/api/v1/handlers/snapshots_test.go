@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/gin-gonic/gin"
+
+	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+)
+
+func TestSnapshotHistoryCauses(t *testing.T) {
+	c := xdscache.NewSnapshotCache()
+	causes := []struct {
+		cause     string
+		namespace string
+	}{
+		{cause: "upsert VirtualService b/web", namespace: "b"},
+		{cause: "upsert VirtualService a/web", namespace: "a"},
+		{cause: "rebuild"},
+	}
+	for i, cause := range causes {
+		version := string(rune('1' + i))
+		snapshot, err := cache.NewSnapshot(version, map[resourcev3.Type][]types.Resource{
+			resourcev3.ClusterType: {&clusterv3.Cluster{Name: "backend-" + version}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := xdscache.WithObjectCause(context.Background(), cause.cause, cause.namespace)
+		if err := c.SetSnapshot(ctx, "node-a", snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acl := middlewares.StaticACL{{Groups: []string{"team-a"}, NodeIDs: []string{"node-a"}, Namespaces: []string{"a"}}}
+	identity := &middlewares.Identity{Name: "alice", Groups: []string{"team-a"}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middlewares.NewAuth([]middlewares.Authenticator{testAuthenticator{identity: identity}}, acl, false).HandlerFunc)
+	RegisterRoutes(r, c, Options{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/snapshots?node_id=node-a", nil))
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response GetSnapshotHistoryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range response.History {
+		got = append(got, entry.Cause)
+	}
+	expected := []string{"", "upsert VirtualService a/web", "rebuild"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected causes %q, got %q", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected causes %q, got %q", expected, got)
+			break
+		}
+	}
+}
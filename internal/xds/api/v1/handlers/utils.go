@@ -5,13 +5,17 @@ import (
 	"net/url"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
 	"github.com/kaasops/envoy-xds-controller/internal/xds/api/v1/middlewares"
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/updater"
 
 	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 )
 
@@ -31,6 +35,32 @@ var (
 	clustersParamName           = "cluster_name"
 	secretParamName             = "secret_name"
 	domainParamName             = "domain_name"
+	nodeClusterParamName        = "node_cluster"
+	adminPortParamName          = "admin_port"
+	statsdAddressParamName      = "statsd_address"
+	maxHeapSizeParamName        = "max_heap_size_bytes"
+	runtimeLayerParamName       = "runtime_layer"
+	deltaADSParamName           = "delta_ads"
+	revisionParamName           = "revision"
+	fromRevisionParamName       = "from"
+	toRevisionParamName         = "to"
+	formatParamName             = "format"
+	typeURLParamName            = "type_url"
+	resourceNameParamName       = "resource_name"
+	kindParamName               = "kind"
+	namespaceParamName          = "namespace"
+	nameParamName               = "name"
+	hostParamName               = "host"
+	sniParamName                = "sni"
+	pathParamName               = "path"
+	methodParamName             = "method"
+	headerParamName             = "header"
+	sourceIPParamName           = "source_ip"
+	directRemoteIPParamName     = "direct_remote_ip"
+	destinationIPParamName      = "destination_ip"
+	destinationPortParamName    = "destination_port"
+	principalParamName          = "principal"
+	dryRunParamName             = "dry_run"
 )
 
 // ****
@@ -110,8 +140,65 @@ func (h *handler) getRequiredOnlyOneParam(queryValues url.Values, param string)
 // Methods for work xDS Cache
 // ****
 
-func (h *handler) getListenerByName(nodeID string, listenerName string) (*listenerv3.Listener, error) {
-	listeners, err := h.cache.GetListeners(nodeID)
+// getViewOptions returns options of the view of the node snapshot available to the request.
+// Resources restricted by namespaces are available if they were produced by a virtual service of the namespaces,
+// filter chains of shared listeners are available if their virtual service is in the namespaces.
+func (h *handler) getViewOptions(ctx *gin.Context, nodeID string) xdscache.ViewOptions {
+	access := middlewares.NodeAccess(ctx, nodeID)
+	if access == nil {
+		return xdscache.ViewOptions{
+			Include:         func(string, string) bool { return false },
+			SecretRedaction: xdscache.SecretRedactionFull,
+		}
+	}
+	opts := xdscache.ViewOptions{SecretRedaction: access.SecretRedaction}
+	if access.Namespaces == nil && access.ResourceTypes == nil {
+		return opts
+	}
+	var provenance updater.NodeProvenance
+	if access.Namespaces != nil && h.updater != nil {
+		provenance, _ = h.updater.GetProvenance(nodeID)
+	}
+	opts.Include = func(typeURL, name string) bool {
+		if !access.AllowsType(typeURL) {
+			return false
+		}
+		if access.Namespaces == nil {
+			return true
+		}
+		for _, origin := range provenance[typeURL][name] {
+			if access.AllowsNamespace(origin.VirtualService.Namespace) {
+				return true
+			}
+		}
+		return false
+	}
+	if access.Namespaces != nil {
+		// filter chains are named after their virtual services
+		opts.IncludeFilterChain = func(listener, filterChain string) bool {
+			for _, origin := range provenance[resourcev3.ListenerType][listener] {
+				vs := helpers.NamespacedName{Namespace: origin.VirtualService.Namespace, Name: origin.VirtualService.Name}
+				if vs.String() == filterChain && access.AllowsNamespace(vs.Namespace) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return opts
+}
+
+// getSnapshotView returns the view of the node snapshot available to the request.
+func (h *handler) getSnapshotView(ctx *gin.Context, nodeID string) (cache.ResourceSnapshot, error) {
+	snapshot, err := h.cache.GetSnapshot(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return xdscache.NewSnapshotView(snapshot, h.getViewOptions(ctx, nodeID)), nil
+}
+
+func (h *handler) getListenerByName(ctx *gin.Context, nodeID string, listenerName string) (*listenerv3.Listener, error) {
+	listeners, err := h.getListenersAll(ctx, nodeID)
 	if err != nil {
 		return nil, err
 	}
@@ -126,13 +213,24 @@ func (h *handler) getListenerByName(nodeID string, listenerName string) (*listen
 	return nil, fmt.Errorf("listener %v not found", listenerName)
 }
 
-func (h *handler) getListenersAll(nodeID string) ([]*listenerv3.Listener, error) {
-	return h.cache.GetListeners(nodeID)
+func (h *handler) getListenersAll(ctx *gin.Context, nodeID string) ([]*listenerv3.Listener, error) {
+	resources, err := h.cache.GetListeners(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	opts := h.getViewOptions(ctx, nodeID)
+	listeners := make([]*listenerv3.Listener, 0, len(resources))
+	for _, listener := range resources {
+		if opts.Include == nil || opts.Include(resourcev3.ListenerType, listener.Name) {
+			listeners = append(listeners, xdscache.ListenerView(listener, opts.IncludeFilterChain))
+		}
+	}
+	return listeners, nil
 }
 
 // getRouteConfigurationByName returns route configuration by name
-func (h *handler) getRouteConfigurationByName(nodeID string, routeConfigurationName string) (*routev3.RouteConfiguration, error) {
-	resources, err := h.cache.GetRouteConfigurations(nodeID)
+func (h *handler) getRouteConfigurationByName(ctx *gin.Context, nodeID string, routeConfigurationName string) (*routev3.RouteConfiguration, error) {
+	resources, err := h.getRouteConfigurationsAll(ctx, nodeID)
 	if err != nil {
 		return nil, err
 	}
@@ -147,12 +245,23 @@ func (h *handler) getRouteConfigurationByName(nodeID string, routeConfigurationN
 	return nil, fmt.Errorf("RouteConfiguration %v not found", routeConfigurationName)
 }
 
-func (h *handler) getRouteConfigurationsAll(nodeID string) ([]*routev3.RouteConfiguration, error) {
-	return h.cache.GetRouteConfigurations(nodeID)
+func (h *handler) getRouteConfigurationsAll(ctx *gin.Context, nodeID string) ([]*routev3.RouteConfiguration, error) {
+	resources, err := h.cache.GetRouteConfigurations(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	include := h.getViewOptions(ctx, nodeID).Include
+	rcs := make([]*routev3.RouteConfiguration, 0, len(resources))
+	for _, rc := range resources {
+		if include == nil || include(resourcev3.RouteType, rc.Name) {
+			rcs = append(rcs, rc)
+		}
+	}
+	return rcs, nil
 }
 
-func (h *handler) getClusterByName(nodeID, clusterName string) (*clusterv3.Cluster, error) {
-	resources, err := h.cache.GetClusters(nodeID)
+func (h *handler) getClusterByName(ctx *gin.Context, nodeID, clusterName string) (*clusterv3.Cluster, error) {
+	resources, err := h.getClustersAll(ctx, nodeID)
 	if err != nil {
 		return nil, err
 	}
@@ -167,12 +276,23 @@ func (h *handler) getClusterByName(nodeID, clusterName string) (*clusterv3.Clust
 	return nil, fmt.Errorf("cluster %v not found", clusterName)
 }
 
-func (h *handler) getClustersAll(nodeID string) ([]*clusterv3.Cluster, error) {
-	return h.cache.GetClusters(nodeID)
+func (h *handler) getClustersAll(ctx *gin.Context, nodeID string) ([]*clusterv3.Cluster, error) {
+	resources, err := h.cache.GetClusters(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	include := h.getViewOptions(ctx, nodeID).Include
+	clusters := make([]*clusterv3.Cluster, 0, len(resources))
+	for _, cluster := range resources {
+		if include == nil || include(resourcev3.ClusterType, cluster.Name) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters, nil
 }
 
-func (h *handler) getSecretByName(nodeID, secretName string) (*tlsv3.Secret, error) {
-	secrets, err := h.cache.GetSecrets(nodeID)
+func (h *handler) getSecretByName(ctx *gin.Context, nodeID, secretName string) (*tlsv3.Secret, error) {
+	secrets, err := h.getSecretsAll(ctx, nodeID)
 	if err != nil {
 		return nil, err
 	}
@@ -181,44 +301,31 @@ func (h *handler) getSecretByName(nodeID, secretName string) (*tlsv3.Secret, err
 		if secret.Name != secretName {
 			continue
 		}
-		clearSecretData(secret)
 		return secret, nil
 	}
 
-	return nil, fmt.Errorf("cluster %v not found", secretName)
+	return nil, fmt.Errorf("secret %v not found", secretName)
 }
 
-func (h *handler) getSecretsAll(nodeID string, clearSecret bool) ([]*tlsv3.Secret, error) {
+// getSecretsAll returns secrets redacted to the level of the request access.
+func (h *handler) getSecretsAll(ctx *gin.Context, nodeID string) ([]*tlsv3.Secret, error) {
 	resources, err := h.cache.GetSecrets(nodeID)
 	if err != nil {
 		return nil, err
 	}
 
+	opts := h.getViewOptions(ctx, nodeID)
 	secrets := make([]*tlsv3.Secret, 0, len(resources))
 
 	for _, secret := range resources {
-		if clearSecret {
-			clearSecretData(secret)
+		if opts.Include == nil || opts.Include(resourcev3.SecretType, secret.Name) {
+			secrets = append(secrets, xdscache.RedactSecret(secret, opts.SecretRedaction))
 		}
-		secrets = append(secrets, secret)
 	}
 
 	return secrets, nil
 }
 
-func clearSecretData(secret *tlsv3.Secret) {
-	switch secret.Type.(type) {
-	case *tlsv3.Secret_TlsCertificate:
-		secret.Type = &tlsv3.Secret_TlsCertificate{}
-	case *tlsv3.Secret_SessionTicketKeys:
-		secret.Type = &tlsv3.Secret_SessionTicketKeys{}
-	case *tlsv3.Secret_ValidationContext:
-		secret.Type = &tlsv3.Secret_ValidationContext{}
-	case *tlsv3.Secret_GenericSecret:
-		secret.Type = &tlsv3.Secret_GenericSecret{}
-	}
-}
-
 func (h *handler) getFilterChainByName(listener *listenerv3.Listener, filterChainName string) (*listenerv3.FilterChain, error) {
 	for _, filterChain := range listener.FilterChains {
 		if filterChain.Name != filterChainName {
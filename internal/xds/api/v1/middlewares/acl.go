@@ -0,0 +1,415 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+)
+
+const (
+	// AuthAccess is the key of the *ACLAccess of the authenticated user in the request context
+	AuthAccess = "auth_access"
+
+	// ACLConfigKey is the key of the ConfigMap with ACL rules
+	ACLConfigKey = "acl.yaml"
+
+	DefaultACLRefreshPeriod = 30 * time.Second
+
+	allNodeIDs = "*"
+)
+
+// resourceTypes maps short names of resource types in ACL rules to type URLs.
+var resourceTypes = map[string]string{
+	"listeners":        resourcev3.ListenerType,
+	"clusters":         resourcev3.ClusterType,
+	"endpoints":        resourcev3.EndpointType,
+	"routes":           resourcev3.RouteType,
+	"scopedRoutes":     resourcev3.ScopedRouteType,
+	"virtualHosts":     resourcev3.VirtualHostType,
+	"secrets":          resourcev3.SecretType,
+	"runtimes":         resourcev3.RuntimeType,
+	"extensionConfigs": resourcev3.ExtensionConfigType,
+}
+
+// ACLRule grants groups access to resources of node IDs.
+type ACLRule struct {
+	Groups []string `json:"groups"`
+	// NodeIDs are available node IDs, "*" for all of them
+	NodeIDs []string `json:"nodeIds"`
+	// Namespaces restrict resources to those produced by virtual services of the namespaces, all resources if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// ResourceTypes restrict resources to the types, short names (listeners, clusters, routes, secrets...)
+	// or type URLs, all types if empty
+	ResourceTypes []string `json:"resourceTypes,omitempty"`
+	// SecretRedaction is one of none, metadata or full, metadata by default
+	SecretRedaction string `json:"secretRedaction,omitempty"`
+	// Write allows managing virtual services of the node IDs and objects of the namespaces with the management API
+	Write bool `json:"write,omitempty"`
+}
+
+// ACLConfig is the content of the acl.yaml key of the ACL ConfigMap.
+type ACLConfig struct {
+	Rules []ACLRule `json:"rules"`
+}
+
+// Validate checks node IDs, resource types and redaction levels of the rules.
+func (c *ACLConfig) Validate() error {
+	var errs []error
+	for i, rule := range c.Rules {
+		if len(rule.Groups) == 0 || len(rule.NodeIDs) == 0 {
+			errs = append(errs, fmt.Errorf("rule %d: groups and nodeIds are required", i))
+		}
+		for _, resourceType := range rule.ResourceTypes {
+			if _, err := resourceTypeURL(resourceType); err != nil {
+				errs = append(errs, fmt.Errorf("rule %d: %w", i, err))
+			}
+		}
+		switch rule.SecretRedaction {
+		case "", xdscache.SecretRedactionNone, xdscache.SecretRedactionMetadata, xdscache.SecretRedactionFull:
+		default:
+			errs = append(errs, fmt.Errorf("rule %d: unknown secret redaction %q", i, rule.SecretRedaction))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ACLProvider provides ACL rules, no rules disable the ACL.
+type ACLProvider interface {
+	Rules(ctx context.Context) ([]ACLRule, error)
+}
+
+// StaticACL is the ACL with fixed rules.
+type StaticACL []ACLRule
+
+// NewStaticACL converts groups mapped to node IDs to rules with access to all resources,
+// the ACL is disabled if the map is empty.
+func NewStaticACL(nodeIDsByGroup map[string][]string) StaticACL {
+	if len(nodeIDsByGroup) == 0 {
+		return nil
+	}
+	rules := make(StaticACL, 0, len(nodeIDsByGroup))
+	for group, nodeIDs := range nodeIDsByGroup {
+		rules = append(rules, ACLRule{Groups: []string{group}, NodeIDs: nodeIDs})
+	}
+	return rules
+}
+
+func (a StaticACL) Rules(context.Context) ([]ACLRule, error) {
+	return a, nil
+}
+
+// ConfigMapACL reads ACL rules from the acl.yaml key of a ConfigMap.
+// The ConfigMap is reread not more often than the refresh period, so rules are changed without restart.
+// The previous rules are used if the ConfigMap can't be read or is invalid.
+type ConfigMapACL struct {
+	reader        client.Reader
+	configMap     types.NamespacedName
+	refreshPeriod time.Duration
+
+	mu       sync.Mutex
+	rules    []ACLRule
+	loaded   bool
+	loadedAt time.Time
+}
+
+func NewConfigMapACL(reader client.Reader, configMap types.NamespacedName, refreshPeriod time.Duration) *ConfigMapACL {
+	if refreshPeriod <= 0 {
+		refreshPeriod = DefaultACLRefreshPeriod
+	}
+	return &ConfigMapACL{reader: reader, configMap: configMap, refreshPeriod: refreshPeriod}
+}
+
+func (a *ConfigMapACL) Rules(ctx context.Context) ([]ACLRule, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.loaded && time.Since(a.loadedAt) < a.refreshPeriod {
+		return a.rules, nil
+	}
+
+	rules, err := a.load(ctx)
+	if err != nil {
+		if a.loaded {
+			// keep the previous rules
+			return a.rules, nil
+		}
+		return nil, err
+	}
+	a.rules, a.loaded, a.loadedAt = rules, true, time.Now()
+	return a.rules, nil
+}
+
+func (a *ConfigMapACL) load(ctx context.Context) ([]ACLRule, error) {
+	var cm v1.ConfigMap
+	if err := a.reader.Get(ctx, a.configMap, &cm); err != nil {
+		return nil, fmt.Errorf("failed to get ACL configmap %s: %w", a.configMap.String(), err)
+	}
+	var cfg ACLConfig
+	if err := yaml.UnmarshalStrict([]byte(cm.Data[ACLConfigKey]), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse ACL configmap %s: %w", a.configMap.String(), err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ACL configmap %s: %w", a.configMap.String(), err)
+	}
+	// an empty list of rules must not disable the ACL
+	if cfg.Rules == nil {
+		cfg.Rules = []ACLRule{}
+	}
+	return cfg.Rules, nil
+}
+
+// Access is the access of a user to resources of a node ID.
+type Access struct {
+	// Namespaces of virtual services which produced available resources, nil if all resources are available
+	Namespaces map[string]struct{}
+	// ResourceTypes are type URLs of available resources, nil if all types are available
+	ResourceTypes   map[string]struct{}
+	SecretRedaction string
+	// Write allows managing objects of the namespaces
+	Write bool
+}
+
+// FullAccess is the access to nodes without ACL, writes require explicit grants of ACL rules.
+var FullAccess = &Access{SecretRedaction: xdscache.SecretRedactionMetadata}
+
+// AllowsType returns true if resources of the type URL are available.
+func (a *Access) AllowsType(typeURL string) bool {
+	if a.ResourceTypes == nil {
+		return true
+	}
+	_, ok := a.ResourceTypes[typeURL]
+	return ok
+}
+
+// AllowsNamespace returns true if resources produced by virtual services of the namespace are available.
+func (a *Access) AllowsNamespace(namespace string) bool {
+	if a.Namespaces == nil {
+		return true
+	}
+	_, ok := a.Namespaces[namespace]
+	return ok
+}
+
+// merge extends the access with the rule, the least restrictive redaction is used.
+func (a *Access) merge(rule ACLRule) {
+	a.Namespaces = unionSet(a.Namespaces, rule.Namespaces, func(ns string) string { return ns })
+	a.ResourceTypes = unionSet(a.ResourceTypes, rule.ResourceTypes, func(resourceType string) string {
+		typeURL, _ := resourceTypeURL(resourceType)
+		return typeURL
+	})
+	redaction := rule.SecretRedaction
+	if redaction == "" {
+		redaction = xdscache.SecretRedactionMetadata
+	}
+	if redactionLevels[redaction] < redactionLevels[a.SecretRedaction] {
+		a.SecretRedaction = redaction
+	}
+	a.Write = a.Write || rule.Write
+}
+
+// redactionLevels orders redactions from the least restrictive.
+var redactionLevels = map[string]int{
+	xdscache.SecretRedactionNone:     0,
+	xdscache.SecretRedactionMetadata: 1,
+	xdscache.SecretRedactionFull:     2,
+}
+
+// unionSet adds values to the set, nil sets and empty values mean all.
+func unionSet(set map[string]struct{}, values []string, key func(string) string) map[string]struct{} {
+	if set == nil || len(values) == 0 {
+		return nil
+	}
+	for _, v := range values {
+		set[key(v)] = struct{}{}
+	}
+	return set
+}
+
+// ACLAccess is the access of a user to resources of available node IDs.
+type ACLAccess struct {
+	nodes map[string]*Access
+	// all is the access to all node IDs, nil if not granted
+	all *Access
+}
+
+// newACLAccess merges rules matching the groups, nil if no rule matches.
+func newACLAccess(rules []ACLRule, groups []string) *ACLAccess {
+	access := &ACLAccess{nodes: make(map[string]*Access)}
+	for _, rule := range rules {
+		if !matchGroups(rule.Groups, groups) {
+			continue
+		}
+		for _, nodeID := range rule.NodeIDs {
+			if nodeID == allNodeIDs {
+				access.all = mergeAccess(access.all, rule)
+				continue
+			}
+			access.nodes[nodeID] = mergeAccess(access.nodes[nodeID], rule)
+		}
+	}
+	if access.all == nil && len(access.nodes) == 0 {
+		return nil
+	}
+	if access.all != nil {
+		// rules for all node IDs apply to each of them
+		for nodeID, a := range access.nodes {
+			access.nodes[nodeID] = mergeAccesses(a, access.all)
+		}
+	}
+	return access
+}
+
+func mergeAccess(a *Access, rule ACLRule) *Access {
+	if a == nil {
+		a = &Access{
+			Namespaces:      make(map[string]struct{}),
+			ResourceTypes:   make(map[string]struct{}),
+			SecretRedaction: xdscache.SecretRedactionFull,
+		}
+	}
+	a.merge(rule)
+	return a
+}
+
+func mergeAccesses(a, b *Access) *Access {
+	merged := &Access{SecretRedaction: a.SecretRedaction, Write: a.Write || b.Write}
+	if redactionLevels[b.SecretRedaction] < redactionLevels[merged.SecretRedaction] {
+		merged.SecretRedaction = b.SecretRedaction
+	}
+	if a.Namespaces != nil && b.Namespaces != nil {
+		merged.Namespaces = make(map[string]struct{})
+		for _, set := range []map[string]struct{}{a.Namespaces, b.Namespaces} {
+			for ns := range set {
+				merged.Namespaces[ns] = struct{}{}
+			}
+		}
+	}
+	if a.ResourceTypes != nil && b.ResourceTypes != nil {
+		merged.ResourceTypes = make(map[string]struct{})
+		for _, set := range []map[string]struct{}{a.ResourceTypes, b.ResourceTypes} {
+			for typeURL := range set {
+				merged.ResourceTypes[typeURL] = struct{}{}
+			}
+		}
+	}
+	return merged
+}
+
+// NodeIDs returns available node IDs, nil if all of them are available.
+func (a *ACLAccess) NodeIDs() map[string]struct{} {
+	if a.all != nil {
+		return nil
+	}
+	nodeIDs := make(map[string]struct{}, len(a.nodes))
+	for nodeID := range a.nodes {
+		nodeIDs[nodeID] = struct{}{}
+	}
+	return nodeIDs
+}
+
+// Node returns the access to the node ID, nil if the node ID isn't available.
+func (a *ACLAccess) Node(nodeID string) *Access {
+	if access, ok := a.nodes[nodeID]; ok {
+		return access
+	}
+	return a.all
+}
+
+// NodeAccess returns the access of the request to the node ID, FullAccess if the ACL is disabled
+// and nil if the node ID isn't available.
+func NodeAccess(c *gin.Context, nodeID string) *Access {
+	v, exists := c.Get(AuthAccess)
+	if !exists {
+		return FullAccess
+	}
+	return v.(*ACLAccess).Node(nodeID)
+}
+
+func matchGroups(ruleGroups, groups []string) bool {
+	for _, ruleGroup := range ruleGroups {
+		for _, group := range groups {
+			if ruleGroup == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func resourceTypeURL(resourceType string) (string, error) {
+	if typeURL, ok := resourceTypes[resourceType]; ok {
+		return typeURL, nil
+	}
+	for _, typeURL := range resourceTypes {
+		if typeURL == resourceType {
+			return typeURL, nil
+		}
+	}
+	return "", fmt.Errorf("unknown resource type %q", resourceType)
+}
+
+// HasFullAccess returns true if the request has access to all resources of all node IDs.
+// Without ACL only authenticated requests have it.
+func HasFullAccess(c *gin.Context) bool {
+	v, exists := c.Get(AuthAccess)
+	if !exists {
+		_, authenticated := c.Get(AuthIdentity)
+		return authenticated
+	}
+	all := v.(*ACLAccess).all
+	return all != nil && all.Namespaces == nil && all.ResourceTypes == nil
+}
+
+// CanWriteNode returns true if the request may change what the node ID is served, e.g. pin its snapshots.
+// Write access to all namespaces and resource types of the node ID is required.
+func CanWriteNode(c *gin.Context, nodeID string) bool {
+	access := NodeAccess(c, nodeID)
+	return access != nil && access.Write && access.Namespaces == nil && access.ResourceTypes == nil
+}
+
+// CanReadNamespace returns true if the request may see objects of the namespace on any available node ID.
+func CanReadNamespace(c *gin.Context, namespace string) bool {
+	v, exists := c.Get(AuthAccess)
+	if !exists {
+		return true
+	}
+	return v.(*ACLAccess).anyNode(func(a *Access) bool {
+		return a.AllowsNamespace(namespace)
+	})
+}
+
+// CanWriteNamespace returns true if the request may manage objects of the namespace on any available node ID.
+// Writes are never allowed without ACL.
+func CanWriteNamespace(c *gin.Context, namespace string) bool {
+	v, exists := c.Get(AuthAccess)
+	if !exists {
+		return false
+	}
+	return v.(*ACLAccess).anyNode(func(a *Access) bool {
+		return a.Write && a.AllowsNamespace(namespace)
+	})
+}
+
+// anyNode returns true if the access to any available node ID is allowed.
+func (a *ACLAccess) anyNode(allowed func(*Access) bool) bool {
+	if a.all != nil && allowed(a.all) {
+		return true
+	}
+	for _, access := range a.nodes {
+		if allowed(access) {
+			return true
+		}
+	}
+	return false
+}
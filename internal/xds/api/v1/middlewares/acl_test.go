@@ -0,0 +1,258 @@
+package middlewares
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	xdscache "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+)
+
+func TestACLAccess(t *testing.T) {
+	rules := []ACLRule{
+		{Groups: []string{"admins"}, NodeIDs: []string{"*"}, SecretRedaction: xdscache.SecretRedactionNone},
+		{Groups: []string{"team-a"}, NodeIDs: []string{"node1"}, Namespaces: []string{"a"}, ResourceTypes: []string{"listeners"}, SecretRedaction: xdscache.SecretRedactionFull},
+		{Groups: []string{"team-b"}, NodeIDs: []string{"node1", "node2"}, Namespaces: []string{"b"}, ResourceTypes: []string{resourcev3.ClusterType}, Write: true},
+		{Groups: []string{"viewers"}, NodeIDs: []string{"node2"}},
+	}
+
+	tests := []struct {
+		name    string
+		groups  []string
+		nodeID  string
+		access  *Access
+		nodeIDs map[string]struct{}
+	}{
+		{
+			name:   "all node IDs",
+			groups: []string{"admins"},
+			nodeID: "node3",
+			access: &Access{SecretRedaction: xdscache.SecretRedactionNone},
+		},
+		{
+			name:   "restricted",
+			groups: []string{"team-a"},
+			nodeID: "node1",
+			access: &Access{
+				Namespaces:      map[string]struct{}{"a": {}},
+				ResourceTypes:   map[string]struct{}{resourcev3.ListenerType: {}},
+				SecretRedaction: xdscache.SecretRedactionFull,
+			},
+			nodeIDs: map[string]struct{}{"node1": {}},
+		},
+		{
+			name:   "union of rules",
+			groups: []string{"team-a", "team-b"},
+			nodeID: "node1",
+			access: &Access{
+				Namespaces:      map[string]struct{}{"a": {}, "b": {}},
+				ResourceTypes:   map[string]struct{}{resourcev3.ListenerType: {}, resourcev3.ClusterType: {}},
+				SecretRedaction: xdscache.SecretRedactionMetadata,
+				Write:           true,
+			},
+			nodeIDs: map[string]struct{}{"node1": {}, "node2": {}},
+		},
+		{
+			name:    "unrestricted rule",
+			groups:  []string{"team-b", "viewers"},
+			nodeID:  "node2",
+			access:  &Access{SecretRedaction: xdscache.SecretRedactionMetadata, Write: true},
+			nodeIDs: map[string]struct{}{"node1": {}, "node2": {}},
+		},
+		{
+			name:    "unavailable node ID",
+			groups:  []string{"team-a"},
+			nodeID:  "node2",
+			nodeIDs: map[string]struct{}{"node1": {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := newACLAccess(rules, tt.groups)
+			if access == nil {
+				t.Fatal("expected access")
+			}
+			if nodeIDs := access.NodeIDs(); !reflect.DeepEqual(nodeIDs, tt.nodeIDs) {
+				t.Errorf("expected node IDs %v, got %v", tt.nodeIDs, nodeIDs)
+			}
+			if nodeAccess := access.Node(tt.nodeID); !reflect.DeepEqual(nodeAccess, tt.access) {
+				t.Errorf("expected access %+v, got %+v", tt.access, nodeAccess)
+			}
+		})
+	}
+
+	if access := newACLAccess(rules, []string{"guests"}); access != nil {
+		t.Errorf("expected no access, got %+v", access)
+	}
+}
+
+func TestCanWriteNamespace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rules := []ACLRule{
+		{Groups: []string{"team-a"}, NodeIDs: []string{"node1"}, Namespaces: []string{"a"}, Write: true},
+		{Groups: []string{"viewers"}, NodeIDs: []string{"*"}},
+	}
+	tests := []struct {
+		groups    []string
+		namespace string
+		allowed   bool
+	}{
+		{groups: []string{"team-a"}, namespace: "a", allowed: true},
+		{groups: []string{"team-a"}, namespace: "b"},
+		{groups: []string{"viewers"}, namespace: "a"},
+		{groups: []string{"team-a", "viewers"}, namespace: "a", allowed: true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(AuthAccess, newACLAccess(rules, tt.groups))
+		if allowed := CanWriteNamespace(c, tt.namespace); allowed != tt.allowed {
+			t.Errorf("%v in %s: expected %v, got %v", tt.groups, tt.namespace, tt.allowed, allowed)
+		}
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if CanWriteNamespace(c, "a") {
+		t.Error("writes must not be allowed without ACL")
+	}
+}
+
+func TestCanReadNamespace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rules := []ACLRule{
+		{Groups: []string{"team-a"}, NodeIDs: []string{"node1"}, Namespaces: []string{"a"}},
+		{Groups: []string{"admins"}, NodeIDs: []string{"*"}},
+	}
+	tests := []struct {
+		groups    []string
+		namespace string
+		allowed   bool
+	}{
+		{groups: []string{"team-a"}, namespace: "a", allowed: true},
+		{groups: []string{"team-a"}, namespace: "b"},
+		{groups: []string{"admins"}, namespace: "b", allowed: true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(AuthAccess, newACLAccess(rules, tt.groups))
+		if allowed := CanReadNamespace(c, tt.namespace); allowed != tt.allowed {
+			t.Errorf("%v in %s: expected %v, got %v", tt.groups, tt.namespace, tt.allowed, allowed)
+		}
+	}
+}
+
+func TestCanWriteNode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rules := []ACLRule{
+		{Groups: []string{"team-a"}, NodeIDs: []string{"node1"}, Namespaces: []string{"a"}, Write: true},
+		{Groups: []string{"admins"}, NodeIDs: []string{"node1"}, Write: true},
+		{Groups: []string{"viewers"}, NodeIDs: []string{"*"}},
+	}
+	tests := []struct {
+		groups  []string
+		nodeID  string
+		allowed bool
+	}{
+		{groups: []string{"admins"}, nodeID: "node1", allowed: true},
+		{groups: []string{"admins"}, nodeID: "node2"},
+		{groups: []string{"team-a"}, nodeID: "node1"},
+		{groups: []string{"viewers"}, nodeID: "node1"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(AuthAccess, newACLAccess(rules, tt.groups))
+		if allowed := CanWriteNode(c, tt.nodeID); allowed != tt.allowed {
+			t.Errorf("%v on %s: expected %v, got %v", tt.groups, tt.nodeID, tt.allowed, allowed)
+		}
+	}
+}
+
+func TestHasFullAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rules := []ACLRule{
+		{Groups: []string{"admins"}, NodeIDs: []string{"*"}},
+		{Groups: []string{"team-a"}, NodeIDs: []string{"*"}, Namespaces: []string{"a"}},
+	}
+	tests := []struct {
+		name     string
+		setup    func(c *gin.Context)
+		expected bool
+	}{
+		{name: "no auth", setup: func(*gin.Context) {}},
+		{name: "authenticated without ACL", setup: func(c *gin.Context) {
+			c.Set(AuthIdentity, &Identity{Name: "user"})
+		}, expected: true},
+		{name: "all node IDs", setup: func(c *gin.Context) {
+			c.Set(AuthAccess, newACLAccess(rules, []string{"admins"}))
+		}, expected: true},
+		{name: "restricted namespaces", setup: func(c *gin.Context) {
+			c.Set(AuthAccess, newACLAccess(rules, []string{"team-a"}))
+		}},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		tt.setup(c)
+		if hasFullAccess := HasFullAccess(c); hasFullAccess != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, hasFullAccess)
+		}
+	}
+}
+
+func TestConfigMapACL(t *testing.T) {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "exc", Name: "acl"},
+		Data: map[string]string{ACLConfigKey: `
+rules:
+  - groups: ["admins"]
+    nodeIds: ["*"]
+`},
+	}
+	reader := fake.NewClientBuilder().WithObjects(cm).Build()
+	acl := NewConfigMapACL(reader, types.NamespacedName{Namespace: "exc", Name: "acl"}, time.Minute)
+
+	rules, err := acl.Rules(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Groups[0] != "admins" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	// invalid rules are ignored
+	cm.Data[ACLConfigKey] = `
+rules:
+  - groups: ["users"]
+    nodeIds: ["node1"]
+    resourceTypes: ["unknown"]
+`
+	if err := reader.Update(context.Background(), cm); err != nil {
+		t.Fatal(err)
+	}
+	acl.loadedAt = time.Time{}
+	if rules, err = acl.Rules(context.Background()); err != nil || rules[0].Groups[0] != "admins" {
+		t.Errorf("previous rules must be used, got %+v, %v", rules, err)
+	}
+
+	// rules are reloaded
+	cm.Data[ACLConfigKey] = `
+rules:
+  - groups: ["users"]
+    nodeIds: ["node1"]
+    secretRedaction: full
+`
+	if err := reader.Update(context.Background(), cm); err != nil {
+		t.Fatal(err)
+	}
+	acl.loadedAt = time.Time{}
+	if rules, err = acl.Rules(context.Background()); err != nil || rules[0].Groups[0] != "users" {
+		t.Errorf("expected reloaded rules, got %+v, %v", rules, err)
+	}
+}
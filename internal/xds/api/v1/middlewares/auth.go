@@ -1,43 +1,50 @@
 package middlewares
 
 import (
-	"context"
+	"errors"
+	"net/http"
 
-	"github.com/coreos/go-oidc/v3/oidc"
-	"github.com/dgrijalva/jwt-go/request"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/multierr"
 )
 
-const AvailableNodeIDs = "available_node_ids"
+const (
+	AvailableNodeIDs = "available_node_ids"
+	// AuthIdentity is the key of the authenticated Identity in the request context
+	AuthIdentity = "auth_identity"
+)
 
-type Auth struct {
-	verifier *oidc.IDTokenVerifier
-	aclStore *aclStore
-	devMode  bool
+// ErrNoCredentials is returned by authenticators if the request has no credentials of their kind.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity is the authenticated user of the API, groups are mapped to node IDs by the ACL.
+type Identity struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups"`
+	// Method is the authenticator which authenticated the user
+	Method string `json:"method"`
 }
 
-type ACL struct {
-	Group   string   `json:"group"`
-	NodeIDs []string `json:"nodeIds"`
+// Authenticator authenticates requests to the API.
+type Authenticator interface {
+	// Authenticate returns the identity of the request, ErrNoCredentials if the request has no credentials for the authenticator
+	Authenticate(r *http.Request) (*Identity, error)
 }
 
-type aclStore struct {
-	enabled        bool
-	fullAccess     map[string]struct{}
-	nodeIDsByGroup map[string][]string
+type Auth struct {
+	authenticators []Authenticator
+	// acl provides rules mapping groups to available node IDs and resources, disabled if nil
+	acl     ACLProvider
+	devMode bool
 }
 
-func NewAuth(issuerURL, clientID string, acl map[string][]string, devMode bool) (*Auth, error) {
-	provider, err := oidc.NewProvider(context.Background(), issuerURL)
-	if err != nil {
-		return nil, err
-	}
-	verifier := provider.Verifier(&oidc.Config{ClientID: clientID})
+// NewAuth returns the middleware authenticating requests with the first authenticator accepting them.
+func NewAuth(authenticators []Authenticator, acl ACLProvider, devMode bool) *Auth {
 	return &Auth{
-		verifier: verifier,
-		aclStore: newACLStore(acl),
-		devMode:  devMode,
-	}, nil
+		authenticators: authenticators,
+		acl:            acl,
+		devMode:        devMode,
+	}
 }
 
 func (m *Auth) HandlerFunc(c *gin.Context) {
@@ -54,75 +61,52 @@ func (m *Auth) HandlerFunc(c *gin.Context) {
 		return resp
 	}
 
-	token, err := request.OAuth2Extractor.ExtractToken(c.Request)
+	identity, err := m.authenticate(c.Request)
 	if err != nil {
-		c.JSON(401, respMessage("Unauthorized", "failed to extract token", err))
-		c.Abort()
-		return
-	}
-	idToken, err := m.verifier.Verify(c.Request.Context(), token)
-	if err != nil {
-		c.JSON(401, respMessage("Unauthorized", "failed to verify token", err))
+		c.JSON(401, respMessage("Unauthorized", "failed to authenticate", err))
 		c.Abort()
 		return
 	}
+	c.Set(AuthIdentity, identity)
 
-	var claims struct {
-		Groups []string `json:"groups"`
-	}
-	if err := idToken.Claims(&claims); err != nil {
-		c.JSON(401, respMessage("Unauthorized", "failed to get claims", err))
-		c.Abort()
-		return
-	}
-
-	if m.aclStore.enabled {
-		fullAccess := false
-		availableNodeIds := make(map[string]struct{})
-		for _, group := range claims.Groups {
-			if _, ok := m.aclStore.fullAccess[group]; ok {
-				fullAccess = true
-				break
-			}
-			if nodeIds, ok := m.aclStore.nodeIDsByGroup[group]; ok {
-				for _, nodeId := range nodeIds {
-					availableNodeIds[nodeId] = struct{}{}
-				}
-			}
-		}
-
-		switch {
-		case fullAccess:
-		case len(availableNodeIds) > 0:
-			c.Set(AvailableNodeIDs, availableNodeIds)
-		default:
-			c.JSON(401, respMessage("Unauthorized", "not available node ids", err))
+	if m.acl != nil {
+		rules, err := m.acl.Rules(c.Request.Context())
+		if err != nil {
+			c.JSON(500, respMessage("Internal Server Error", "failed to get ACL rules", err))
 			c.Abort()
 			return
 		}
+		if rules != nil {
+			access := newACLAccess(rules, identity.Groups)
+			if access == nil {
+				c.JSON(401, respMessage("Unauthorized", "not available node ids", nil))
+				c.Abort()
+				return
+			}
+			if nodeIDs := access.NodeIDs(); nodeIDs != nil {
+				c.Set(AvailableNodeIDs, nodeIDs)
+			}
+			c.Set(AuthAccess, access)
+		}
 	}
 
 	c.Next()
 }
 
-func newACLStore(acl map[string][]string) *aclStore {
-	store := &aclStore{}
-	if len(acl) == 0 {
-		return store
-	}
-	store.enabled = true
-	store.fullAccess = make(map[string]struct{})
-	store.nodeIDsByGroup = make(map[string][]string)
-
-LOOP:
-	for group, nodeIDs := range acl {
-		for _, nodeID := range nodeIDs {
-			if nodeID == "*" {
-				store.fullAccess[group] = struct{}{}
-				continue LOOP
-			}
+// authenticate returns the identity from the first authenticator accepting the credentials of the request.
+func (m *Auth) authenticate(r *http.Request) (*Identity, error) {
+	var errs error
+	for _, authenticator := range m.authenticators {
+		identity, err := authenticator.Authenticate(r)
+		switch {
+		case err == nil:
+			return identity, nil
+		case !errors.Is(err, ErrNoCredentials):
+			errs = multierr.Append(errs, err)
 		}
-		store.nodeIDsByGroup[group] = nodeIDs
 	}
-	return store
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, ErrNoCredentials
 }
@@ -0,0 +1,162 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/nodeIDs", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestStaticTokenAuthenticator(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "exc", Name: "tokens"},
+		Data: map[string][]byte{StaticTokensKey: []byte(
+			"# ci tokens\n" +
+				"token-1,ci,1,\"admins,users\"\n" +
+				"token-2,viewer\n",
+		)},
+	}
+	reader := fake.NewClientBuilder().WithObjects(secret).Build()
+	a := NewStaticTokenAuthenticator(reader, types.NamespacedName{Namespace: "exc", Name: "tokens"}, time.Minute)
+
+	identity, err := a.Authenticate(newRequest("token-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Identity{Name: "ci", Groups: []string{"admins", "users"}, Method: "static_token"}
+	if !reflect.DeepEqual(identity, expected) {
+		t.Errorf("expected %+v, got %+v", expected, identity)
+	}
+	if identity, err = a.Authenticate(newRequest("token-2")); err != nil || identity.Name != "viewer" || len(identity.Groups) != 0 {
+		t.Errorf("expected viewer without groups, got %+v, %v", identity, err)
+	}
+	if _, err = a.Authenticate(newRequest("token-3")); err == nil {
+		t.Error("unknown token must not be authenticated")
+	}
+	if _, err = a.Authenticate(newRequest("")); err != ErrNoCredentials {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+
+	// tokens are kept if the secret is deleted
+	if err := reader.Delete(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	a.loadedAt = time.Time{}
+	if _, err = a.Authenticate(newRequest("token-1")); err != nil {
+		t.Errorf("previous tokens must be used, got %v", err)
+	}
+}
+
+func TestStaticTokenAuthenticatorMissingSecret(t *testing.T) {
+	reader := fake.NewClientBuilder().Build()
+	a := NewStaticTokenAuthenticator(reader, types.NamespacedName{Namespace: "exc", Name: "tokens"}, time.Minute)
+
+	if _, err := a.Authenticate(newRequest("token-1")); err == nil {
+		t.Fatal("token must not be authenticated without the secret")
+	}
+	loadedAt := a.loadedAt
+	if _, err := a.Authenticate(newRequest("token-1")); err == nil {
+		t.Fatal("token must not be authenticated without the secret")
+	}
+	if a.loadedAt != loadedAt {
+		t.Error("secret must not be reread before the refresh period")
+	}
+}
+
+func TestClientCertAuthenticator(t *testing.T) {
+	r := newRequest("")
+	if _, err := (ClientCertAuthenticator{}).Authenticate(r); err != ErrNoCredentials {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "ops", Organization: []string{"admins"}}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	identity, err := (ClientCertAuthenticator{}).Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Name != "ops" || !reflect.DeepEqual(identity.Groups, []string{"admins"}) {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCAuthenticatorUnavailableIssuer(t *testing.T) {
+	issuer := httptest.NewServer(http.NotFoundHandler())
+	issuer.Close()
+
+	a := NewOIDCAuthenticator(issuer.URL, "client", time.Hour)
+	if _, err := a.Authenticate(newRequest("token")); err == nil {
+		t.Fatal("token must not be authenticated without the provider")
+	}
+	attempt := a.lastAttempt
+	if _, err := a.Authenticate(newRequest("token")); err == nil {
+		t.Fatal("token must not be authenticated without the provider")
+	}
+	if a.lastAttempt != attempt {
+		t.Error("discovery must not be retried before the retry interval")
+	}
+}
+
+type authenticatorFunc func(r *http.Request) (*Identity, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (*Identity, error) { return f(r) }
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	byToken := func(token string, identity *Identity) Authenticator {
+		return authenticatorFunc(func(r *http.Request) (*Identity, error) {
+			if r.Header.Get("Authorization") == "Bearer "+token {
+				return identity, nil
+			}
+			return nil, ErrNoCredentials
+		})
+	}
+	auth := NewAuth([]Authenticator{
+		byToken("admin", &Identity{Name: "admin", Groups: []string{"admins"}}),
+		byToken("user", &Identity{Name: "user", Groups: []string{"users"}}),
+		byToken("guest", &Identity{Name: "guest", Groups: []string{"guests"}}),
+	}, NewStaticACL(map[string][]string{"admins": {"*"}, "users": {"node1"}}), false)
+
+	tests := []struct {
+		token   string
+		code    int
+		nodeIDs map[string]struct{}
+	}{
+		{token: "admin", code: 200},
+		{token: "user", code: 200, nodeIDs: map[string]struct{}{"node1": {}}},
+		{token: "guest", code: 401},
+		{token: "", code: 401},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newRequest(tt.token)
+		auth.HandlerFunc(c)
+		if c.IsAborted() != (tt.code != 200) {
+			t.Errorf("%q: expected code %d, got %d", tt.token, tt.code, w.Code)
+			continue
+		}
+		nodeIDs, _ := c.Get(AvailableNodeIDs)
+		if tt.nodeIDs != nil && !reflect.DeepEqual(nodeIDs, tt.nodeIDs) {
+			t.Errorf("%q: expected node IDs %v, got %v", tt.token, tt.nodeIDs, nodeIDs)
+		}
+	}
+}
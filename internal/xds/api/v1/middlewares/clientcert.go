@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+)
+
+// ClientCertAuthenticator authenticates verified TLS client certificates.
+// Like in Kubernetes, the common name of the subject is the user name and organizations are groups.
+type ClientCertAuthenticator struct{}
+
+func (ClientCertAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	return &Identity{
+		Name:   cert.Subject.CommonName,
+		Groups: cert.Subject.Organization,
+		Method: "client_cert",
+	}, nil
+}
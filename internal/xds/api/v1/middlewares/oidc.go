@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dgrijalva/jwt-go/request"
+)
+
+const (
+	// DefaultOIDCRetryInterval is the minimal interval between attempts to discover the OIDC provider
+	DefaultOIDCRetryInterval = 10 * time.Second
+
+	oidcDiscoveryTimeout = 10 * time.Second
+)
+
+// OIDCAuthenticator authenticates OIDC ID tokens with groups in the groups claim.
+// The provider is discovered on the first request, so an unavailable issuer doesn't fail the startup,
+// failed discovery is retried by later requests.
+type OIDCAuthenticator struct {
+	issuerURL     string
+	clientID      string
+	retryInterval time.Duration
+
+	mu          sync.Mutex
+	verifier    *oidc.IDTokenVerifier
+	lastAttempt time.Time
+	lastErr     error
+}
+
+func NewOIDCAuthenticator(issuerURL, clientID string, retryInterval time.Duration) *OIDCAuthenticator {
+	if retryInterval <= 0 {
+		retryInterval = DefaultOIDCRetryInterval
+	}
+	return &OIDCAuthenticator{issuerURL: issuerURL, clientID: clientID, retryInterval: retryInterval}
+}
+
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, err := request.OAuth2Extractor.ExtractToken(r)
+	if err != nil {
+		return nil, ErrNoCredentials
+	}
+	verifier, err := a.getVerifier(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := verifier.Verify(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify OIDC token: %w", err)
+	}
+
+	var claims struct {
+		Groups []string `json:"groups"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to get OIDC claims: %w", err)
+	}
+	return &Identity{Name: idToken.Subject, Groups: claims.Groups, Method: "oidc"}, nil
+}
+
+func (a *OIDCAuthenticator) getVerifier(ctx context.Context) (*oidc.IDTokenVerifier, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.verifier != nil {
+		return a.verifier, nil
+	}
+	if !a.lastAttempt.IsZero() && time.Since(a.lastAttempt) < a.retryInterval {
+		return nil, fmt.Errorf("OIDC provider %s is not available: %w", a.issuerURL, a.lastErr)
+	}
+
+	a.lastAttempt = time.Now()
+	ctx, cancel := context.WithTimeout(ctx, oidcDiscoveryTimeout)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, a.issuerURL)
+	if err != nil {
+		a.lastErr = err
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", a.issuerURL, err)
+	}
+	a.verifier = provider.Verifier(&oidc.Config{ClientID: a.clientID})
+	return a.verifier, nil
+}
@@ -0,0 +1,137 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/request"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StaticTokensKey is the key of the Secret with static API tokens
+	StaticTokensKey = "tokens.csv"
+
+	DefaultStaticTokensRefreshPeriod = time.Minute
+)
+
+// StaticTokenAuthenticator authenticates static API tokens from a Secret.
+// The tokens.csv key of the Secret has the format of the Kubernetes static token file:
+// token,user,uid,"group1,group2", the uid is ignored.
+// The Secret is reread not more often than the refresh period, so tokens are rotated without restart.
+type StaticTokenAuthenticator struct {
+	reader        client.Reader
+	secret        types.NamespacedName
+	refreshPeriod time.Duration
+
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]*Identity
+	// loadedAt is the time of the last attempt to load tokens, failed attempts aren't retried before the refresh period
+	loadedAt time.Time
+	lastErr  error
+}
+
+func NewStaticTokenAuthenticator(reader client.Reader, secret types.NamespacedName, refreshPeriod time.Duration) *StaticTokenAuthenticator {
+	if refreshPeriod <= 0 {
+		refreshPeriod = DefaultStaticTokensRefreshPeriod
+	}
+	return &StaticTokenAuthenticator{reader: reader, secret: secret, refreshPeriod: refreshPeriod}
+}
+
+func (a *StaticTokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, err := request.OAuth2Extractor.ExtractToken(r)
+	if err != nil {
+		return nil, ErrNoCredentials
+	}
+	tokens, err := a.get(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	identity, ok := tokens[sha256.Sum256([]byte(token))]
+	if !ok {
+		return nil, errors.New("unknown static token")
+	}
+	return identity, nil
+}
+
+func (a *StaticTokenAuthenticator) get(ctx context.Context) (map[[sha256.Size]byte]*Identity, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loadedAt.IsZero() && time.Since(a.loadedAt) < a.refreshPeriod {
+		if a.tokens == nil {
+			return nil, a.lastErr
+		}
+		return a.tokens, nil
+	}
+
+	a.loadedAt = time.Now()
+	tokens, err := a.load(ctx)
+	if err != nil {
+		a.lastErr = err
+		if a.tokens != nil {
+			// keep the previous tokens
+			return a.tokens, nil
+		}
+		return nil, err
+	}
+	a.tokens = tokens
+	return a.tokens, nil
+}
+
+func (a *StaticTokenAuthenticator) load(ctx context.Context) (map[[sha256.Size]byte]*Identity, error) {
+	var secret v1.Secret
+	if err := a.reader.Get(ctx, a.secret, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get static tokens secret %s: %w", a.secret.String(), err)
+	}
+	tokens, err := parseStaticTokens(secret.Data[StaticTokensKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse static tokens secret %s: %w", a.secret.String(), err)
+	}
+	return tokens, nil
+}
+
+func parseStaticTokens(data []byte) (map[[sha256.Size]byte]*Identity, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	tokens := make(map[[sha256.Size]byte]*Identity)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: token and user are required", line)
+		}
+		identity := &Identity{Name: record[1], Method: "static_token"}
+		if len(record) > 3 && record[3] != "" {
+			for _, group := range strings.Split(record[3], ",") {
+				identity.Groups = append(identity.Groups, strings.TrimSpace(group))
+			}
+		}
+		key := sha256.Sum256([]byte(record[0]))
+		if _, ok := tokens[key]; ok {
+			return nil, fmt.Errorf("duplicate token of user %s", identity.Name)
+		}
+		tokens[key] = identity
+	}
+	return tokens, nil
+}
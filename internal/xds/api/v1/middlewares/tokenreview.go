@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/request"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultTokenReviewCacheTTL is how long successful token reviews are cached.
+const DefaultTokenReviewCacheTTL = time.Minute
+
+// TokenReviewAuthenticator authenticates Kubernetes tokens, e.g. of service accounts, with TokenReview.
+// The user name and groups come from the review, service accounts are in system:serviceaccounts:<namespace> groups.
+type TokenReviewAuthenticator struct {
+	client    client.Client
+	audiences []string
+	cacheTTL  time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity  *Identity
+	expiresAt time.Time
+}
+
+func NewTokenReviewAuthenticator(c client.Client, audiences []string, cacheTTL time.Duration) *TokenReviewAuthenticator {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultTokenReviewCacheTTL
+	}
+	return &TokenReviewAuthenticator{
+		client:    c,
+		audiences: audiences,
+		cacheTTL:  cacheTTL,
+		cache:     make(map[[sha256.Size]byte]cachedIdentity),
+	}
+}
+
+func (a *TokenReviewAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token, err := request.OAuth2Extractor.ExtractToken(r)
+	if err != nil {
+		return nil, ErrNoCredentials
+	}
+	key := sha256.Sum256([]byte(token))
+	if identity := a.cached(key); identity != nil {
+		return identity, nil
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: a.audiences},
+	}
+	if err := a.client.Create(r.Context(), review); err != nil {
+		return nil, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, fmt.Errorf("token is not authenticated: %s", review.Status.Error)
+	}
+
+	identity := &Identity{
+		Name:   review.Status.User.Username,
+		Groups: review.Status.User.Groups,
+		Method: "token_review",
+	}
+	a.store(key, identity)
+	return identity, nil
+}
+
+func (a *TokenReviewAuthenticator) cached(key [sha256.Size]byte) *Identity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.cache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return entry.identity
+}
+
+func (a *TokenReviewAuthenticator) store(key [sha256.Size]byte, identity *Identity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for k, entry := range a.cache {
+		if now.After(entry.expiresAt) {
+			delete(a.cache, k)
+		}
+	}
+	a.cache[key] = cachedIdentity{identity: identity, expiresAt: now.Add(a.cacheTTL)}
+}
@@ -0,0 +1,340 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	bootstrapv3 "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	metricsv3 "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v3"
+	overloadv3 "github.com/envoyproxy/go-control-plane/envoy/config/overload/v3"
+	fixedheapv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/resource_monitors/fixed_heap/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	httpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	XDSClusterName = "xds_cluster"
+
+	DefaultKeepaliveInterval = 30 * time.Second
+	DefaultKeepaliveTimeout  = 5 * time.Second
+	DefaultConnectTimeout    = 5 * time.Second
+)
+
+// Options of the generated bootstrap.
+type Options struct {
+	NodeID      string
+	NodeCluster string
+
+	// XDSAddress and XDSPort are the address of the controller xDS server reachable from Envoy.
+	XDSAddress string
+	XDSPort    uint32
+	// TLS enables TLS to the xDS server, CA, certificate and key are files on the Envoy side.
+	TLS *TLSOptions
+
+	// DeltaADS enables incremental ADS, it is required for virtual hosts delivered over VHDS.
+	DeltaADS bool
+
+	KeepaliveInterval time.Duration
+	KeepaliveTimeout  time.Duration
+
+	// AdminPort enables the admin interface on 127.0.0.1, disabled if 0.
+	AdminPort uint32
+	// StatsdAddress enables the statsd stats sink, e.g. "127.0.0.1:8125".
+	StatsdAddress string
+	// MaxHeapSizeBytes enables the overload manager with the fixed heap resource monitor.
+	MaxHeapSizeBytes uint64
+	// RuntimeLayer adds an RTDS layer with the given name to the layered runtime.
+	RuntimeLayer string
+}
+
+type TLSOptions struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// SNI is the server name used for the TLS handshake, the xDS address is used if empty.
+	SNI string
+}
+
+// Generate builds the Envoy bootstrap and validates it.
+func Generate(opts Options) (*bootstrapv3.Bootstrap, error) {
+	if opts.NodeID == "" {
+		return nil, errors.New("node ID is required")
+	}
+	if opts.XDSAddress == "" || opts.XDSPort == 0 {
+		return nil, errors.New("xDS address and port are required")
+	}
+	if opts.KeepaliveInterval == 0 {
+		opts.KeepaliveInterval = DefaultKeepaliveInterval
+	}
+	if opts.KeepaliveTimeout == 0 {
+		opts.KeepaliveTimeout = DefaultKeepaliveTimeout
+	}
+
+	xdsCluster, err := makeXDSCluster(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	adsAPIType := corev3.ApiConfigSource_GRPC
+	if opts.DeltaADS {
+		adsAPIType = corev3.ApiConfigSource_DELTA_GRPC
+	}
+
+	adsConfigSource := &corev3.ConfigSource{
+		ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+		ResourceApiVersion:    corev3.ApiVersion_V3,
+	}
+
+	b := &bootstrapv3.Bootstrap{
+		Node: &corev3.Node{
+			Id:      opts.NodeID,
+			Cluster: opts.NodeCluster,
+		},
+		DynamicResources: &bootstrapv3.Bootstrap_DynamicResources{
+			AdsConfig: &corev3.ApiConfigSource{
+				ApiType:             adsAPIType,
+				TransportApiVersion: corev3.ApiVersion_V3,
+				GrpcServices: []*corev3.GrpcService{{
+					TargetSpecifier: &corev3.GrpcService_EnvoyGrpc_{
+						EnvoyGrpc: &corev3.GrpcService_EnvoyGrpc{ClusterName: XDSClusterName},
+					},
+				}},
+				SetNodeOnFirstMessageOnly: true,
+			},
+			LdsConfig: adsConfigSource,
+			CdsConfig: proto.Clone(adsConfigSource).(*corev3.ConfigSource),
+		},
+		StaticResources: &bootstrapv3.Bootstrap_StaticResources{
+			Clusters: []*clusterv3.Cluster{xdsCluster},
+		},
+	}
+
+	if opts.AdminPort > 0 {
+		b.Admin = &bootstrapv3.Admin{
+			Address: socketAddress("127.0.0.1", opts.AdminPort),
+		}
+	}
+
+	if opts.StatsdAddress != "" {
+		sink, err := makeStatsdSink(opts.StatsdAddress)
+		if err != nil {
+			return nil, err
+		}
+		b.StatsSinks = []*metricsv3.StatsSink{sink}
+	}
+
+	if opts.MaxHeapSizeBytes > 0 {
+		overloadManager, err := makeOverloadManager(opts.MaxHeapSizeBytes)
+		if err != nil {
+			return nil, err
+		}
+		b.OverloadManager = overloadManager
+	}
+
+	if opts.RuntimeLayer != "" {
+		b.LayeredRuntime = &bootstrapv3.LayeredRuntime{
+			Layers: []*bootstrapv3.RuntimeLayer{{
+				Name: opts.RuntimeLayer,
+				LayerSpecifier: &bootstrapv3.RuntimeLayer_RtdsLayer_{
+					RtdsLayer: &bootstrapv3.RuntimeLayer_RtdsLayer{
+						Name:       opts.RuntimeLayer,
+						RtdsConfig: proto.Clone(adsConfigSource).(*corev3.ConfigSource),
+					},
+				},
+			}},
+		}
+	}
+
+	if err := b.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap: %w", err)
+	}
+	return b, nil
+}
+
+// MarshalYAML marshals the bootstrap to YAML with snake_case field names, as expected by Envoy.
+func MarshalYAML(b *bootstrapv3.Bootstrap) ([]byte, error) {
+	data, err := MarshalJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(data)
+}
+
+// MarshalJSON marshals the bootstrap to JSON with snake_case field names, as expected by Envoy.
+func MarshalJSON(b *bootstrapv3.Bootstrap) ([]byte, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(b)
+}
+
+func makeXDSCluster(opts Options) (*clusterv3.Cluster, error) {
+	httpOptions, err := anypb.New(&httpv3.HttpProtocolOptions{
+		UpstreamProtocolOptions: &httpv3.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &httpv3.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &httpv3.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: &corev3.Http2ProtocolOptions{
+						ConnectionKeepalive: &corev3.KeepaliveSettings{
+							Interval: durationpb.New(opts.KeepaliveInterval),
+							Timeout:  durationpb.New(opts.KeepaliveTimeout),
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &clusterv3.Cluster{
+		Name:                 XDSClusterName,
+		ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_STRICT_DNS},
+		ConnectTimeout:       durationpb.New(DefaultConnectTimeout),
+		TypedExtensionProtocolOptions: map[string]*anypb.Any{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": httpOptions,
+		},
+		LoadAssignment: &endpointv3.ClusterLoadAssignment{
+			ClusterName: XDSClusterName,
+			Endpoints: []*endpointv3.LocalityLbEndpoints{{
+				LbEndpoints: []*endpointv3.LbEndpoint{{
+					HostIdentifier: &endpointv3.LbEndpoint_Endpoint{
+						Endpoint: &endpointv3.Endpoint{Address: socketAddress(opts.XDSAddress, opts.XDSPort)},
+					},
+				}},
+			}},
+		},
+	}
+
+	if opts.TLS != nil {
+		transportSocket, err := makeUpstreamTLS(opts)
+		if err != nil {
+			return nil, err
+		}
+		cluster.TransportSocket = transportSocket
+	}
+
+	return cluster, nil
+}
+
+func makeUpstreamTLS(opts Options) (*corev3.TransportSocket, error) {
+	commonTLSContext := &tlsv3.CommonTlsContext{}
+	if opts.TLS.CAFile != "" {
+		commonTLSContext.ValidationContextType = &tlsv3.CommonTlsContext_ValidationContext{
+			ValidationContext: &tlsv3.CertificateValidationContext{
+				TrustedCa: fileDataSource(opts.TLS.CAFile),
+			},
+		}
+	}
+	if opts.TLS.CertFile != "" || opts.TLS.KeyFile != "" {
+		if opts.TLS.CertFile == "" || opts.TLS.KeyFile == "" {
+			return nil, errors.New("both client certificate and key files are required")
+		}
+		commonTLSContext.TlsCertificates = []*tlsv3.TlsCertificate{{
+			CertificateChain: fileDataSource(opts.TLS.CertFile),
+			PrivateKey:       fileDataSource(opts.TLS.KeyFile),
+		}}
+	}
+
+	sni := opts.TLS.SNI
+	if sni == "" {
+		sni = opts.XDSAddress
+	}
+	tlsContext, err := anypb.New(&tlsv3.UpstreamTlsContext{
+		CommonTlsContext: commonTLSContext,
+		Sni:              sni,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &corev3.TransportSocket{
+		Name:       wellknown.TransportSocketTLS,
+		ConfigType: &corev3.TransportSocket_TypedConfig{TypedConfig: tlsContext},
+	}, nil
+}
+
+func makeStatsdSink(address string) (*metricsv3.StatsSink, error) {
+	host, port, err := splitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statsd address: %w", err)
+	}
+	statsd, err := anypb.New(&metricsv3.StatsdSink{
+		StatsdSpecifier: &metricsv3.StatsdSink_Address{Address: socketAddress(host, port)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &metricsv3.StatsSink{
+		Name:       "envoy.stat_sinks.statsd",
+		ConfigType: &metricsv3.StatsSink_TypedConfig{TypedConfig: statsd},
+	}, nil
+}
+
+func makeOverloadManager(maxHeapSizeBytes uint64) (*overloadv3.OverloadManager, error) {
+	fixedHeap, err := anypb.New(&fixedheapv3.FixedHeapConfig{MaxHeapSizeBytes: maxHeapSizeBytes})
+	if err != nil {
+		return nil, err
+	}
+	const heapMonitor = "envoy.resource_monitors.fixed_heap"
+	threshold := func(value float64) *overloadv3.Trigger {
+		return &overloadv3.Trigger{
+			Name: heapMonitor,
+			TriggerOneof: &overloadv3.Trigger_Threshold{
+				Threshold: &overloadv3.ThresholdTrigger{Value: value},
+			},
+		}
+	}
+	return &overloadv3.OverloadManager{
+		RefreshInterval: durationpb.New(250 * time.Millisecond),
+		ResourceMonitors: []*overloadv3.ResourceMonitor{{
+			Name:       heapMonitor,
+			ConfigType: &overloadv3.ResourceMonitor_TypedConfig{TypedConfig: fixedHeap},
+		}},
+		Actions: []*overloadv3.OverloadAction{
+			{
+				Name:     "envoy.overload_actions.shrink_heap",
+				Triggers: []*overloadv3.Trigger{threshold(0.95)},
+			},
+			{
+				Name:     "envoy.overload_actions.stop_accepting_requests",
+				Triggers: []*overloadv3.Trigger{threshold(0.98)},
+			},
+		},
+	}, nil
+}
+
+func splitHostPort(address string) (string, uint32, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, uint32(port), nil
+}
+
+func socketAddress(address string, port uint32) *corev3.Address {
+	return &corev3.Address{
+		Address: &corev3.Address_SocketAddress{
+			SocketAddress: &corev3.SocketAddress{
+				Address:       address,
+				PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: port},
+			},
+		},
+	}
+}
+
+func fileDataSource(filename string) *corev3.DataSource {
+	return &corev3.DataSource{Specifier: &corev3.DataSource_Filename{Filename: filename}}
+}
@@ -0,0 +1,119 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		wantErr  bool
+		contains []string
+		excludes []string
+	}{
+		{
+			name:    "missing node ID",
+			opts:    Options{XDSAddress: "xds.envoy.svc", XDSPort: 9000},
+			wantErr: true,
+		},
+		{
+			name:    "missing xDS address",
+			opts:    Options{NodeID: "node1"},
+			wantErr: true,
+		},
+		{
+			name: "minimal",
+			opts: Options{NodeID: "node1", XDSAddress: "xds.envoy.svc", XDSPort: 9000},
+			contains: []string{
+				"id: node1",
+				"cluster_name: xds_cluster",
+				"address: xds.envoy.svc",
+				"port_value: 9000",
+				"set_node_on_first_message_only: true",
+			},
+			excludes: []string{"DELTA_GRPC", "admin:", "stats_sinks:", "overload_manager:", "layered_runtime:", "transport_socket:"},
+		},
+		{
+			name: "all sections",
+			opts: Options{
+				NodeID:           "node1",
+				NodeCluster:      "edge",
+				XDSAddress:       "xds.envoy.svc",
+				XDSPort:          9000,
+				TLS:              &TLSOptions{CAFile: "/certs/ca.crt", CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key"},
+				AdminPort:        9901,
+				StatsdAddress:    "127.0.0.1:8125",
+				MaxHeapSizeBytes: 1 << 30,
+				RuntimeLayer:     "rtds",
+				DeltaADS:         true,
+			},
+			contains: []string{
+				"cluster: edge",
+				"admin:",
+				"port_value: 9901",
+				"envoy.stat_sinks.statsd",
+				"port_value: 8125",
+				"envoy.resource_monitors.fixed_heap",
+				"max_heap_size_bytes: \"1073741824\"",
+				"rtds_layer:",
+				"filename: /certs/ca.crt",
+				"sni: xds.envoy.svc",
+				"api_type: DELTA_GRPC",
+			},
+		},
+		{
+			name: "client certificate without key",
+			opts: Options{
+				NodeID:     "node1",
+				XDSAddress: "xds.envoy.svc",
+				XDSPort:    9000,
+				TLS:        &TLSOptions{CertFile: "/certs/tls.crt"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid statsd address",
+			opts: Options{
+				NodeID:        "node1",
+				XDSAddress:    "xds.envoy.svc",
+				XDSPort:       9000,
+				StatsdAddress: "statsd",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Generate(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			data, err := MarshalYAML(b)
+			if err != nil {
+				t.Fatalf("MarshalYAML() error = %v", err)
+			}
+			var out map[string]interface{}
+			if err := yaml.Unmarshal(data, &out); err != nil {
+				t.Fatalf("invalid YAML: %v", err)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(string(data), s) {
+					t.Errorf("bootstrap does not contain %q:\n%s", s, data)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(string(data), s) {
+					t.Errorf("bootstrap contains %q:\n%s", s, data)
+				}
+			}
+		})
+	}
+}
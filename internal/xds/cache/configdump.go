@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"sort"
+	"time"
+
+	adminv3 "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GetConfigDump returns the view of the snapshot served to the node in the format of the Envoy admin /config_dump endpoint.
+func (c *SnapshotCache) GetConfigDump(nodeID string, opts ViewOptions) (*adminv3.ConfigDump, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	snapshot, err := c.SnapshotCache.GetSnapshot(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigDump(NewSnapshotView(snapshot, opts), c.servedAt(nodeID))
+}
+
+// servedAt returns the time the served snapshot of the node was set, zero if the history is disabled.
+func (c *SnapshotCache) servedAt(nodeID string) time.Time {
+	h := c.history[nodeID]
+	if h == nil || len(h.entries) == 0 {
+		return time.Time{}
+	}
+	if h.pinned != 0 {
+		entry, _ := c.getHistoryEntry(nodeID, h.pinned)
+		return entry.Timestamp
+	}
+	return h.entries[len(h.entries)-1].Timestamp
+}
+
+// ConfigDump converts the snapshot to dynamic listener, cluster, route and secret sections of an Envoy config dump.
+// Secrets are dumped as they are in the snapshot, use a view of the snapshot to redact them.
+func ConfigDump(snapshot cache.ResourceSnapshot, lastUpdated time.Time) (*adminv3.ConfigDump, error) {
+	var updated *timestamppb.Timestamp
+	if !lastUpdated.IsZero() {
+		updated = timestamppb.New(lastUpdated)
+	}
+
+	clusters := &adminv3.ClustersConfigDump{VersionInfo: snapshot.GetVersion(resourcev3.ClusterType)}
+	for _, resource := range sortedResources(snapshot, resourcev3.ClusterType) {
+		cluster, err := anypb.New(resource)
+		if err != nil {
+			return nil, err
+		}
+		clusters.DynamicActiveClusters = append(clusters.DynamicActiveClusters, &adminv3.ClustersConfigDump_DynamicCluster{
+			VersionInfo: snapshot.GetVersion(resourcev3.ClusterType),
+			Cluster:     cluster,
+			LastUpdated: updated,
+		})
+	}
+
+	listeners := &adminv3.ListenersConfigDump{VersionInfo: snapshot.GetVersion(resourcev3.ListenerType)}
+	for _, resource := range sortedResources(snapshot, resourcev3.ListenerType) {
+		listener, err := anypb.New(resource)
+		if err != nil {
+			return nil, err
+		}
+		listeners.DynamicListeners = append(listeners.DynamicListeners, &adminv3.ListenersConfigDump_DynamicListener{
+			Name: cache.GetResourceName(resource),
+			ActiveState: &adminv3.ListenersConfigDump_DynamicListenerState{
+				VersionInfo: snapshot.GetVersion(resourcev3.ListenerType),
+				Listener:    listener,
+				LastUpdated: updated,
+			},
+		})
+	}
+
+	routes := &adminv3.RoutesConfigDump{}
+	for _, resource := range sortedResources(snapshot, resourcev3.RouteType) {
+		routeConfig, err := anypb.New(resource)
+		if err != nil {
+			return nil, err
+		}
+		routes.DynamicRouteConfigs = append(routes.DynamicRouteConfigs, &adminv3.RoutesConfigDump_DynamicRouteConfig{
+			VersionInfo: snapshot.GetVersion(resourcev3.RouteType),
+			RouteConfig: routeConfig,
+			LastUpdated: updated,
+		})
+	}
+
+	secrets := &adminv3.SecretsConfigDump{}
+	for _, resource := range sortedResources(snapshot, resourcev3.SecretType) {
+		secret, ok := resource.(*tlsv3.Secret)
+		if !ok {
+			continue
+		}
+		secretAny, err := anypb.New(secret)
+		if err != nil {
+			return nil, err
+		}
+		secrets.DynamicActiveSecrets = append(secrets.DynamicActiveSecrets, &adminv3.SecretsConfigDump_DynamicSecret{
+			Name:        secret.Name,
+			VersionInfo: snapshot.GetVersion(resourcev3.SecretType),
+			LastUpdated: updated,
+			Secret:      secretAny,
+		})
+	}
+
+	// sections are in the same order as in Envoy
+	dump := &adminv3.ConfigDump{}
+	for _, section := range []proto.Message{clusters, listeners, routes, secrets} {
+		config, err := anypb.New(section)
+		if err != nil {
+			return nil, err
+		}
+		dump.Configs = append(dump.Configs, config)
+	}
+	return dump, nil
+}
+
+func sortedResources(snapshot cache.ResourceSnapshot, typeURL string) []types.Resource {
+	resources := snapshot.GetResources(typeURL)
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]types.Resource, 0, len(names))
+	for _, name := range names {
+		result = append(result, resources[name])
+	}
+	return result
+}
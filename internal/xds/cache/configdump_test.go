@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	adminv3 "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+func TestConfigDump(t *testing.T) {
+	secret := &tlsv3.Secret{
+		Name: "default/cert",
+		Type: &tlsv3.Secret_TlsCertificate{TlsCertificate: &tlsv3.TlsCertificate{
+			CertificateChain: &corev3.DataSource{Specifier: &corev3.DataSource_InlineBytes{InlineBytes: []byte("cert")}},
+			PrivateKey:       &corev3.DataSource{Specifier: &corev3.DataSource_InlineBytes{InlineBytes: []byte("key")}},
+		}},
+	}
+	snapshot, err := cache.NewSnapshot("1", map[resourcev3.Type][]types.Resource{
+		resourcev3.ClusterType:  {&clusterv3.Cluster{Name: "b"}, &clusterv3.Cluster{Name: "a"}},
+		resourcev3.ListenerType: {&listenerv3.Listener{Name: "default/http"}},
+		resourcev3.SecretType:   {secret},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := ConfigDump(NewSnapshotView(snapshot, ViewOptions{}), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dump.Configs) != 4 {
+		t.Fatalf("expected 4 sections, got %d", len(dump.Configs))
+	}
+
+	clusters := &adminv3.ClustersConfigDump{}
+	if err := dump.Configs[0].UnmarshalTo(clusters); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &clusterv3.Cluster{}
+	if len(clusters.DynamicActiveClusters) != 2 || clusters.DynamicActiveClusters[0].Cluster.UnmarshalTo(cluster) != nil || cluster.Name != "a" {
+		t.Errorf("unexpected clusters %v", clusters)
+	}
+
+	listeners := &adminv3.ListenersConfigDump{}
+	if err := dump.Configs[1].UnmarshalTo(listeners); err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners.DynamicListeners) != 1 || listeners.DynamicListeners[0].Name != "default/http" {
+		t.Errorf("unexpected listeners %v", listeners)
+	}
+
+	secrets := &adminv3.SecretsConfigDump{}
+	if err := dump.Configs[3].UnmarshalTo(secrets); err != nil {
+		t.Fatal(err)
+	}
+	dumped := &tlsv3.Secret{}
+	if err := secrets.DynamicActiveSecrets[0].Secret.UnmarshalTo(dumped); err != nil {
+		t.Fatal(err)
+	}
+	if got := dumped.GetTlsCertificate().GetPrivateKey().GetInlineString(); got != RedactedValue {
+		t.Errorf("private key must be redacted, got %q", got)
+	}
+	if got := string(dumped.GetTlsCertificate().GetCertificateChain().GetInlineBytes()); got != "cert" {
+		t.Errorf("certificate chain must be kept, got %q", got)
+	}
+	if string(secret.GetTlsCertificate().GetPrivateKey().GetInlineBytes()) != "key" {
+		t.Errorf("snapshot secret must not be modified")
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+)
+
+// Types of snapshot events.
+const (
+	EventSet   = "set"
+	EventClear = "clear"
+	EventPin   = "pin"
+	EventUnpin = "unpin"
+	// EventOverflow ends streams of subscribers which missed events, they must resubscribe and resync
+	EventOverflow = "overflow"
+)
+
+// DefaultEventBufferSize is the default number of events buffered for a subscriber.
+const DefaultEventBufferSize = 64
+
+// SnapshotEvent is a change of the snapshot of a node.
+type SnapshotEvent struct {
+	Type      string    `json:"type"`
+	NodeID    string    `json:"node_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// ChangedTypes are type URLs of resources with new versions
+	ChangedTypes []string `json:"changed_types,omitempty"`
+	// Versions are versions of resources by type URL
+	Versions map[string]string `json:"versions,omitempty"`
+	// Cause is the change of objects which triggered the snapshot
+	Cause string `json:"cause,omitempty"`
+	// CauseNamespace is the namespace of the changed object, empty if the cause isn't a change of an object
+	CauseNamespace string `json:"-"`
+	// Pinned is set if the node is pinned and the snapshot is recorded, but not served
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+type subscriber struct {
+	events chan SnapshotEvent
+}
+
+// Subscribe returns a channel of snapshot events and a function to cancel the subscription.
+// If the subscriber doesn't read events fast enough to keep the buffer from filling, it is unsubscribed
+// and the channel is closed, so missed events are never hidden from it.
+func (c *SnapshotCache) Subscribe(buffer int) (<-chan SnapshotEvent, func()) {
+	s := &subscriber{events: make(chan SnapshotEvent, buffer)}
+	c.subscribersMu.Lock()
+	c.subscribers[s] = struct{}{}
+	c.subscribersMu.Unlock()
+
+	return s.events, func() {
+		c.subscribersMu.Lock()
+		defer c.subscribersMu.Unlock()
+		if _, ok := c.subscribers[s]; ok {
+			delete(c.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+func (c *SnapshotCache) publish(event SnapshotEvent) {
+	event.Timestamp = time.Now()
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+	for s := range c.subscribers {
+		select {
+		case s.events <- event:
+		default:
+			delete(c.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// newSetEvent returns the event of a snapshot replacing the previous one, prev may be nil.
+func newSetEvent(eventType, nodeID string, prev, snapshot cache.ResourceSnapshot) SnapshotEvent {
+	event := SnapshotEvent{Type: eventType, NodeID: nodeID, Versions: make(map[string]string)}
+	for i := types.ResponseType(0); i < types.UnknownType; i++ {
+		typeURL, err := cache.GetResponseTypeURL(i)
+		if err != nil {
+			continue
+		}
+		version := snapshot.GetVersion(typeURL)
+		if len(snapshot.GetResources(typeURL)) > 0 {
+			event.Versions[typeURL] = version
+		}
+		var prevVersion string
+		if prev != nil {
+			prevVersion = prev.GetVersion(typeURL)
+		}
+		if version != prevVersion {
+			event.ChangedTypes = append(event.ChangedTypes, typeURL)
+		}
+	}
+	return event
+}
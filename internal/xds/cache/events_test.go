@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+func TestSnapshotEvents(t *testing.T) {
+	c := NewSnapshotCache()
+	events, cancel := c.Subscribe(DefaultEventBufferSize)
+	ctx := WithCause(context.Background(), "update Cluster default/a")
+
+	if err := c.SetSnapshot(ctx, "node", newClusterSnapshot(t, "1", "a")); err != nil {
+		t.Fatal(err)
+	}
+	event := <-events
+	if event.Type != EventSet || event.NodeID != "node" || event.Cause != "update Cluster default/a" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.Versions[resourcev3.ClusterType] != "1" {
+		t.Errorf("unexpected versions %v", event.Versions)
+	}
+	// all types are changed for the first snapshot, they get a version
+	if len(event.ChangedTypes) == 0 {
+		t.Errorf("changed types must be set")
+	}
+
+	// only the version of clusters is changed
+	snapshot := newClusterSnapshot(t, "1", "b")
+	snapshot.Resources[types.Cluster].Version = "2"
+	if err := c.SetSnapshot(context.Background(), "node", snapshot); err != nil {
+		t.Fatal(err)
+	}
+	event = <-events
+	if !slices.Equal(event.ChangedTypes, []string{resourcev3.ClusterType}) {
+		t.Errorf("only clusters must be changed, got %v", event.ChangedTypes)
+	}
+
+	c.ClearSnapshot("node")
+	if event = <-events; event.Type != EventClear || event.NodeID != "node" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Errorf("events must be closed after cancel")
+	}
+}
+
+func TestSnapshotEventsOverflow(t *testing.T) {
+	c := NewSnapshotCache()
+	events, cancel := c.Subscribe(1)
+	defer cancel()
+
+	c.ClearSnapshot("node1")
+	c.ClearSnapshot("node2")
+	if event, ok := <-events; !ok || event.NodeID != "node1" {
+		t.Errorf("buffered event must be delivered, got %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("events must be closed after an event is missed")
+	}
+}
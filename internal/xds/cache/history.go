@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultHistorySize is the default number of snapshots kept per node.
+const DefaultHistorySize = 10
+
+// pinnedVersionSuffix is added to versions of a pinned snapshot, so they always differ from versions
+// ACKed by Envoy before pinning and after the pin is released.
+const pinnedVersionSuffix = "-pinned"
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// HistoryEntry is a snapshot set for a node.
+type HistoryEntry struct {
+	Revision  int64
+	Timestamp time.Time
+	// Cause is the change which triggered the snapshot
+	Cause string
+	// CauseNamespace is the namespace of the changed object, empty if the cause isn't a change of an object
+	CauseNamespace string
+	Snapshot       cache.ResourceSnapshot
+}
+
+type causeKey struct{}
+
+type snapshotCause struct {
+	cause     string
+	namespace string
+}
+
+// WithCause returns the context with the cause of snapshot changes, it is recorded in the snapshot history.
+func WithCause(ctx context.Context, cause string) context.Context {
+	return context.WithValue(ctx, causeKey{}, snapshotCause{cause: cause})
+}
+
+// WithObjectCause returns the context with the change of an object in the namespace as the cause of snapshot changes,
+// the namespace allows hiding the cause from users who can't read objects of the namespace.
+func WithObjectCause(ctx context.Context, cause, namespace string) context.Context {
+	return context.WithValue(ctx, causeKey{}, snapshotCause{cause: cause, namespace: namespace})
+}
+
+func causeFromContext(ctx context.Context) (string, string) {
+	c, _ := ctx.Value(causeKey{}).(snapshotCause)
+	return c.cause, c.namespace
+}
+
+type nodeHistory struct {
+	entries      []HistoryEntry
+	lastRevision int64
+	// latest is the last snapshot set for the node, it differs from the served one if the node is pinned
+	latest cache.ResourceSnapshot
+	// pinned is the revision the node is pinned to, 0 if not pinned
+	pinned int64
+}
+
+// GetLatestSnapshot returns the last snapshot set for the node, regardless of pinning.
+// New snapshots must be based on it, not on the served one.
+func (c *SnapshotCache) GetLatestSnapshot(nodeID string) (cache.ResourceSnapshot, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if h := c.history[nodeID]; h != nil && h.latest != nil {
+		return h.latest, nil
+	}
+	return c.SnapshotCache.GetSnapshot(nodeID)
+}
+
+// GetHistory returns snapshots of the node from the oldest to the newest and the pinned revision, 0 if not pinned.
+func (c *SnapshotCache) GetHistory(nodeID string) ([]HistoryEntry, int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	h := c.history[nodeID]
+	if h == nil {
+		return nil, 0
+	}
+	return append([]HistoryEntry(nil), h.entries...), h.pinned
+}
+
+// GetHistoryEntry returns the snapshot of the node with the revision.
+func (c *SnapshotCache) GetHistoryEntry(nodeID string, revision int64) (HistoryEntry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.getHistoryEntry(nodeID, revision)
+}
+
+// Pin serves the snapshot with the revision to the node until Unpin is called.
+// Snapshots set for the node in the meantime are recorded, but not served.
+func (c *SnapshotCache) Pin(ctx context.Context, nodeID string, revision int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, err := c.getHistoryEntry(nodeID, revision)
+	if err != nil {
+		return err
+	}
+	snapshot, err := withVersionSuffix(entry.Snapshot, pinnedVersionSuffix)
+	if err != nil {
+		return err
+	}
+	prev, _ := c.SnapshotCache.GetSnapshot(nodeID)
+	if err := c.SnapshotCache.SetSnapshot(ctx, nodeID, snapshot); err != nil {
+		return err
+	}
+	c.history[nodeID].pinned = revision
+	event := newSetEvent(EventPin, nodeID, prev, snapshot)
+	event.Cause, event.CauseNamespace = causeFromContext(ctx)
+	c.publish(event)
+	return nil
+}
+
+// Unpin releases the pin of the node and serves the last snapshot set for it.
+func (c *SnapshotCache) Unpin(ctx context.Context, nodeID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.history[nodeID]
+	if h == nil || h.pinned == 0 {
+		return fmt.Errorf("node %s is not pinned", nodeID)
+	}
+	prev, _ := c.SnapshotCache.GetSnapshot(nodeID)
+	if err := c.SnapshotCache.SetSnapshot(ctx, nodeID, h.latest); err != nil {
+		return err
+	}
+	h.pinned = 0
+	event := newSetEvent(EventUnpin, nodeID, prev, h.latest)
+	event.Cause, event.CauseNamespace = causeFromContext(ctx)
+	c.publish(event)
+	return nil
+}
+
+func (c *SnapshotCache) getHistoryEntry(nodeID string, revision int64) (HistoryEntry, error) {
+	if h := c.history[nodeID]; h != nil {
+		for _, entry := range h.entries {
+			if entry.Revision == revision {
+				return entry, nil
+			}
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("%w: node %s, revision %d", ErrSnapshotNotFound, nodeID, revision)
+}
+
+// record adds the snapshot to the node history and returns true if the node is pinned.
+func (c *SnapshotCache) record(ctx context.Context, nodeID string, snapshot cache.ResourceSnapshot) bool {
+	h := c.history[nodeID]
+	if h == nil {
+		h = &nodeHistory{}
+		c.history[nodeID] = h
+	}
+	h.latest = snapshot
+	if c.historySize > 0 {
+		h.lastRevision++
+		cause, causeNamespace := causeFromContext(ctx)
+		h.entries = append(h.entries, HistoryEntry{
+			Revision:       h.lastRevision,
+			Timestamp:      time.Now(),
+			Cause:          cause,
+			CauseNamespace: causeNamespace,
+			Snapshot:       snapshot,
+		})
+		if len(h.entries) > c.historySize {
+			// the pinned snapshot is served, so it isn't evicted
+			for i, entry := range h.entries {
+				if entry.Revision != h.pinned {
+					h.entries = append(h.entries[:i:i], h.entries[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return h.pinned != 0
+}
+
+func withVersionSuffix(snapshot cache.ResourceSnapshot, suffix string) (*cache.Snapshot, error) {
+	s, ok := snapshot.(*cache.Snapshot)
+	if !ok || s == nil {
+		return nil, errors.New("unsupported snapshot")
+	}
+	result := &cache.Snapshot{}
+	for i, resources := range s.Resources {
+		result.Resources[i] = cache.Resources{Version: resources.Version + suffix, Items: resources.Items}
+	}
+	return result, nil
+}
+
+// ResourceDiff is a difference of a resource between two snapshots.
+type ResourceDiff struct {
+	TypeURL string `json:"type_url"`
+	Name    string `json:"name"`
+	// Change is one of added, removed or changed
+	Change string          `json:"change"`
+	From   json.RawMessage `json:"from,omitempty"`
+	To     json.RawMessage `json:"to,omitempty"`
+}
+
+// DiffSnapshots returns differences of resources between the snapshots sorted by type URL and name.
+// Contents of secrets are never included.
+func DiffSnapshots(from, to cache.ResourceSnapshot) ([]ResourceDiff, error) {
+	var diffs []ResourceDiff
+	for i := types.ResponseType(0); i < types.UnknownType; i++ {
+		typeURL, err := cache.GetResponseTypeURL(i)
+		if err != nil {
+			return nil, err
+		}
+		fromResources := from.GetResources(typeURL)
+		toResources := to.GetResources(typeURL)
+
+		names := make([]string, 0, len(fromResources)+len(toResources))
+		for name := range fromResources {
+			names = append(names, name)
+		}
+		for name := range toResources {
+			if _, ok := fromResources[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fromRes, inFrom := fromResources[name]
+			toRes, inTo := toResources[name]
+			diff := ResourceDiff{TypeURL: typeURL, Name: name}
+			switch {
+			case !inFrom:
+				diff.Change = "added"
+			case !inTo:
+				diff.Change = "removed"
+			case proto.Equal(fromRes, toRes):
+				continue
+			default:
+				diff.Change = "changed"
+			}
+			if typeURL != resourcev3.SecretType {
+				if diff.From, err = marshalResource(fromRes); err != nil {
+					return nil, err
+				}
+				if diff.To, err = marshalResource(toRes); err != nil {
+					return nil, err
+				}
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+func marshalResource(res types.Resource) (json.RawMessage, error) {
+	if res == nil {
+		return nil, nil
+	}
+	return protojson.Marshal(res)
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+func newClusterSnapshot(t *testing.T, version string, clusters ...string) *cache.Snapshot {
+	t.Helper()
+	resources := make([]types.Resource, 0, len(clusters))
+	for _, name := range clusters {
+		resources = append(resources, &clusterv3.Cluster{Name: name})
+	}
+	snapshot, err := cache.NewSnapshot(version, map[resourcev3.Type][]types.Resource{resourcev3.ClusterType: resources})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestSnapshotHistory(t *testing.T) {
+	c := NewSnapshotCache(WithHistorySize(2))
+	ctx := context.Background()
+
+	for i, clusters := range [][]string{{"a"}, {"a", "b"}, {"b", "c"}} {
+		version := string(rune('1' + i))
+		if err := c.SetSnapshot(WithCause(ctx, "change "+version), "node", newClusterSnapshot(t, version, clusters...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, pinned := c.GetHistory("node")
+	if pinned != 0 {
+		t.Errorf("node must not be pinned")
+	}
+	if len(entries) != 2 || entries[0].Revision != 2 || entries[1].Revision != 3 || entries[1].Cause != "change 3" {
+		t.Fatalf("unexpected history %+v", entries)
+	}
+
+	diffs, err := DiffSnapshots(entries[0].Snapshot, entries[1].Snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].Name != "a" || diffs[0].Change != "removed" || diffs[1].Name != "c" || diffs[1].Change != "added" {
+		t.Errorf("unexpected diffs %+v", diffs)
+	}
+
+	// pinned node is served the old snapshot with distinct versions
+	if err := c.Pin(ctx, "node", 2); err != nil {
+		t.Fatal(err)
+	}
+	served, _ := c.GetSnapshot("node")
+	if served.GetVersion(resourcev3.ClusterType) != "2"+pinnedVersionSuffix {
+		t.Errorf("unexpected served version %s", served.GetVersion(resourcev3.ClusterType))
+	}
+
+	// new snapshots are recorded, but not served while pinned
+	if err := c.SetSnapshot(ctx, "node", newClusterSnapshot(t, "4", "d")); err != nil {
+		t.Fatal(err)
+	}
+	served, _ = c.GetSnapshot("node")
+	if _, ok := served.GetResources(resourcev3.ClusterType)["b"]; !ok {
+		t.Errorf("pinned snapshot must be served")
+	}
+	latest, _ := c.GetLatestSnapshot("node")
+	if latest.GetVersion(resourcev3.ClusterType) != "4" {
+		t.Errorf("latest snapshot must be the last one set")
+	}
+	if entries, _ := c.GetHistory("node"); entries[0].Revision != 2 || entries[1].Revision != 4 {
+		t.Errorf("pinned snapshot must not be evicted, got %+v", entries)
+	}
+
+	if err := c.Unpin(ctx, "node"); err != nil {
+		t.Fatal(err)
+	}
+	served, _ = c.GetSnapshot("node")
+	if served.GetVersion(resourcev3.ClusterType) != "4" {
+		t.Errorf("latest snapshot must be served after unpin")
+	}
+	if err := c.Pin(ctx, "node", 1); err == nil {
+		t.Errorf("pin to evicted revision must fail")
+	}
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// Secret redaction levels of snapshot views.
+const (
+	// SecretRedactionNone keeps secrets as they are served to Envoy
+	SecretRedactionNone = "none"
+	// SecretRedactionMetadata replaces private keys and other secret data, certificates are kept
+	SecretRedactionMetadata = "metadata"
+	// SecretRedactionFull keeps only names and types of secrets
+	SecretRedactionFull = "full"
+)
+
+// RedactedValue replaces private data of secrets, the same way Envoy does it in its config dump.
+const RedactedValue = "[redacted]"
+
+// ViewOptions define which resources of a snapshot are visible and how secrets are redacted.
+type ViewOptions struct {
+	// Include returns true if the resource is visible, all resources are visible if nil
+	Include func(typeURL, name string) bool
+	// IncludeFilterChain returns true if the filter chain of the visible listener is visible, all filter chains are visible if nil
+	IncludeFilterChain func(listener, filterChain string) bool
+	// SecretRedaction is the redaction level of secrets, SecretRedactionMetadata if empty
+	SecretRedaction string
+}
+
+// NewSnapshotView returns a copy of the snapshot with only visible resources and redacted secrets.
+// Resources are shared with the snapshot and must not be modified.
+func NewSnapshotView(snapshot cache.ResourceSnapshot, opts ViewOptions) cache.ResourceSnapshot {
+	view := &cache.Snapshot{}
+	for i := types.ResponseType(0); i < types.UnknownType; i++ {
+		typeURL, err := cache.GetResponseTypeURL(i)
+		if err != nil {
+			continue
+		}
+		resources := snapshot.GetResourcesAndTTL(typeURL)
+		items := make(map[string]types.ResourceWithTTL, len(resources))
+		for name, res := range resources {
+			if opts.Include != nil && !opts.Include(typeURL, name) {
+				continue
+			}
+			if secret, ok := res.Resource.(*tlsv3.Secret); ok && typeURL == resourcev3.SecretType {
+				res.Resource = RedactSecret(secret, opts.SecretRedaction)
+			}
+			if listener, ok := res.Resource.(*listenerv3.Listener); ok {
+				res.Resource = ListenerView(listener, opts.IncludeFilterChain)
+			}
+			items[name] = res
+		}
+		view.Resources[i] = cache.Resources{Version: snapshot.GetVersion(typeURL), Items: items}
+	}
+	return view
+}
+
+// ListenerView returns the listener with only visible filter chains, a copy if any filter chain isn't visible.
+// Listeners are shared by virtual services of many namespaces, each of them adds its filter chains.
+func ListenerView(listener *listenerv3.Listener, include func(listener, filterChain string) bool) *listenerv3.Listener {
+	if include == nil {
+		return listener
+	}
+	filterChains := make([]*listenerv3.FilterChain, 0, len(listener.FilterChains))
+	for _, fc := range listener.FilterChains {
+		if include(listener.Name, fc.Name) {
+			filterChains = append(filterChains, fc)
+		}
+	}
+	if len(filterChains) == len(listener.FilterChains) {
+		return listener
+	}
+	view := proto.Clone(listener).(*listenerv3.Listener)
+	view.FilterChains = filterChains
+	return view
+}
+
+// RedactSecret returns the secret redacted to the level, a copy if anything is redacted.
+func RedactSecret(secret *tlsv3.Secret, level string) *tlsv3.Secret {
+	switch level {
+	case SecretRedactionNone:
+		return secret
+	case SecretRedactionFull:
+		redacted := &tlsv3.Secret{Name: secret.Name}
+		switch secret.Type.(type) {
+		case *tlsv3.Secret_TlsCertificate:
+			redacted.Type = &tlsv3.Secret_TlsCertificate{}
+		case *tlsv3.Secret_SessionTicketKeys:
+			redacted.Type = &tlsv3.Secret_SessionTicketKeys{}
+		case *tlsv3.Secret_ValidationContext:
+			redacted.Type = &tlsv3.Secret_ValidationContext{}
+		case *tlsv3.Secret_GenericSecret:
+			redacted.Type = &tlsv3.Secret_GenericSecret{}
+		}
+		return redacted
+	}
+
+	secret = proto.Clone(secret).(*tlsv3.Secret)
+	switch s := secret.Type.(type) {
+	case *tlsv3.Secret_TlsCertificate:
+		s.TlsCertificate.PrivateKey = redactDataSource(s.TlsCertificate.PrivateKey)
+		s.TlsCertificate.Password = redactDataSource(s.TlsCertificate.Password)
+	case *tlsv3.Secret_SessionTicketKeys:
+		for i, key := range s.SessionTicketKeys.Keys {
+			s.SessionTicketKeys.Keys[i] = redactDataSource(key)
+		}
+	case *tlsv3.Secret_GenericSecret:
+		s.GenericSecret.Secret = redactDataSource(s.GenericSecret.Secret)
+	}
+	return secret
+}
+
+func redactDataSource(ds *corev3.DataSource) *corev3.DataSource {
+	if ds == nil {
+		return nil
+	}
+	return &corev3.DataSource{Specifier: &corev3.DataSource_InlineString{InlineString: RedactedValue}}
+}
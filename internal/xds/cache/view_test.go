@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+)
+
+func TestNewSnapshotView(t *testing.T) {
+	secret := &tlsv3.Secret{
+		Name: "default/cert",
+		Type: &tlsv3.Secret_TlsCertificate{TlsCertificate: &tlsv3.TlsCertificate{
+			CertificateChain: &corev3.DataSource{Specifier: &corev3.DataSource_InlineBytes{InlineBytes: []byte("cert")}},
+			PrivateKey:       &corev3.DataSource{Specifier: &corev3.DataSource_InlineBytes{InlineBytes: []byte("key")}},
+		}},
+	}
+	snapshot, err := cache.NewSnapshot("1", map[resourcev3.Type][]types.Resource{
+		resourcev3.ClusterType: {&clusterv3.Cluster{Name: "a/web"}, &clusterv3.Cluster{Name: "b/web"}},
+		resourcev3.SecretType:  {secret},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       ViewOptions
+		clusters   int
+		secrets    int
+		chain      string
+		privateKey string
+	}{
+		{
+			name:       "default",
+			clusters:   2,
+			secrets:    1,
+			chain:      "cert",
+			privateKey: RedactedValue,
+		},
+		{
+			name:       "no redaction",
+			opts:       ViewOptions{SecretRedaction: SecretRedactionNone},
+			clusters:   2,
+			secrets:    1,
+			chain:      "cert",
+			privateKey: "key",
+		},
+		{
+			name:     "full redaction",
+			opts:     ViewOptions{SecretRedaction: SecretRedactionFull},
+			clusters: 2,
+			secrets:  1,
+		},
+		{
+			name: "filtered",
+			opts: ViewOptions{Include: func(typeURL, name string) bool {
+				return typeURL == resourcev3.ClusterType && name == "a/web"
+			}},
+			clusters: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view := NewSnapshotView(snapshot, tt.opts)
+			if got := len(view.GetResources(resourcev3.ClusterType)); got != tt.clusters {
+				t.Errorf("expected %d clusters, got %d", tt.clusters, got)
+			}
+			if view.GetVersion(resourcev3.ClusterType) != "1" {
+				t.Errorf("versions must be kept")
+			}
+			secrets := view.GetResources(resourcev3.SecretType)
+			if len(secrets) != tt.secrets {
+				t.Fatalf("expected %d secrets, got %d", tt.secrets, len(secrets))
+			}
+			if tt.secrets == 0 {
+				return
+			}
+			viewed := secrets[secret.Name].(*tlsv3.Secret)
+			if _, ok := viewed.Type.(*tlsv3.Secret_TlsCertificate); !ok {
+				t.Fatal("secret type must be kept")
+			}
+			if got := string(viewed.GetTlsCertificate().GetCertificateChain().GetInlineBytes()); got != tt.chain {
+				t.Errorf("expected certificate chain %q, got %q", tt.chain, got)
+			}
+			privateKey := viewed.GetTlsCertificate().GetPrivateKey()
+			if got := privateKey.GetInlineString() + string(privateKey.GetInlineBytes()); got != tt.privateKey {
+				t.Errorf("expected private key %q, got %q", tt.privateKey, got)
+			}
+		})
+	}
+	if string(secret.GetTlsCertificate().GetPrivateKey().GetInlineBytes()) != "key" {
+		t.Errorf("snapshot secret must not be modified")
+	}
+}
+
+func TestListenerView(t *testing.T) {
+	listener := &listenerv3.Listener{
+		Name:         "default/https",
+		FilterChains: []*listenerv3.FilterChain{{Name: "a/web"}, {Name: "b/web"}},
+	}
+	onlyA := func(_, filterChain string) bool { return filterChain == "a/web" }
+
+	if view := ListenerView(listener, nil); view != listener {
+		t.Error("listener must be returned as is without the filter")
+	}
+	if view := ListenerView(listener, func(string, string) bool { return true }); view != listener {
+		t.Error("listener must be returned as is if all filter chains are visible")
+	}
+	view := ListenerView(listener, onlyA)
+	if len(view.FilterChains) != 1 || view.FilterChains[0].Name != "a/web" {
+		t.Errorf("expected only the a/web filter chain, got %v", view.FilterChains)
+	}
+	if len(listener.FilterChains) != 2 {
+		t.Error("listener must not be changed")
+	}
+
+	snapshot, err := cache.NewSnapshot("1", map[resourcev3.Type][]types.Resource{
+		resourcev3.ListenerType: {listener},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := NewSnapshotView(snapshot, ViewOptions{IncludeFilterChain: onlyA}).GetResources(resourcev3.ListenerType)
+	if fcs := res["default/https"].(*listenerv3.Listener).FilterChains; len(fcs) != 1 {
+		t.Errorf("expected 1 filter chain in the snapshot view, got %d", len(fcs))
+	}
+}
@@ -19,20 +19,58 @@ type SnapshotCache struct {
 	cache.SnapshotCache
 	mu      sync.RWMutex
 	nodeIDs map[string]struct{}
+
+	historySize int
+	history     map[string]*nodeHistory
+
+	subscribersMu sync.Mutex
+	subscribers   map[*subscriber]struct{}
+}
+
+type Option func(*SnapshotCache)
+
+// WithHistorySize sets the number of snapshots kept per node, history is disabled if 0.
+func WithHistorySize(size int) Option {
+	return func(c *SnapshotCache) {
+		c.historySize = size
+	}
 }
 
-func NewSnapshotCache() *SnapshotCache {
-	return &SnapshotCache{
+func NewSnapshotCache(opts ...Option) *SnapshotCache {
+	c := &SnapshotCache{
 		SnapshotCache: cache.NewSnapshotCache(false, cache.IDHash{}, nil),
 		nodeIDs:       make(map[string]struct{}),
+		historySize:   DefaultHistorySize,
+		history:       make(map[string]*nodeHistory),
+		subscribers:   make(map[*subscriber]struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
+// SetSnapshot records the snapshot in the node history and serves it, unless the node is pinned.
 func (c *SnapshotCache) SetSnapshot(ctx context.Context, nodeID string, snapshot cache.ResourceSnapshot) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.nodeIDs[nodeID] = struct{}{}
-	return c.SnapshotCache.SetSnapshot(ctx, nodeID, snapshot)
+	var prev cache.ResourceSnapshot
+	if h := c.history[nodeID]; h != nil {
+		prev = h.latest
+	}
+	event := newSetEvent(EventSet, nodeID, prev, snapshot)
+	event.Cause, event.CauseNamespace = causeFromContext(ctx)
+	if pinned := c.record(ctx, nodeID, snapshot); pinned {
+		event.Pinned = true
+		c.publish(event)
+		return nil
+	}
+	if err := c.SnapshotCache.SetSnapshot(ctx, nodeID, snapshot); err != nil {
+		return err
+	}
+	c.publish(event)
+	return nil
 }
 
 func (c *SnapshotCache) GetSnapshot(nodeID string) (cache.ResourceSnapshot, error) {
@@ -45,7 +83,9 @@ func (c *SnapshotCache) ClearSnapshot(nodeID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	delete(c.nodeIDs, nodeID)
+	delete(c.history, nodeID)
 	c.SnapshotCache.ClearSnapshot(nodeID)
+	c.publish(SnapshotEvent{Type: EventClear, NodeID: nodeID})
 }
 
 func (c *SnapshotCache) GetNodeIDsAsMap() map[string]struct{} {
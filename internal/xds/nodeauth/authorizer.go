@@ -0,0 +1,116 @@
+package nodeauth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Identity is the identity of an xDS client taken from its TLS certificate.
+type Identity struct {
+	// DNSNames are DNS SANs of the certificate
+	DNSNames []string
+	// URIs are URI SANs of the certificate, including SPIFFE IDs
+	URIs []string
+}
+
+func IdentityFromCertificate(cert *x509.Certificate) Identity {
+	identity := Identity{DNSNames: append([]string(nil), cert.DNSNames...)}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity
+}
+
+// Names returns all names of the identity.
+func (i Identity) Names() []string {
+	return append(append([]string(nil), i.URIs...), i.DNSNames...)
+}
+
+func (i Identity) String() string {
+	return strings.Join(i.Names(), ",")
+}
+
+// Authorizer checks that the client with the identity is allowed to request resources of the node ID.
+type Authorizer interface {
+	Authorize(identity Identity, nodeID string) error
+}
+
+// AllowAll allows any identity to request any node ID.
+type AllowAll struct{}
+
+func (AllowAll) Authorize(Identity, string) error {
+	return nil
+}
+
+// IdentityAuthorizer authorizes node IDs by rules, where the key is an identity pattern
+// (DNS SAN or URI SAN, e.g. SPIFFE ID) and the value is a list of allowed node ID patterns.
+// Patterns support "*" wildcard, which matches any sequence of characters.
+// The node ID pattern "{name}" allows the node ID equal to the matched identity name.
+type IdentityAuthorizer struct {
+	rules map[string][]string
+}
+
+const identityNamePlaceholder = "{name}"
+
+func NewIdentityAuthorizer(rules map[string][]string) *IdentityAuthorizer {
+	return &IdentityAuthorizer{rules: rules}
+}
+
+// ParseIdentityAuthorizer creates IdentityAuthorizer from JSON rules, e.g.
+// {"spiffe://cluster.local/ns/envoy/sa/*": ["envoy-*"], "proxy.example.com": ["{name}"]}
+func ParseIdentityAuthorizer(data string) (*IdentityAuthorizer, error) {
+	rules := make(map[string][]string)
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse authorization rules: %w", err)
+	}
+	return NewIdentityAuthorizer(rules), nil
+}
+
+func (a *IdentityAuthorizer) Authorize(identity Identity, nodeID string) error {
+	names := identity.Names()
+	if len(names) == 0 {
+		return fmt.Errorf("client certificate has no SANs, node ID %s is not allowed", nodeID)
+	}
+	for _, name := range names {
+		for identityPattern, nodeIDPatterns := range a.rules {
+			if !matchPattern(identityPattern, name) {
+				continue
+			}
+			for _, nodeIDPattern := range nodeIDPatterns {
+				if nodeIDPattern == identityNamePlaceholder {
+					if nodeID == name {
+						return nil
+					}
+					continue
+				}
+				if matchPattern(nodeIDPattern, nodeID) {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("identity %s is not allowed to request node ID %s", identity.String(), nodeID)
+}
+
+// matchPattern matches the value with the pattern, where "*" matches any sequence of characters.
+func matchPattern(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, part)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(part):]
+	}
+	return strings.HasSuffix(value, last)
+}
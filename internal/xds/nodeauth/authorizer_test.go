@@ -0,0 +1,100 @@
+package nodeauth
+
+import "testing"
+
+func TestIdentityAuthorizer(t *testing.T) {
+	authorizer, err := ParseIdentityAuthorizer(`{
+		"spiffe://cluster.local/ns/envoy/sa/*": ["envoy-*"],
+		"proxy.example.com": ["{name}", "edge"]
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		identity Identity
+		nodeID   string
+		allowed  bool
+	}{
+		{
+			name:     "spiffe id with wildcard",
+			identity: Identity{URIs: []string{"spiffe://cluster.local/ns/envoy/sa/gateway"}},
+			nodeID:   "envoy-gateway",
+			allowed:  true,
+		},
+		{
+			name:     "spiffe id with not allowed node",
+			identity: Identity{URIs: []string{"spiffe://cluster.local/ns/envoy/sa/gateway"}},
+			nodeID:   "gateway",
+			allowed:  false,
+		},
+		{
+			name:     "spiffe id from other namespace",
+			identity: Identity{URIs: []string{"spiffe://cluster.local/ns/other/sa/gateway"}},
+			nodeID:   "envoy-gateway",
+			allowed:  false,
+		},
+		{
+			name:     "dns name equal to node id",
+			identity: Identity{DNSNames: []string{"proxy.example.com"}},
+			nodeID:   "proxy.example.com",
+			allowed:  true,
+		},
+		{
+			name:     "dns name with explicit node id",
+			identity: Identity{DNSNames: []string{"proxy.example.com"}},
+			nodeID:   "edge",
+			allowed:  true,
+		},
+		{
+			name:     "unknown dns name",
+			identity: Identity{DNSNames: []string{"other.example.com"}},
+			nodeID:   "other.example.com",
+			allowed:  false,
+		},
+		{
+			name:     "no names",
+			identity: Identity{},
+			nodeID:   "edge",
+			allowed:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := authorizer.Authorize(tc.identity, tc.nodeID)
+			if tc.allowed && err != nil {
+				t.Errorf("expected allowed, got %v", err)
+			}
+			if !tc.allowed && err == nil {
+				t.Errorf("expected denied")
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"a", "a", true},
+		{"a", "b", false},
+		{"*", "anything", true},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"a*c", "abc", true},
+		{"a*c", "ab", false},
+		{"a*b*c", "a-b-c", true},
+		{"a*b*c", "a-c-b", false},
+		{"ab*ba", "aba", false},
+	}
+
+	for _, tc := range testCases {
+		if got := matchPattern(tc.pattern, tc.value); got != tc.match {
+			t.Errorf("matchPattern(%q, %q): expected %v, got %v", tc.pattern, tc.value, tc.match, got)
+		}
+	}
+}
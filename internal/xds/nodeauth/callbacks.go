@@ -0,0 +1,162 @@
+package nodeauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Callbacks enforce the Authorizer on xDS streams and fetch requests and delegate to the wrapped callbacks.
+// The identity of a stream is taken from the client certificate when the stream is opened,
+// the node ID of a stream is fixed by its first request.
+type Callbacks struct {
+	server.Callbacks
+
+	authorizer Authorizer
+	log        logr.Logger
+
+	mu      sync.Mutex
+	streams map[int64]*streamState
+}
+
+type streamState struct {
+	identity *Identity
+	nodeID   string
+}
+
+var _ server.Callbacks = &Callbacks{}
+
+func NewCallbacks(callbacks server.Callbacks, authorizer Authorizer, log logr.Logger) *Callbacks {
+	return &Callbacks{
+		Callbacks:  callbacks,
+		authorizer: authorizer,
+		log:        log,
+		streams:    make(map[int64]*streamState),
+	}
+}
+
+func (c *Callbacks) OnStreamOpen(ctx context.Context, streamID int64, typeURL string) error {
+	c.openStream(ctx, streamID)
+	return c.Callbacks.OnStreamOpen(ctx, streamID, typeURL)
+}
+
+func (c *Callbacks) OnDeltaStreamOpen(ctx context.Context, streamID int64, typeURL string) error {
+	c.openStream(ctx, streamID)
+	return c.Callbacks.OnDeltaStreamOpen(ctx, streamID, typeURL)
+}
+
+func (c *Callbacks) OnStreamClosed(streamID int64, node *corev3.Node) {
+	c.closeStream(streamID)
+	c.Callbacks.OnStreamClosed(streamID, node)
+}
+
+func (c *Callbacks) OnDeltaStreamClosed(streamID int64, node *corev3.Node) {
+	c.closeStream(streamID)
+	c.Callbacks.OnDeltaStreamClosed(streamID, node)
+}
+
+func (c *Callbacks) OnStreamRequest(streamID int64, req *discoveryv3.DiscoveryRequest) error {
+	if err := c.authorizeStream(streamID, req.GetNode(), req.GetTypeUrl()); err != nil {
+		return err
+	}
+	return c.Callbacks.OnStreamRequest(streamID, req)
+}
+
+func (c *Callbacks) OnStreamDeltaRequest(streamID int64, req *discoveryv3.DeltaDiscoveryRequest) error {
+	if err := c.authorizeStream(streamID, req.GetNode(), req.GetTypeUrl()); err != nil {
+		return err
+	}
+	return c.Callbacks.OnStreamDeltaRequest(streamID, req)
+}
+
+func (c *Callbacks) OnFetchRequest(ctx context.Context, req *discoveryv3.DiscoveryRequest) error {
+	identity, err := identityFromContext(ctx)
+	if err == nil {
+		err = c.authorizer.Authorize(*identity, req.GetNode().GetId())
+	}
+	if err != nil {
+		c.log.Info("Rejected xDS fetch request", "nodeID", req.GetNode().GetId(), "typeURL", req.GetTypeUrl(), "reason", err.Error())
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return c.Callbacks.OnFetchRequest(ctx, req)
+}
+
+func (c *Callbacks) openStream(ctx context.Context, streamID int64) {
+	state := &streamState{}
+	if identity, err := identityFromContext(ctx); err == nil {
+		state.identity = identity
+	}
+	c.mu.Lock()
+	c.streams[streamID] = state
+	c.mu.Unlock()
+}
+
+func (c *Callbacks) closeStream(streamID int64) {
+	c.mu.Lock()
+	delete(c.streams, streamID)
+	c.mu.Unlock()
+}
+
+func (c *Callbacks) authorizeStream(streamID int64, node *corev3.Node, typeURL string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.streams[streamID]
+	if !ok {
+		return status.Error(codes.Internal, "unknown stream")
+	}
+
+	var err error
+	switch {
+	case state.identity == nil:
+		err = errors.New("client certificate is required")
+	case state.nodeID == "":
+		// the node is required in the first request of the stream
+		if node.GetId() == "" {
+			err = errors.New("node ID is required")
+			break
+		}
+		if err = c.authorizer.Authorize(*state.identity, node.GetId()); err == nil {
+			state.nodeID = node.GetId()
+		}
+	case node.GetId() != "" && node.GetId() != state.nodeID:
+		err = fmt.Errorf("node ID of the stream is changed from %s to %s", state.nodeID, node.GetId())
+	}
+
+	if err != nil {
+		identity := ""
+		if state.identity != nil {
+			identity = state.identity.String()
+		}
+		c.log.Info("Rejected xDS stream", "streamID", streamID, "nodeID", node.GetId(), "typeURL", typeURL,
+			"identity", identity, "reason", err.Error())
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+func identityFromContext(ctx context.Context) (*Identity, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, errors.New("no peer in context")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, errors.New("connection is not TLS")
+	}
+	if len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, errors.New("client certificate is not verified")
+	}
+	identity := IdentityFromCertificate(tlsInfo.State.VerifiedChains[0][0])
+	return &identity, nil
+}
@@ -0,0 +1,112 @@
+package nodeauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CACertKey is the key of the CA bundle used to verify client certificates.
+	CACertKey = "ca.crt"
+
+	DefaultCertificateRefreshPeriod = time.Minute
+)
+
+// SecretCertificateSource provides the server certificate and client CAs from a kubernetes.io/tls Secret.
+// The Secret is reread not more often than the refresh period, so rotated certificates are picked up
+// by new connections without restart.
+type SecretCertificateSource struct {
+	reader        client.Reader
+	secret        types.NamespacedName
+	refreshPeriod time.Duration
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	loadedAt time.Time
+}
+
+func NewSecretCertificateSource(reader client.Reader, secret types.NamespacedName, refreshPeriod time.Duration) *SecretCertificateSource {
+	if refreshPeriod <= 0 {
+		refreshPeriod = DefaultCertificateRefreshPeriod
+	}
+	return &SecretCertificateSource{reader: reader, secret: secret, refreshPeriod: refreshPeriod}
+}
+
+// TLSConfig returns TLS config for the xDS server.
+// If requireClientCert is set, clients must present a certificate signed by ca.crt of the Secret.
+func (s *SecretCertificateSource) TLSConfig(ctx context.Context, requireClientCert bool) (*tls.Config, error) {
+	if _, _, err := s.get(ctx); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCA, err := s.get(hello.Context())
+			if err != nil {
+				return nil, err
+			}
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if requireClientCert {
+				if clientCA == nil {
+					return nil, fmt.Errorf("secret %s has no %s key", s.secret.String(), CACertKey)
+				}
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = clientCA
+			}
+			return cfg, nil
+		},
+	}, nil
+}
+
+func (s *SecretCertificateSource) get(ctx context.Context) (*tls.Certificate, *x509.CertPool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cert != nil && time.Since(s.loadedAt) < s.refreshPeriod {
+		return s.cert, s.clientCA, nil
+	}
+
+	cert, clientCA, err := s.load(ctx)
+	if err != nil {
+		if s.cert != nil {
+			// keep serving the previous certificate
+			return s.cert, s.clientCA, nil
+		}
+		return nil, nil, err
+	}
+	s.cert, s.clientCA, s.loadedAt = cert, clientCA, time.Now()
+	return s.cert, s.clientCA, nil
+}
+
+func (s *SecretCertificateSource) load(ctx context.Context) (*tls.Certificate, *x509.CertPool, error) {
+	var secret v1.Secret
+	if err := s.reader.Get(ctx, s.secret, &secret); err != nil {
+		return nil, nil, fmt.Errorf("failed to get xDS server TLS secret %s: %w", s.secret.String(), err)
+	}
+	cert, err := tls.X509KeyPair(secret.Data[v1.TLSCertKey], secret.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse xDS server TLS secret %s: %w", s.secret.String(), err)
+	}
+	var clientCA *x509.CertPool
+	if caData := secret.Data[CACertKey]; len(caData) > 0 {
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(caData) {
+			return nil, nil, errors.New("failed to parse " + CACertKey + " of xDS server TLS secret " + s.secret.String())
+		}
+	}
+	return &cert, clientCA, nil
+}
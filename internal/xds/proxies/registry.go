@@ -0,0 +1,190 @@
+package proxies
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc/peer"
+)
+
+// Proxy is a connected Envoy xDS stream.
+type Proxy struct {
+	StreamID     int64     `json:"stream_id"`
+	Delta        bool      `json:"delta"`
+	NodeID       string    `json:"node_id"`
+	Cluster      string    `json:"cluster,omitempty"`
+	Locality     *Locality `json:"locality,omitempty"`
+	BuildVersion string    `json:"build_version,omitempty"`
+	PeerAddress  string    `json:"peer_address,omitempty"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	// AckedVersions are the last versions ACKed by the proxy per type URL
+	AckedVersions map[string]string `json:"acked_versions"`
+}
+
+type Locality struct {
+	Region  string `json:"region,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	SubZone string `json:"sub_zone,omitempty"`
+}
+
+// Registry tracks the connected proxies using xDS callbacks and delegates to the wrapped callbacks.
+type Registry struct {
+	server.Callbacks
+
+	mu      sync.RWMutex
+	streams map[int64]*stream
+}
+
+type stream struct {
+	proxy Proxy
+	// versions of delta responses by nonce, delta requests don't contain the version
+	deltaNonces map[string]string
+}
+
+var _ server.Callbacks = &Registry{}
+
+func NewRegistry(callbacks server.Callbacks) *Registry {
+	return &Registry{
+		Callbacks: callbacks,
+		streams:   make(map[int64]*stream),
+	}
+}
+
+func (r *Registry) OnStreamOpen(ctx context.Context, streamID int64, typeURL string) error {
+	r.openStream(ctx, streamID, false)
+	return r.Callbacks.OnStreamOpen(ctx, streamID, typeURL)
+}
+
+func (r *Registry) OnDeltaStreamOpen(ctx context.Context, streamID int64, typeURL string) error {
+	r.openStream(ctx, streamID, true)
+	return r.Callbacks.OnDeltaStreamOpen(ctx, streamID, typeURL)
+}
+
+func (r *Registry) OnStreamClosed(streamID int64, node *corev3.Node) {
+	r.closeStream(streamID)
+	r.Callbacks.OnStreamClosed(streamID, node)
+}
+
+func (r *Registry) OnDeltaStreamClosed(streamID int64, node *corev3.Node) {
+	r.closeStream(streamID)
+	r.Callbacks.OnDeltaStreamClosed(streamID, node)
+}
+
+func (r *Registry) OnStreamRequest(streamID int64, req *discoveryv3.DiscoveryRequest) error {
+	r.mu.Lock()
+	if s, ok := r.streams[streamID]; ok {
+		s.setNode(req.GetNode())
+		if req.GetResponseNonce() != "" && req.GetErrorDetail() == nil {
+			s.proxy.AckedVersions[req.GetTypeUrl()] = req.GetVersionInfo()
+		}
+	}
+	r.mu.Unlock()
+	return r.Callbacks.OnStreamRequest(streamID, req)
+}
+
+func (r *Registry) OnStreamDeltaRequest(streamID int64, req *discoveryv3.DeltaDiscoveryRequest) error {
+	r.mu.Lock()
+	if s, ok := r.streams[streamID]; ok {
+		s.setNode(req.GetNode())
+		if nonce := req.GetResponseNonce(); nonce != "" {
+			if version, ok := s.deltaNonces[nonce]; ok && req.GetErrorDetail() == nil {
+				s.proxy.AckedVersions[req.GetTypeUrl()] = version
+			}
+			delete(s.deltaNonces, nonce)
+		}
+	}
+	r.mu.Unlock()
+	return r.Callbacks.OnStreamDeltaRequest(streamID, req)
+}
+
+func (r *Registry) OnStreamDeltaResponse(streamID int64, req *discoveryv3.DeltaDiscoveryRequest, resp *discoveryv3.DeltaDiscoveryResponse) {
+	r.mu.Lock()
+	if s, ok := r.streams[streamID]; ok {
+		s.deltaNonces[resp.GetNonce()] = resp.GetSystemVersionInfo()
+	}
+	r.mu.Unlock()
+	r.Callbacks.OnStreamDeltaResponse(streamID, req, resp)
+}
+
+// List returns the connected proxies which sent their node, sorted by node ID and stream ID.
+func (r *Registry) List() []Proxy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	proxies := make([]Proxy, 0, len(r.streams))
+	for _, s := range r.streams {
+		if s.proxy.NodeID == "" {
+			continue
+		}
+		proxy := s.proxy
+		proxy.AckedVersions = make(map[string]string, len(s.proxy.AckedVersions))
+		for typeURL, version := range s.proxy.AckedVersions {
+			proxy.AckedVersions[typeURL] = version
+		}
+		proxies = append(proxies, proxy)
+	}
+	sort.Slice(proxies, func(i, j int) bool {
+		if proxies[i].NodeID != proxies[j].NodeID {
+			return proxies[i].NodeID < proxies[j].NodeID
+		}
+		return proxies[i].StreamID < proxies[j].StreamID
+	})
+	return proxies
+}
+
+func (r *Registry) openStream(ctx context.Context, streamID int64, delta bool) {
+	s := &stream{
+		proxy: Proxy{
+			StreamID:      streamID,
+			Delta:         delta,
+			ConnectedAt:   time.Now(),
+			AckedVersions: make(map[string]string),
+		},
+	}
+	if delta {
+		s.deltaNonces = make(map[string]string)
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		s.proxy.PeerAddress = p.Addr.String()
+	}
+	r.mu.Lock()
+	r.streams[streamID] = s
+	r.mu.Unlock()
+}
+
+func (r *Registry) closeStream(streamID int64) {
+	r.mu.Lock()
+	delete(r.streams, streamID)
+	r.mu.Unlock()
+}
+
+// setNode sets the node of the stream from the first request, next requests may omit it.
+func (s *stream) setNode(node *corev3.Node) {
+	if node == nil || s.proxy.NodeID != "" {
+		return
+	}
+	s.proxy.NodeID = node.GetId()
+	s.proxy.Cluster = node.GetCluster()
+	if l := node.GetLocality(); l != nil {
+		s.proxy.Locality = &Locality{Region: l.GetRegion(), Zone: l.GetZone(), SubZone: l.GetSubZone()}
+	}
+	s.proxy.BuildVersion = buildVersion(node)
+}
+
+func buildVersion(node *corev3.Node) string {
+	if bv := node.GetUserAgentBuildVersion(); bv != nil {
+		v := bv.GetVersion()
+		version := fmt.Sprintf("%d.%d.%d", v.GetMajorNumber(), v.GetMinorNumber(), v.GetPatch())
+		if label, ok := bv.GetMetadata().GetFields()["build.label"]; ok && label.GetStringValue() != "" {
+			version += "/" + label.GetStringValue()
+		}
+		return version
+	}
+	return node.GetUserAgentVersion()
+}
@@ -0,0 +1,85 @@
+package proxies
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/test/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/peer"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(&test.Callbacks{})
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40000}})
+	node := &corev3.Node{
+		Id:                   "node1",
+		Cluster:              "edge",
+		Locality:             &corev3.Locality{Zone: "a"},
+		UserAgentVersionType: &corev3.Node_UserAgentVersion{UserAgentVersion: "1.31.0"},
+	}
+
+	// SotW stream
+	if err := r.OnStreamOpen(ctx, 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(t, r, 1, &discoveryv3.DiscoveryRequest{Node: node, TypeUrl: resourcev3.ListenerType})
+	mustRequest(t, r, 1, &discoveryv3.DiscoveryRequest{TypeUrl: resourcev3.ListenerType, VersionInfo: "1", ResponseNonce: "a"})
+	mustRequest(t, r, 1, &discoveryv3.DiscoveryRequest{TypeUrl: resourcev3.ClusterType, VersionInfo: "2", ResponseNonce: "b"})
+	// NACK keeps the previous ACKed version
+	mustRequest(t, r, 1, &discoveryv3.DiscoveryRequest{
+		TypeUrl: resourcev3.ListenerType, VersionInfo: "1", ResponseNonce: "c", ErrorDetail: &status.Status{Message: "invalid"},
+	})
+
+	// Delta stream
+	if err := r.OnDeltaStreamOpen(ctx, 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.OnStreamDeltaRequest(2, &discoveryv3.DeltaDiscoveryRequest{Node: &corev3.Node{Id: "node0"}, TypeUrl: resourcev3.SecretType}); err != nil {
+		t.Fatal(err)
+	}
+	r.OnStreamDeltaResponse(2, nil, &discoveryv3.DeltaDiscoveryResponse{Nonce: "n1", SystemVersionInfo: "5", TypeUrl: resourcev3.SecretType})
+	if err := r.OnStreamDeltaRequest(2, &discoveryv3.DeltaDiscoveryRequest{TypeUrl: resourcev3.SecretType, ResponseNonce: "n1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stream without node is not listed
+	if err := r.OnStreamOpen(ctx, 3, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	proxies := r.List()
+	if len(proxies) != 2 {
+		t.Fatalf("expected 2 proxies, got %d", len(proxies))
+	}
+	if proxies[0].NodeID != "node0" || !proxies[0].Delta || proxies[0].AckedVersions[resourcev3.SecretType] != "5" {
+		t.Errorf("unexpected delta proxy: %+v", proxies[0])
+	}
+	p := proxies[1]
+	if p.NodeID != "node1" || p.Cluster != "edge" || p.Locality.Zone != "a" || p.BuildVersion != "1.31.0" {
+		t.Errorf("unexpected node info: %+v", p)
+	}
+	if p.PeerAddress != "10.0.0.1:40000" {
+		t.Errorf("unexpected peer address %s", p.PeerAddress)
+	}
+	if p.AckedVersions[resourcev3.ListenerType] != "1" || p.AckedVersions[resourcev3.ClusterType] != "2" {
+		t.Errorf("unexpected ACKed versions: %v", p.AckedVersions)
+	}
+
+	r.OnStreamClosed(1, node)
+	r.OnDeltaStreamClosed(2, nil)
+	if proxies := r.List(); len(proxies) != 0 {
+		t.Errorf("expected no proxies after close, got %v", proxies)
+	}
+}
+
+func mustRequest(t *testing.T, r *Registry, streamID int64, req *discoveryv3.DiscoveryRequest) {
+	t.Helper()
+	if err := r.OnStreamRequest(streamID, req); err != nil {
+		t.Fatal(err)
+	}
+}
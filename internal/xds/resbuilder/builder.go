@@ -1,6 +1,7 @@
 package resbuilder
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	oauth2v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3"
+	ondemandv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/on_demand/v3"
 
 	accesslogv3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
@@ -25,6 +27,7 @@ import (
 	"github.com/kaasops/envoy-xds-controller/internal/helpers"
 	"github.com/kaasops/envoy-xds-controller/internal/protoutil"
 	"github.com/kaasops/envoy-xds-controller/internal/store"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	v1 "k8s.io/api/core/v1"
@@ -47,7 +50,11 @@ type FilterChainsParams struct {
 	Domains              []string
 	DownstreamTLSContext *tlsv3.DownstreamTlsContext
 	SecretNameToDomains  map[helpers.NamespacedName][]string
-	IsTLS                bool
+	// ValidationContext is the Secret with CA of client certificates
+	ValidationContext *helpers.NamespacedName
+	// ValidationContextName is the name of the Envoy validation context built from ValidationContext
+	ValidationContextName string
+	IsTLS                 bool
 }
 
 type Resources struct {
@@ -56,18 +63,27 @@ type Resources struct {
 	RouteConfig *routev3.RouteConfiguration
 	Clusters    []*cluster.Cluster
 	Secrets     []*tlsv3.Secret
+	// ExtensionConfigs are configs of http filters delivered over ECDS
+	ExtensionConfigs []*corev3.TypedExtensionConfig
+	// VirtualHosts are virtual hosts delivered over VHDS
+	VirtualHosts []*routev3.VirtualHost
+	// Provenance describes objects which produced the resources
+	Provenance *Provenance
 }
 
 // nolint: gocyclo
-func BuildResources(vs *v1alpha1.VirtualService, store *store.Store) (*Resources, []helpers.NamespacedName, error) {
+func BuildResources(ctx context.Context, vs *v1alpha1.VirtualService, store *store.Store) (*Resources, []helpers.NamespacedName, error) {
 	var err error
 	nn := helpers.NamespacedName{Namespace: vs.Namespace, Name: vs.Name}
 
+	provenance := newProvenance(vs, nil)
 	if vs.Spec.Template != nil {
 		vst, ok := store.VirtualServiceTemplates[helpers.NamespacedName{Namespace: helpers.GetNamespace(vs.Spec.Template.Namespace, vs.Namespace), Name: vs.Spec.Template.Name}]
 		if !ok {
 			return nil, nil, fmt.Errorf("virtual service template %s/%s not found", helpers.GetNamespace(vs.Spec.Template.Namespace, vs.Namespace), vs.Spec.Template.Name)
 		}
+		// template fields are computed before the virtual service is filled
+		provenance = newProvenance(vs, vst)
 		vs = vs.DeepCopy()
 		err = vs.FillFromTemplate(vst, vs.Spec.TemplateOptions...)
 		if err != nil {
@@ -156,11 +172,13 @@ func BuildResources(vs *v1alpha1.VirtualService, store *store.Store) (*Resources
 			}
 		}
 
-		return &Resources{
+		res := &Resources{
 			Listener:    listenerNN,
 			FilterChain: xdsListener.FilterChains,
 			Clusters:    clusters,
-		}, nil, nil
+		}
+		res.Provenance = buildProvenance(vs, provenance, res, store)
+		return res, nil, nil
 	}
 
 	listenerIsTLS := isTLSListener(xdsListener)
@@ -181,8 +199,18 @@ func BuildResources(vs *v1alpha1.VirtualService, store *store.Store) (*Resources
 			RequestHeadersToAdd: virtualHost.RequestHeadersToAdd,
 		}},
 	}
+	var vhdsVirtualHosts []*routev3.VirtualHost
+	listener := store.Listeners[listenerNN]
+	if listener == nil {
+		return nil, nil, fmt.Errorf("listener %s not found", listenerNN.String())
+	}
+	listenerIsVHDS := listener.IsVHDS()
+	if listenerIsVHDS {
+		routeConfiguration, vhdsVirtualHosts = buildVHDSRouteConfiguration(routeConfiguration)
+	}
 	// https://github.com/envoyproxy/envoy/issues/37810
-	if listenerIsTLS && !(len(virtualHost.Domains) == 1 && virtualHost.Domains[0] == "*") {
+	// the catch-all virtual host is skipped with VHDS, otherwise Envoy never requests virtual hosts on demand
+	if listenerIsTLS && !listenerIsVHDS && !(len(virtualHost.Domains) == 1 && virtualHost.Domains[0] == "*") {
 		routeConfiguration.VirtualHosts = append(routeConfiguration.VirtualHosts, &routev3.VirtualHost{
 			Name:    "421vh",
 			Domains: []string{"*"},
@@ -201,13 +229,24 @@ func BuildResources(vs *v1alpha1.VirtualService, store *store.Store) (*Resources
 	if err = routeConfiguration.ValidateAll(); err != nil {
 		return nil, nil, err
 	}
+	for _, vh := range vhdsVirtualHosts {
+		if err = vh.ValidateAll(); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Listener ---
 
-	httpFilters, err := buildHTTPFilters(vs, store)
+	httpFilters, extensionConfigs, err := buildHTTPFilters(vs, store)
 	if err != nil {
 		return nil, nil, err
 	}
+	if listenerIsVHDS {
+		httpFilters, err = withOnDemandHTTPFilter(httpFilters)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	filterChainParams := &FilterChainsParams{
 		VSName:           nn.String(),
@@ -247,7 +286,15 @@ func BuildResources(vs *v1alpha1.VirtualService, store *store.Store) (*Resources
 		case SecretRefType:
 			filterChainParams.SecretNameToDomains = getSecretNameToDomainsViaSecretRef(vs.Spec.TlsConfig.SecretRef, vs.Namespace, virtualHost.Domains)
 		case AutoDiscoveryType:
-			filterChainParams.SecretNameToDomains, err = getSecretNameToDomainsViaAutoDiscovery(virtualHost.Domains, store.DomainToSecretMap)
+			filterChainParams.SecretNameToDomains, err = getSecretNameToDomainsViaAutoDiscovery(virtualHost.Domains, store)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if ref := vs.Spec.TlsConfig.ValidationContextRef; ref != nil {
+			filterChainParams.ValidationContext = &helpers.NamespacedName{Namespace: helpers.GetNamespace(ref.Namespace, vs.Namespace), Name: ref.Name}
+			filterChainParams.ValidationContextName, err = getValidationContextName(ctx, *filterChainParams.ValidationContext, store)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -264,26 +311,33 @@ func BuildResources(vs *v1alpha1.VirtualService, store *store.Store) (*Resources
 		return nil, nil, err
 	}
 
+	// Clusters and secrets are looked up in configs of all http filters, including the ones delivered over ECDS
+	allHTTPFilters := withExtensionConfigs(httpFilters, extensionConfigs)
+
 	// Clusters ---
 
-	clusters, err := buildClusters(virtualHost, httpFilters, store)
+	clusters, err := buildClusters(virtualHost, allHTTPFilters, store)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// Secrets
-	secrets, usedSecrets, err := buildSecrets(httpFilters, filterChainParams.SecretNameToDomains, store)
+	secrets, usedSecrets, err := buildSecrets(ctx, allHTTPFilters, filterChainParams, store)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to build secrets: %w", err)
 	}
 
-	return &Resources{
-		Listener:    listenerNN,
-		FilterChain: fcs,
-		RouteConfig: routeConfiguration,
-		Clusters:    clusters,
-		Secrets:     secrets,
-	}, usedSecrets, nil
+	res := &Resources{
+		Listener:         listenerNN,
+		FilterChain:      fcs,
+		RouteConfig:      routeConfiguration,
+		Clusters:         clusters,
+		Secrets:          secrets,
+		ExtensionConfigs: extensionConfigs,
+		VirtualHosts:     vhdsVirtualHosts,
+	}
+	res.Provenance = buildProvenance(vs, provenance, res, store)
+	return res, usedSecrets, nil
 }
 
 func buildListener(listenerNN helpers.NamespacedName, store *store.Store) (*listenerv3.Listener, error) {
@@ -348,19 +402,20 @@ func buildVirtualHost(vs *v1alpha1.VirtualService, store *store.Store) (*routev3
 	return virtualHost, nil
 }
 
-func buildHTTPFilters(vs *v1alpha1.VirtualService, store *store.Store) ([]*hcmv3.HttpFilter, error) {
+func buildHTTPFilters(vs *v1alpha1.VirtualService, store *store.Store) ([]*hcmv3.HttpFilter, []*corev3.TypedExtensionConfig, error) {
 	httpFilters := make([]*hcmv3.HttpFilter, 0, len(vs.Spec.HTTPFilters)+len(vs.Spec.AdditionalHttpFilters))
+	var extensionConfigs []*corev3.TypedExtensionConfig
 
 	rbacF, err := buildRBACFilter(vs, store)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if rbacF != nil {
 		configType := &hcmv3.HttpFilter_TypedConfig{
 			TypedConfig: &anypb.Any{},
 		}
 		if err := configType.TypedConfig.MarshalFrom(rbacF); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		httpFilters = append(httpFilters, &hcmv3.HttpFilter{
 			Name:       "exc.filters.http.rbac",
@@ -371,10 +426,10 @@ func buildHTTPFilters(vs *v1alpha1.VirtualService, store *store.Store) ([]*hcmv3
 	for _, httpFilter := range vs.Spec.HTTPFilters {
 		hf := &hcmv3.HttpFilter{}
 		if err := protoutil.Unmarshaler.Unmarshal(httpFilter.Raw, hf); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal http filter: %w", err)
+			return nil, nil, fmt.Errorf("failed to unmarshal http filter: %w", err)
 		}
 		if err := hf.ValidateAll(); err != nil {
-			return nil, fmt.Errorf("failed to validate http filter: %w", err)
+			return nil, nil, fmt.Errorf("failed to validate http filter: %w", err)
 		}
 		httpFilters = append(httpFilters, hf)
 	}
@@ -382,17 +437,27 @@ func buildHTTPFilters(vs *v1alpha1.VirtualService, store *store.Store) ([]*hcmv3
 	if len(vs.Spec.AdditionalHttpFilters) > 0 {
 		for _, httpFilterRef := range vs.Spec.AdditionalHttpFilters {
 			httpFilterRefNs := helpers.GetNamespace(httpFilterRef.Namespace, vs.Namespace)
-			hf := store.HTTPFilters[helpers.NamespacedName{Namespace: httpFilterRefNs, Name: httpFilterRef.Name}]
+			httpFilterRefNN := helpers.NamespacedName{Namespace: httpFilterRefNs, Name: httpFilterRef.Name}
+			hf := store.HTTPFilters[httpFilterRefNN]
 			if hf == nil {
-				return nil, fmt.Errorf("http filter %s/%s not found", httpFilterRefNs, httpFilterRef.Name)
+				return nil, nil, fmt.Errorf("http filter %s/%s not found", httpFilterRefNs, httpFilterRef.Name)
 			}
 			for _, filter := range hf.Spec {
 				xdsHttpFilter := &hcmv3.HttpFilter{}
 				if err := protoutil.Unmarshaler.Unmarshal(filter.Raw, xdsHttpFilter); err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 				if err := xdsHttpFilter.ValidateAll(); err != nil {
-					return nil, err
+					return nil, nil, err
+				}
+				if hf.IsECDS() && !isRouterHTTPFilter(xdsHttpFilter) {
+					discoveryFilter, extensionConfig, err := makeECDSHTTPFilter(httpFilterRefNN, xdsHttpFilter)
+					if err != nil {
+						return nil, nil, err
+					}
+					httpFilters = append(httpFilters, discoveryFilter)
+					extensionConfigs = append(extensionConfigs, extensionConfig)
+					continue
 				}
 				httpFilters = append(httpFilters, xdsHttpFilter)
 			}
@@ -402,16 +467,14 @@ func buildHTTPFilters(vs *v1alpha1.VirtualService, store *store.Store) ([]*hcmv3
 	// filter with type type.googleapis.com/envoy.extensions.filters.http.router.v3.Router must be in the end
 	var routerIdxs []int
 	for i, f := range httpFilters {
-		if tc := f.GetTypedConfig(); tc != nil {
-			if tc.TypeUrl == "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router" {
-				routerIdxs = append(routerIdxs, i)
-			}
+		if isRouterHTTPFilter(f) {
+			routerIdxs = append(routerIdxs, i)
 		}
 	}
 
 	switch {
 	case len(routerIdxs) > 1:
-		return nil, fmt.Errorf("multiple root router http filters")
+		return nil, nil, fmt.Errorf("multiple root router http filters")
 	case len(routerIdxs) == 1 && routerIdxs[0] != len(httpFilters)-1:
 		index := routerIdxs[0]
 		route := httpFilters[index]
@@ -419,7 +482,120 @@ func buildHTTPFilters(vs *v1alpha1.VirtualService, store *store.Store) ([]*hcmv3
 		httpFilters = append(httpFilters, route)
 	}
 
-	return httpFilters, nil
+	return httpFilters, extensionConfigs, nil
+}
+
+// buildVHDSRouteConfiguration moves virtual hosts of exact domains from the route configuration to VHDS.
+// VHDS resources are named <route configuration name>/<domain>, the name Envoy requests on demand
+// for the Host header. Wildcard domains can't be requested on demand, so they stay inline.
+func buildVHDSRouteConfiguration(rc *routev3.RouteConfiguration) (*routev3.RouteConfiguration, []*routev3.VirtualHost) {
+	var vhdsVirtualHosts []*routev3.VirtualHost
+	inlineVirtualHosts := make([]*routev3.VirtualHost, 0, len(rc.VirtualHosts))
+
+	for _, vh := range rc.VirtualHosts {
+		var inlineDomains []string
+		for _, domain := range vh.Domains {
+			if strings.Contains(domain, "*") {
+				inlineDomains = append(inlineDomains, domain)
+				continue
+			}
+			vhdsVirtualHosts = append(vhdsVirtualHosts, &routev3.VirtualHost{
+				Name:                rc.Name + "/" + domain,
+				Domains:             []string{domain},
+				Routes:              vh.Routes,
+				RequestHeadersToAdd: vh.RequestHeadersToAdd,
+			})
+		}
+		if len(inlineDomains) > 0 {
+			inlineVH := proto.Clone(vh).(*routev3.VirtualHost)
+			inlineVH.Domains = inlineDomains
+			inlineVirtualHosts = append(inlineVirtualHosts, inlineVH)
+		}
+	}
+
+	rc.VirtualHosts = inlineVirtualHosts
+	rc.Vhds = &routev3.Vhds{
+		ConfigSource: &corev3.ConfigSource{
+			ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+			ResourceApiVersion:    corev3.ApiVersion_V3,
+		},
+	}
+	return rc, vhdsVirtualHosts
+}
+
+// withOnDemandHTTPFilter adds the on-demand filter required for VHDS before the router filter.
+func withOnDemandHTTPFilter(httpFilters []*hcmv3.HttpFilter) ([]*hcmv3.HttpFilter, error) {
+	onDemand, err := anypb.New(&ondemandv3.OnDemand{})
+	if err != nil {
+		return nil, err
+	}
+	onDemandFilter := &hcmv3.HttpFilter{
+		Name:       "envoy.filters.http.on_demand",
+		ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: onDemand},
+	}
+	result := make([]*hcmv3.HttpFilter, 0, len(httpFilters)+1)
+	if n := len(httpFilters); n > 0 && isRouterHTTPFilter(httpFilters[n-1]) {
+		result = append(result, httpFilters[:n-1]...)
+		return append(result, onDemandFilter, httpFilters[n-1]), nil
+	}
+	result = append(result, httpFilters...)
+	return append(result, onDemandFilter), nil
+}
+
+func isRouterHTTPFilter(f *hcmv3.HttpFilter) bool {
+	tc := f.GetTypedConfig()
+	return tc != nil && tc.TypeUrl == "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"
+}
+
+// makeECDSHTTPFilter returns the http filter referencing the extension config over ADS and the extension config itself.
+// The router filter is terminal and is always inlined.
+func makeECDSHTTPFilter(httpFilterNN helpers.NamespacedName, hf *hcmv3.HttpFilter) (*hcmv3.HttpFilter, *corev3.TypedExtensionConfig, error) {
+	tc := hf.GetTypedConfig()
+	if tc == nil {
+		return nil, nil, fmt.Errorf("http filter %s: %s has no typed config, it can't be delivered over ECDS", httpFilterNN.String(), hf.Name)
+	}
+	extensionConfig := &corev3.TypedExtensionConfig{
+		Name:        httpFilterNN.String() + "/" + hf.Name,
+		TypedConfig: tc,
+	}
+	// Envoy subscribes to the extension config by the name of the http filter
+	discoveryFilter := &hcmv3.HttpFilter{
+		Name:       extensionConfig.Name,
+		IsOptional: hf.IsOptional,
+		Disabled:   hf.Disabled,
+		ConfigType: &hcmv3.HttpFilter_ConfigDiscovery{
+			ConfigDiscovery: &corev3.ExtensionConfigSource{
+				ConfigSource: &corev3.ConfigSource{
+					ConfigSourceSpecifier: &corev3.ConfigSource_Ads{Ads: &corev3.AggregatedConfigSource{}},
+					ResourceApiVersion:    corev3.ApiVersion_V3,
+				},
+				TypeUrls: []string{tc.TypeUrl},
+			},
+		},
+	}
+	if err := extensionConfig.ValidateAll(); err != nil {
+		return nil, nil, err
+	}
+	if err := discoveryFilter.ValidateAll(); err != nil {
+		return nil, nil, err
+	}
+	return discoveryFilter, extensionConfig, nil
+}
+
+// withExtensionConfigs returns the http filters with configs of filters delivered over ECDS appended inline.
+func withExtensionConfigs(httpFilters []*hcmv3.HttpFilter, extensionConfigs []*corev3.TypedExtensionConfig) []*hcmv3.HttpFilter {
+	if len(extensionConfigs) == 0 {
+		return httpFilters
+	}
+	result := make([]*hcmv3.HttpFilter, 0, len(httpFilters)+len(extensionConfigs))
+	result = append(result, httpFilters...)
+	for _, ec := range extensionConfigs {
+		result = append(result, &hcmv3.HttpFilter{
+			Name:       ec.Name,
+			ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: ec.TypedConfig},
+		})
+	}
+	return result
 }
 
 func domainsWithPorts(domains []string, listener *listenerv3.Listener) []string {
@@ -567,18 +743,16 @@ func buildFilterChains(params *FilterChainsParams) ([]*listenerv3.FilterChain, e
 			params.Domains = domains
 			params.DownstreamTLSContext = &tlsv3.DownstreamTlsContext{
 				CommonTlsContext: &tlsv3.CommonTlsContext{
-					TlsCertificateSdsSecretConfigs: []*tlsv3.SdsSecretConfig{{
-						Name: secretName.String(),
-						SdsConfig: &corev3.ConfigSource{
-							ConfigSourceSpecifier: &corev3.ConfigSource_Ads{
-								Ads: &corev3.AggregatedConfigSource{},
-							},
-							ResourceApiVersion: corev3.ApiVersion_V3,
-						},
-					}},
-					AlpnProtocols: []string{"h2", "http/1.1"},
+					TlsCertificateSdsSecretConfigs: []*tlsv3.SdsSecretConfig{adsSdsSecretConfig(secretName.String())},
+					AlpnProtocols:                  []string{"h2", "http/1.1"},
 				},
 			}
+			if params.ValidationContextName != "" {
+				params.DownstreamTLSContext.CommonTlsContext.ValidationContextType = &tlsv3.CommonTlsContext_ValidationContextSdsSecretConfig{
+					ValidationContextSdsSecretConfig: adsSdsSecretConfig(params.ValidationContextName),
+				}
+				params.DownstreamTLSContext.RequireClientCertificate = &wrapperspb.BoolValue{Value: true}
+			}
 			fc, err := buildFilterChain(params)
 			if err != nil {
 				return nil, err
@@ -596,6 +770,19 @@ func buildFilterChains(params *FilterChainsParams) ([]*listenerv3.FilterChain, e
 	return filterChains, nil
 }
 
+// adsSdsSecretConfig returns the config of the secret delivered over ADS.
+func adsSdsSecretConfig(name string) *tlsv3.SdsSecretConfig {
+	return &tlsv3.SdsSecretConfig{
+		Name: name,
+		SdsConfig: &corev3.ConfigSource{
+			ConfigSourceSpecifier: &corev3.ConfigSource_Ads{
+				Ads: &corev3.AggregatedConfigSource{},
+			},
+			ResourceApiVersion: corev3.ApiVersion_V3,
+		},
+	}
+}
+
 func buildFilterChain(params *FilterChainsParams) (*listenerv3.FilterChain, error) {
 	httpConnectionManager := &hcmv3.HttpConnectionManager{
 		CodecType:  hcmv3.HttpConnectionManager_AUTO,
@@ -735,17 +922,13 @@ func getSecretNameToDomainsViaSecretRef(secretRef *v1alpha1.ResourceRef, vsNames
 	return m
 }
 
-func getSecretNameToDomainsViaAutoDiscovery(domains []string, domainToSecretMap map[string]v1.Secret) (map[helpers.NamespacedName][]string, error) {
+func getSecretNameToDomainsViaAutoDiscovery(domains []string, store *store.Store) (map[helpers.NamespacedName][]string, error) {
 	m := make(map[helpers.NamespacedName][]string)
 
 	for _, domain := range domains {
-		var secret v1.Secret
-		secret, ok := domainToSecretMap[domain]
+		secret, ok := store.GetDomainSecret(domain)
 		if !ok {
-			secret, ok = domainToSecretMap[getWildcardDomain(domain)]
-			if !ok {
-				return nil, fmt.Errorf("can't find secret for domain %s", domain)
-			}
+			return nil, fmt.Errorf("can't find secret for domain %s", domain)
 		}
 
 		domainsFromMap, ok := m[helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}]
@@ -759,15 +942,6 @@ func getSecretNameToDomainsViaAutoDiscovery(domains []string, domainToSecretMap
 	return m, nil
 }
 
-func getWildcardDomain(domain string) string {
-	parts := strings.Split(domain, ".")
-	if len(parts) < 2 {
-		return ""
-	}
-	parts[0] = "*"
-	return strings.Join(parts, ".")
-}
-
 func findClusterNames(data interface{}, fieldName string) []string {
 	var results []string
 
@@ -788,28 +962,59 @@ func findClusterNames(data interface{}, fieldName string) []string {
 	return results
 }
 
-func buildSecrets(httpFilters []*hcmv3.HttpFilter, secretNameToDomains map[helpers.NamespacedName][]string, store *store.Store) ([]*tlsv3.Secret, []helpers.NamespacedName, error) {
+func buildSecrets(ctx context.Context, httpFilters []*hcmv3.HttpFilter, params *FilterChainsParams, store *store.Store) ([]*tlsv3.Secret, []helpers.NamespacedName, error) {
 	var secrets []*tlsv3.Secret
 	var usedSecrets []helpers.NamespacedName // for validation
 
-	getEnvoySecret := func(namespace, name string) ([]*tlsv3.Secret, error) {
-		kubeSecret, ok := store.Secrets[helpers.NamespacedName{Namespace: namespace, Name: name}]
-		if !ok {
-			return nil, fmt.Errorf("can't find secret %s/%s", namespace, name)
+	getKubeSecret := func(namespace, name string) (*v1.Secret, error) {
+		kubeSecret, err := store.SecretProvider.GetSecret(ctx, namespace, name)
+		if err != nil {
+			return nil, fmt.Errorf("can't get secret %s/%s: %w", namespace, name, err)
 		}
 		usedSecrets = append(usedSecrets, helpers.NamespacedName{Namespace: namespace, Name: name})
+		return kubeSecret, nil
+	}
+	getEnvoySecret := func(namespace, name string) ([]*tlsv3.Secret, error) {
+		kubeSecret, err := getKubeSecret(namespace, name)
+		if err != nil {
+			return nil, err
+		}
 		return makeEnvoySecretFromKubernetesSecret(kubeSecret)
 	}
 
 	// Get Secrets from certificatesWithDomains
-	for secret := range secretNameToDomains {
-		v3Secret, err := getEnvoySecret(secret.Namespace, secret.Name)
+	for secret := range params.SecretNameToDomains {
+		kubeSecret, err := getKubeSecret(secret.Namespace, secret.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if kind, err := getSecretKind(kubeSecret); err != nil {
+			return nil, nil, err
+		} else if kind != v1alpha1.SecretKindTLSCertificate {
+			return nil, nil, fmt.Errorf("secret %s/%s of kind %s can't be used as tls certificate", secret.Namespace, secret.Name, kind)
+		}
+		v3Secret, err := makeEnvoySecretFromKubernetesSecret(kubeSecret)
 		if err != nil {
-			return nil, nil, fmt.Errorf("can't find envoy secret %s/%s", secret.Namespace, secret.Name)
+			return nil, nil, err
 		}
 		secrets = append(secrets, v3Secret...)
 	}
 
+	// only the validation context is used from a tls secret with ca.crt
+	if params.ValidationContext != nil {
+		if _, ok := params.SecretNameToDomains[*params.ValidationContext]; !ok {
+			v3Secrets, err := getEnvoySecret(params.ValidationContext.Namespace, params.ValidationContext.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, v3Secret := range v3Secrets {
+				if v3Secret.Name == params.ValidationContextName {
+					secrets = append(secrets, v3Secret)
+				}
+			}
+		}
+	}
+
 	for _, filter := range httpFilters {
 		jsonData, err := json.MarshalIndent(filter, "", "  ")
 		if err != nil {
@@ -862,73 +1067,6 @@ func findSDSNames(data interface{}, fieldName string) []string {
 	return results
 }
 
-func makeEnvoySecretFromKubernetesSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
-	switch kubeSecret.Type {
-	case v1.SecretTypeTLS:
-		return makeEnvoyTLSSecret(kubeSecret)
-	case v1.SecretTypeOpaque:
-		return makeEnvoyOpaqueSecret(kubeSecret)
-	default:
-		return nil, fmt.Errorf("unsupported secret type %s", kubeSecret.Type)
-	}
-}
-
-func makeEnvoyTLSSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
-	secrets := make([]*tlsv3.Secret, 0)
-
-	envoySecret := &tlsv3.Secret{
-		Name: fmt.Sprintf("%s/%s", kubeSecret.Namespace, kubeSecret.Name),
-		Type: &tlsv3.Secret_TlsCertificate{
-			TlsCertificate: &tlsv3.TlsCertificate{
-				CertificateChain: &corev3.DataSource{
-					Specifier: &corev3.DataSource_InlineBytes{
-						InlineBytes: kubeSecret.Data[v1.TLSCertKey],
-					},
-				},
-				PrivateKey: &corev3.DataSource{
-					Specifier: &corev3.DataSource_InlineBytes{
-						InlineBytes: kubeSecret.Data[v1.TLSPrivateKeyKey],
-					},
-				},
-			},
-		},
-	}
-	if err := envoySecret.ValidateAll(); err != nil {
-		return nil, fmt.Errorf("failed to validate tls secret: %w", err)
-	}
-
-	secrets = append(secrets, envoySecret)
-
-	return secrets, nil
-}
-
-func makeEnvoyOpaqueSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
-	secrets := make([]*tlsv3.Secret, 0)
-
-	for k, v := range kubeSecret.Data {
-		envoySecret := &tlsv3.Secret{
-			Name: fmt.Sprintf("%s/%s/%s", kubeSecret.Namespace, kubeSecret.Name, k),
-			Type: &tlsv3.Secret_GenericSecret{
-				GenericSecret: &tlsv3.GenericSecret{
-					Secret: &corev3.DataSource{
-						Specifier: &corev3.DataSource_InlineBytes{
-							InlineBytes: v,
-						},
-					},
-				},
-			},
-		}
-
-		if err := envoySecret.ValidateAll(); err != nil {
-			return nil, fmt.Errorf("cannot validate Envoy Secret: %w", err)
-		}
-
-		secrets = append(secrets, envoySecret)
-	}
-
-	return secrets, nil
-}
-
 func isTLSListener(xdsListener *listenerv3.Listener) bool {
 	if xdsListener == nil {
 		return false
@@ -0,0 +1,92 @@
+package resbuilder
+
+import (
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	_ "github.com/kaasops/envoy-xds-controller/internal/xds/cache" // register Envoy extension types
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestBuildHTTPFiltersECDS(t *testing.T) {
+	const (
+		luaFilter    = `{"name":"envoy.filters.http.lua","typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua","default_source_code":{"inline_string":"function envoy_on_request(h) end"}}}`
+		routerFilter = `{"name":"envoy.filters.http.router","typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"}}`
+	)
+
+	testCases := []struct {
+		name                     string
+		ecds                     bool
+		expectedExtensionConfigs []string
+	}{
+		{
+			name: "inline",
+		},
+		{
+			name:                     "ecds",
+			ecds:                     true,
+			expectedExtensionConfigs: []string{"default/lua/envoy.filters.http.lua"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hf := &v1alpha1.HttpFilter{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "lua"},
+				Spec: []*runtime.RawExtension{
+					{Raw: []byte(routerFilter)},
+					{Raw: []byte(luaFilter)},
+				},
+			}
+			if tc.ecds {
+				hf.Annotations = map[string]string{v1alpha1.AnnotationHTTPFilterECDS: "true"}
+			}
+			s := store.New()
+			s.HTTPFilters[helpers.NamespacedName{Namespace: "default", Name: "lua"}] = hf
+
+			vs := &v1alpha1.VirtualService{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vs"}}
+			vs.Spec.AdditionalHttpFilters = []*v1alpha1.ResourceRef{{Name: "lua"}}
+
+			httpFilters, extensionConfigs, err := buildHTTPFilters(vs, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(httpFilters) != 2 {
+				t.Fatalf("expected 2 http filters, got %d", len(httpFilters))
+			}
+			if !isRouterHTTPFilter(httpFilters[1]) {
+				t.Errorf("router filter must be the last one")
+			}
+			if len(extensionConfigs) != len(tc.expectedExtensionConfigs) {
+				t.Fatalf("expected extension configs %v, got %v", tc.expectedExtensionConfigs, extensionConfigs)
+			}
+			for i, name := range tc.expectedExtensionConfigs {
+				if extensionConfigs[i].Name != name {
+					t.Errorf("expected extension config %s, got %s", name, extensionConfigs[i].Name)
+				}
+			}
+
+			lua := httpFilters[0]
+			if tc.ecds {
+				cd := lua.GetConfigDiscovery()
+				if cd == nil || lua.GetTypedConfig() != nil {
+					t.Fatalf("lua filter must reference the extension config: %v", lua)
+				}
+				if lua.Name != extensionConfigs[0].Name {
+					t.Errorf("filter name %s must be the extension config name %s", lua.Name, extensionConfigs[0].Name)
+				}
+				if cd.TypeUrls[0] != "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua" {
+					t.Errorf("unexpected type URLs %v", cd.TypeUrls)
+				}
+				if all := withExtensionConfigs(httpFilters, extensionConfigs); len(all) != 3 {
+					t.Errorf("expected 3 filters with extension configs, got %d", len(all))
+				}
+			} else if lua.GetTypedConfig() == nil {
+				t.Errorf("lua filter must be inlined: %v", lua)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package resbuilder
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+)
+
+// Kinds of objects referenced in provenance.
+const (
+	KindVirtualService         = store.KindVirtualService
+	KindVirtualServiceTemplate = store.KindVirtualServiceTemplate
+	KindListener               = store.KindListener
+	KindRoute                  = store.KindRoute
+	KindCluster                = store.KindCluster
+	KindHTTPFilter             = store.KindHTTPFilter
+	KindPolicy                 = store.KindPolicy
+	KindAccessLogConfig        = store.KindAccessLogConfig
+	KindSecret                 = store.KindSecret
+)
+
+// Sources of template fields.
+const (
+	// TemplateFieldSourceTemplate is a field set only in the template
+	TemplateFieldSourceTemplate = "template"
+	// TemplateFieldSourceMerged is a field set in both the template and the virtual service
+	TemplateFieldSourceMerged = "merged"
+	// TemplateFieldSourceVirtualService is a field of the template replaced by the virtual service
+	TemplateFieldSourceVirtualService = "virtual_service"
+	// TemplateFieldSourceDeleted is a field of the template deleted by the virtual service
+	TemplateFieldSourceDeleted = "deleted"
+)
+
+type ObjectRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// TemplateField describes how a field of the virtual service spec was filled from the template.
+type TemplateField struct {
+	Field    string `json:"field"`
+	Source   string `json:"source"`
+	Modifier string `json:"modifier,omitempty"`
+}
+
+// Provenance describes objects which produced resources of a virtual service.
+type Provenance struct {
+	VirtualService ObjectRef       `json:"virtual_service"`
+	Template       *ObjectRef      `json:"template,omitempty"`
+	TemplateFields []TemplateField `json:"template_fields,omitempty"`
+	// Objects are objects which produced each resource, by type URL and resource name.
+	// The virtual service and the template are not repeated here.
+	Objects map[resource.Type]map[string][]ObjectRef `json:"objects"`
+}
+
+// ResourceOrigin is a virtual service which contributed to a resource, with objects the resource was built from.
+type ResourceOrigin struct {
+	VirtualService ObjectRef       `json:"virtual_service"`
+	Template       *ObjectRef      `json:"template,omitempty"`
+	TemplateFields []TemplateField `json:"template_fields,omitempty"`
+	Objects        []ObjectRef     `json:"objects"`
+}
+
+// Origin returns the origin of the resource, false if the resource wasn't produced by the virtual service.
+func (p *Provenance) Origin(typeURL resource.Type, name string) (ResourceOrigin, bool) {
+	objects, ok := p.Objects[typeURL][name]
+	if !ok {
+		return ResourceOrigin{}, false
+	}
+	return ResourceOrigin{
+		VirtualService: p.VirtualService,
+		Template:       p.Template,
+		TemplateFields: p.TemplateFields,
+		Objects:        objects,
+	}, true
+}
+
+func (p *Provenance) add(typeURL resource.Type, name string, objects ...ObjectRef) {
+	if p.Objects[typeURL] == nil {
+		p.Objects[typeURL] = make(map[string][]ObjectRef)
+	}
+	p.Objects[typeURL][name] = append(p.Objects[typeURL][name], objects...)
+}
+
+func newProvenance(vs *v1alpha1.VirtualService, vst *v1alpha1.VirtualServiceTemplate) *Provenance {
+	p := &Provenance{
+		VirtualService: ObjectRef{Kind: KindVirtualService, Namespace: vs.Namespace, Name: vs.Name},
+		Objects:        make(map[resource.Type]map[string][]ObjectRef),
+	}
+	if vst != nil {
+		p.Template = &ObjectRef{Kind: KindVirtualServiceTemplate, Namespace: vst.Namespace, Name: vst.Name}
+		p.TemplateFields = templateFields(vst, vs)
+	}
+	return p
+}
+
+// buildProvenance returns provenance of resources built from the virtual service filled from the template.
+func buildProvenance(vs *v1alpha1.VirtualService, p *Provenance, res *Resources, store *store.Store) *Provenance {
+	listener := ObjectRef{Kind: KindListener, Namespace: res.Listener.Namespace, Name: res.Listener.Name}
+
+	var routeObjects, listenerObjects []ObjectRef
+	for _, ref := range vs.Spec.AdditionalRoutes {
+		routeObjects = append(routeObjects, objectRef(KindRoute, ref, vs.Namespace))
+	}
+	listenerObjects = append(listenerObjects, listener)
+	for _, ref := range vs.Spec.AdditionalHttpFilters {
+		listenerObjects = append(listenerObjects, objectRef(KindHTTPFilter, ref, vs.Namespace))
+	}
+	if vs.Spec.RBAC != nil {
+		for _, ref := range vs.Spec.RBAC.AdditionalPolicies {
+			listenerObjects = append(listenerObjects, objectRef(KindPolicy, ref, vs.Namespace))
+		}
+	}
+	if vs.Spec.AccessLogConfig != nil {
+		listenerObjects = append(listenerObjects, objectRef(KindAccessLogConfig, vs.Spec.AccessLogConfig, vs.Namespace))
+	}
+	for _, secret := range res.Secrets {
+		// secrets of filter chains and http filters are delivered over SDS, the listener references them
+		if ns, name, ok := secretObjectName(secret.Name); ok {
+			listenerObjects = append(listenerObjects, ObjectRef{Kind: KindSecret, Namespace: ns, Name: name})
+		}
+	}
+	p.add(resource.ListenerType, res.Listener.String(), uniqueObjectRefs(listenerObjects)...)
+
+	if res.RouteConfig != nil {
+		p.add(resource.RouteType, res.RouteConfig.Name, routeObjects...)
+	}
+	for _, vh := range res.VirtualHosts {
+		p.add(resource.VirtualHostType, vh.Name, routeObjects...)
+	}
+	for _, cl := range res.Clusters {
+		var objects []ObjectRef
+		if specCluster := store.SpecClusters[cl.Name]; specCluster != nil {
+			objects = append(objects, ObjectRef{Kind: KindCluster, Namespace: specCluster.Namespace, Name: specCluster.Name})
+		}
+		p.add(resource.ClusterType, cl.Name, objects...)
+	}
+	for _, secret := range res.Secrets {
+		var objects []ObjectRef
+		if ns, name, ok := secretObjectName(secret.Name); ok {
+			objects = append(objects, ObjectRef{Kind: KindSecret, Namespace: ns, Name: name})
+		}
+		p.add(resource.SecretType, secret.Name, objects...)
+	}
+	for _, ec := range res.ExtensionConfigs {
+		var objects []ObjectRef
+		// extension configs are named namespace/name/filterName after the HttpFilter
+		if parts := strings.SplitN(ec.Name, "/", 3); len(parts) == 3 {
+			objects = append(objects, ObjectRef{Kind: KindHTTPFilter, Namespace: parts[0], Name: parts[1]})
+		}
+		p.add(resource.ExtensionConfigType, ec.Name, objects...)
+	}
+	return p
+}
+
+func objectRef(kind string, ref *v1alpha1.ResourceRef, namespace string) ObjectRef {
+	return ObjectRef{Kind: kind, Namespace: helpers.GetNamespace(ref.Namespace, namespace), Name: ref.Name}
+}
+
+// secretObjectName returns the Kubernetes Secret of an Envoy secret named namespace/name[/suffix].
+func secretObjectName(secretName string) (string, string, bool) {
+	parts := strings.SplitN(secretName, "/", 3)
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func uniqueObjectRefs(refs []ObjectRef) []ObjectRef {
+	seen := make(map[ObjectRef]struct{}, len(refs))
+	result := make([]ObjectRef, 0, len(refs))
+	for _, ref := range refs {
+		if _, ok := seen[ref]; ok {
+			continue
+		}
+		seen[ref] = struct{}{}
+		result = append(result, ref)
+	}
+	return result
+}
+
+// templateFields returns top-level fields of the virtual service spec which were filled from the template,
+// and fields changed by template options.
+func templateFields(vst *v1alpha1.VirtualServiceTemplate, vs *v1alpha1.VirtualService) []TemplateField {
+	templateSpec := topLevelFields(&vst.Spec.VirtualServiceCommonSpec)
+	vsSpec := topLevelFields(&vs.Spec.VirtualServiceCommonSpec)
+
+	modifiers := make(map[string]v1alpha1.Modifier, len(vs.Spec.TemplateOptions))
+	for _, opt := range vs.Spec.TemplateOptions {
+		modifiers[opt.Field] = opt.Modifier
+	}
+
+	fields := make([]TemplateField, 0, len(templateSpec)+len(modifiers))
+	for field := range templateSpec {
+		tf := TemplateField{Field: field, Source: TemplateFieldSourceTemplate}
+		if _, ok := vsSpec[field]; ok {
+			tf.Source = TemplateFieldSourceMerged
+		}
+		if modifier, ok := modifiers[field]; ok {
+			tf.Source = templateFieldSource(modifier, tf.Source)
+			tf.Modifier = string(modifier)
+			delete(modifiers, field)
+		}
+		fields = append(fields, tf)
+	}
+	// options of nested fields
+	for field, modifier := range modifiers {
+		fields = append(fields, TemplateField{
+			Field:    field,
+			Source:   templateFieldSource(modifier, TemplateFieldSourceMerged),
+			Modifier: string(modifier),
+		})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+func templateFieldSource(modifier v1alpha1.Modifier, source string) string {
+	switch modifier {
+	case v1alpha1.ModifierReplace:
+		return TemplateFieldSourceVirtualService
+	case v1alpha1.ModifierDelete:
+		return TemplateFieldSourceDeleted
+	}
+	return source
+}
+
+func topLevelFields(spec *v1alpha1.VirtualServiceCommonSpec) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
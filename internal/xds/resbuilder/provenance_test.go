@@ -0,0 +1,90 @@
+package resbuilder
+
+import (
+	"reflect"
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestTemplateFields(t *testing.T) {
+	vst := &v1alpha1.VirtualServiceTemplate{}
+	vst.Spec.Listener = &v1alpha1.ResourceRef{Name: "http"}
+	vst.Spec.VirtualHost = &runtime.RawExtension{Raw: []byte(`{"domains":["*"]}`)}
+	vst.Spec.AdditionalRoutes = []*v1alpha1.ResourceRef{{Name: "route"}}
+	vst.Spec.AccessLogConfig = &v1alpha1.ResourceRef{Name: "stdout"}
+
+	vs := &v1alpha1.VirtualService{}
+	vs.Spec.VirtualHost = &runtime.RawExtension{Raw: []byte(`{"domains":["example.com"]}`)}
+	vs.Spec.AdditionalRoutes = []*v1alpha1.ResourceRef{{Name: "other"}}
+	vs.Spec.TemplateOptions = []v1alpha1.TemplateOpts{
+		{Field: "additionalRoutes", Modifier: v1alpha1.ModifierReplace},
+		{Field: "accessLogConfig", Modifier: v1alpha1.ModifierDelete},
+		{Field: "virtualHost.domains", Modifier: v1alpha1.ModifierReplace},
+	}
+
+	expected := []TemplateField{
+		{Field: "accessLogConfig", Source: TemplateFieldSourceDeleted, Modifier: "delete"},
+		{Field: "additionalRoutes", Source: TemplateFieldSourceVirtualService, Modifier: "replace"},
+		{Field: "listener", Source: TemplateFieldSourceTemplate},
+		{Field: "virtualHost", Source: TemplateFieldSourceMerged},
+		{Field: "virtualHost.domains", Source: TemplateFieldSourceVirtualService, Modifier: "replace"},
+	}
+	if fields := templateFields(vst, vs); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestBuildProvenance(t *testing.T) {
+	vs := &v1alpha1.VirtualService{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vs"}}
+	ns := "shared"
+	vs.Spec.AdditionalRoutes = []*v1alpha1.ResourceRef{{Name: "route"}}
+	vs.Spec.AdditionalHttpFilters = []*v1alpha1.ResourceRef{{Name: "lua", Namespace: &ns}}
+
+	s := store.New()
+	s.SpecClusters["backend"] = &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backend-cluster"}}
+
+	res := &Resources{
+		Listener:         helpers.NamespacedName{Namespace: "default", Name: "https"},
+		RouteConfig:      &routev3.RouteConfiguration{Name: "default/vs"},
+		Clusters:         []*clusterv3.Cluster{{Name: "backend"}},
+		Secrets:          []*tlsv3.Secret{{Name: "default/tls"}},
+		ExtensionConfigs: []*corev3.TypedExtensionConfig{{Name: "shared/lua/envoy.filters.http.lua"}},
+	}
+	p := buildProvenance(vs, newProvenance(vs, nil), res, s)
+
+	tests := []struct {
+		typeURL  string
+		name     string
+		expected []ObjectRef
+	}{
+		{resource.ListenerType, "default/https", []ObjectRef{
+			{Kind: KindListener, Namespace: "default", Name: "https"},
+			{Kind: KindHTTPFilter, Namespace: "shared", Name: "lua"},
+			{Kind: KindSecret, Namespace: "default", Name: "tls"},
+		}},
+		{resource.RouteType, "default/vs", []ObjectRef{{Kind: KindRoute, Namespace: "default", Name: "route"}}},
+		{resource.ClusterType, "backend", []ObjectRef{{Kind: KindCluster, Namespace: "default", Name: "backend-cluster"}}},
+		{resource.SecretType, "default/tls", []ObjectRef{{Kind: KindSecret, Namespace: "default", Name: "tls"}}},
+		{resource.ExtensionConfigType, "shared/lua/envoy.filters.http.lua", []ObjectRef{{Kind: KindHTTPFilter, Namespace: "shared", Name: "lua"}}},
+	}
+	for _, tt := range tests {
+		origin, ok := p.Origin(tt.typeURL, tt.name)
+		if !ok {
+			t.Errorf("%s %s: origin not found", tt.typeURL, tt.name)
+			continue
+		}
+		if origin.VirtualService.Name != "vs" || !reflect.DeepEqual(origin.Objects, tt.expected) {
+			t.Errorf("%s %s: expected %v, got %v", tt.typeURL, tt.name, tt.expected, origin)
+		}
+	}
+}
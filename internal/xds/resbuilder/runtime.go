@@ -0,0 +1,73 @@
+package resbuilder
+
+import (
+	"fmt"
+	"sort"
+
+	runtimev3 "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// BuildRuntimes builds RTDS resources from Runtimes, Runtimes with the same layer are merged into one resource.
+// A layer is skipped and the error is returned if the same key is set by several Runtimes of the layer.
+func BuildRuntimes(runtimes []*v1alpha1.Runtime) ([]*runtimev3.Runtime, error) {
+	byLayer := make(map[string][]*v1alpha1.Runtime)
+	for _, rt := range runtimes {
+		byLayer[rt.Spec.Layer] = append(byLayer[rt.Spec.Layer], rt)
+	}
+
+	layers := make([]string, 0, len(byLayer))
+	for layer := range byLayer {
+		layers = append(layers, layer)
+	}
+	sort.Strings(layers)
+
+	var errs []error
+	res := make([]*runtimev3.Runtime, 0, len(layers))
+	for _, layer := range layers {
+		rtdsRuntime, err := buildRuntimeLayer(layer, byLayer[layer])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res = append(res, rtdsRuntime)
+	}
+	return res, multierr.Combine(errs...)
+}
+
+func buildRuntimeLayer(layer string, runtimes []*v1alpha1.Runtime) (*runtimev3.Runtime, error) {
+	sort.Slice(runtimes, func(i, j int) bool {
+		if runtimes[i].Namespace != runtimes[j].Namespace {
+			return runtimes[i].Namespace < runtimes[j].Namespace
+		}
+		return runtimes[i].Name < runtimes[j].Name
+	})
+
+	fields := make(map[string]*structpb.Value)
+	keyOwners := make(map[string]string)
+	for _, rt := range runtimes {
+		values, err := rt.UnmarshalValues()
+		if err != nil {
+			return nil, fmt.Errorf("runtime %s/%s: %w", rt.Namespace, rt.Name, err)
+		}
+		owner := rt.Namespace + "/" + rt.Name
+		for key, value := range values.Fields {
+			if prevOwner, ok := keyOwners[key]; ok {
+				return nil, fmt.Errorf("runtime layer %s: key %s is set by both %s and %s", layer, key, prevOwner, owner)
+			}
+			keyOwners[key] = owner
+			fields[key] = value
+		}
+	}
+
+	rtdsRuntime := &runtimev3.Runtime{
+		Name:  layer,
+		Layer: &structpb.Struct{Fields: fields},
+	}
+	if err := rtdsRuntime.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("failed to validate runtime layer %s: %w", layer, err)
+	}
+	return rtdsRuntime, nil
+}
@@ -0,0 +1,52 @@
+package resbuilder
+
+import (
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestBuildRuntimes(t *testing.T) {
+	newRuntime := func(name, layer, values string) *v1alpha1.Runtime {
+		return &v1alpha1.Runtime{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec:       v1alpha1.RuntimeSpec{Layer: layer, Values: &runtime.RawExtension{Raw: []byte(values)}},
+		}
+	}
+
+	runtimes, err := BuildRuntimes([]*v1alpha1.Runtime{
+		newRuntime("a", "rtds", `{"feature.a": true}`),
+		newRuntime("b", "rtds", `{"routing.canary": {"numerator": 10, "denominator": "HUNDRED"}}`),
+		newRuntime("c", "overload", `{"overload.threshold": 0.9}`),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runtimes) != 2 {
+		t.Fatalf("expected 2 layers, got %d", len(runtimes))
+	}
+	if runtimes[0].Name != "overload" || runtimes[1].Name != "rtds" {
+		t.Errorf("unexpected layers order: %s, %s", runtimes[0].Name, runtimes[1].Name)
+	}
+	if len(runtimes[1].Layer.Fields) != 2 {
+		t.Errorf("expected 2 merged keys, got %d", len(runtimes[1].Layer.Fields))
+	}
+
+	runtimes, err = BuildRuntimes([]*v1alpha1.Runtime{
+		newRuntime("a", "rtds", `{"feature.a": true}`),
+		newRuntime("b", "rtds", `{"feature.a": false}`),
+		newRuntime("c", "overload", `{"overload.threshold": 0.9}`),
+	})
+	if err == nil {
+		t.Fatalf("expected conflict error")
+	}
+	if len(runtimes) != 1 || runtimes[0].Name != "overload" {
+		t.Errorf("expected only not conflicting layer")
+	}
+
+	if _, err := BuildRuntimes([]*v1alpha1.Runtime{newRuntime("a", "rtds", `[1, 2]`)}); err == nil {
+		t.Errorf("expected error for not object values")
+	}
+}
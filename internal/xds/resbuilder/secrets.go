@@ -0,0 +1,272 @@
+package resbuilder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// CACertKey is the key of the CA bundle in kubernetes.io/tls secrets (cert-manager convention)
+	// and in dedicated CA secrets.
+	CACertKey = "ca.crt"
+	// CACRLKey is the key of an optional certificate revocation list in CA secrets.
+	CACRLKey = "ca.crl"
+	// OCSPStapleKey is the key of DER-encoded OCSP response data stapled to the TLS certificate.
+	OCSPStapleKey = "tls.ocsp-staple"
+
+	// SessionTicketKeyLength is the length of session ticket keys required by Envoy.
+	SessionTicketKeyLength = 80
+
+	// ValidationContextSuffix is appended to the name of a TLS secret to build
+	// the name of the validation context produced from its ca.crt.
+	ValidationContextSuffix = "validation-context"
+)
+
+// BuildSecret converts the Kubernetes Secret to Envoy secrets.
+func BuildSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
+	return makeEnvoySecretFromKubernetesSecret(kubeSecret)
+}
+
+func makeEnvoySecretFromKubernetesSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
+	kind, err := getSecretKind(kubeSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case v1alpha1.SecretKindTLSCertificate:
+		return makeEnvoyTLSSecret(kubeSecret)
+	case v1alpha1.SecretKindValidationContext:
+		return makeEnvoyValidationContextSecret(kubeSecret)
+	case v1alpha1.SecretKindSessionTicketKeys:
+		return makeEnvoySessionTicketKeysSecret(kubeSecret)
+	case v1alpha1.SecretKindGeneric:
+		return makeEnvoyOpaqueSecret(kubeSecret)
+	default:
+		return nil, fmt.Errorf("unsupported secret kind %s", kind)
+	}
+}
+
+// getSecretKind returns the Envoy secret kind for the kubernetes secret.
+// The kind from annotation "envoy.kaasops.io/secret-kind" has priority over the kind derived from secret type.
+func getSecretKind(kubeSecret *v1.Secret) (string, error) {
+	if kind, ok := kubeSecret.Annotations[v1alpha1.AnnotationSecretKind]; ok {
+		kind = strings.TrimSpace(kind)
+		switch kind {
+		case v1alpha1.SecretKindTLSCertificate,
+			v1alpha1.SecretKindValidationContext,
+			v1alpha1.SecretKindSessionTicketKeys,
+			v1alpha1.SecretKindGeneric:
+			return kind, nil
+		default:
+			return "", fmt.Errorf("unsupported secret kind %s in annotation %s", kind, v1alpha1.AnnotationSecretKind)
+		}
+	}
+
+	switch kubeSecret.Type {
+	case v1.SecretTypeTLS:
+		return v1alpha1.SecretKindTLSCertificate, nil
+	case v1.SecretTypeOpaque, v1.SecretTypeBasicAuth:
+		return v1alpha1.SecretKindGeneric, nil
+	default:
+		return "", fmt.Errorf("unsupported secret type %s", kubeSecret.Type)
+	}
+}
+
+// getValidationContextName returns the name of the Envoy validation context built from the Kubernetes Secret.
+func getValidationContextName(ctx context.Context, nn helpers.NamespacedName, store *store.Store) (string, error) {
+	kubeSecret, err := store.SecretProvider.GetSecret(ctx, nn.Namespace, nn.Name)
+	if err != nil {
+		return "", fmt.Errorf("can't get secret %s: %w", nn.String(), err)
+	}
+	kind, err := getSecretKind(kubeSecret)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case kind == v1alpha1.SecretKindValidationContext:
+		return nn.String(), nil
+	case kind == v1alpha1.SecretKindTLSCertificate && len(kubeSecret.Data[CACertKey]) > 0:
+		return fmt.Sprintf("%s/%s", nn.String(), ValidationContextSuffix), nil
+	default:
+		return "", fmt.Errorf("secret %s of kind %s has no validation context", nn.String(), kind)
+	}
+}
+
+func makeEnvoyTLSSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
+	secrets := make([]*tlsv3.Secret, 0)
+
+	tlsCertificate := &tlsv3.TlsCertificate{
+		CertificateChain: &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineBytes{
+				InlineBytes: kubeSecret.Data[v1.TLSCertKey],
+			},
+		},
+		PrivateKey: &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineBytes{
+				InlineBytes: kubeSecret.Data[v1.TLSPrivateKeyKey],
+			},
+		},
+	}
+	if ocspStaple, ok := kubeSecret.Data[OCSPStapleKey]; ok && len(ocspStaple) > 0 {
+		tlsCertificate.OcspStaple = &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineBytes{
+				InlineBytes: ocspStaple,
+			},
+		}
+	}
+
+	envoySecret := &tlsv3.Secret{
+		Name: fmt.Sprintf("%s/%s", kubeSecret.Namespace, kubeSecret.Name),
+		Type: &tlsv3.Secret_TlsCertificate{
+			TlsCertificate: tlsCertificate,
+		},
+	}
+	if err := envoySecret.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("failed to validate tls secret: %w", err)
+	}
+
+	secrets = append(secrets, envoySecret)
+
+	// cert-manager puts the issuing CA into ca.crt, expose it as validation context
+	// to make it possible to use the same secret for mTLS
+	if caCert, ok := kubeSecret.Data[CACertKey]; ok && len(caCert) > 0 {
+		validationContext, err := makeValidationContext(kubeSecret)
+		if err != nil {
+			return nil, err
+		}
+		envoyValidationSecret := &tlsv3.Secret{
+			Name: fmt.Sprintf("%s/%s/%s", kubeSecret.Namespace, kubeSecret.Name, ValidationContextSuffix),
+			Type: &tlsv3.Secret_ValidationContext{
+				ValidationContext: validationContext,
+			},
+		}
+		if err := envoyValidationSecret.ValidateAll(); err != nil {
+			return nil, fmt.Errorf("failed to validate validation context secret: %w", err)
+		}
+		secrets = append(secrets, envoyValidationSecret)
+	}
+
+	return secrets, nil
+}
+
+func makeEnvoyValidationContextSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
+	if len(kubeSecret.Data[CACertKey]) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", kubeSecret.Namespace, kubeSecret.Name, CACertKey)
+	}
+
+	validationContext, err := makeValidationContext(kubeSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	envoySecret := &tlsv3.Secret{
+		Name: fmt.Sprintf("%s/%s", kubeSecret.Namespace, kubeSecret.Name),
+		Type: &tlsv3.Secret_ValidationContext{
+			ValidationContext: validationContext,
+		},
+	}
+	if err := envoySecret.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("failed to validate validation context secret: %w", err)
+	}
+
+	return []*tlsv3.Secret{envoySecret}, nil
+}
+
+func makeValidationContext(kubeSecret *v1.Secret) (*tlsv3.CertificateValidationContext, error) {
+	validationContext := &tlsv3.CertificateValidationContext{
+		TrustedCa: &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineBytes{
+				InlineBytes: kubeSecret.Data[CACertKey],
+			},
+		},
+	}
+	if crl, ok := kubeSecret.Data[CACRLKey]; ok && len(crl) > 0 {
+		validationContext.Crl = &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineBytes{
+				InlineBytes: crl,
+			},
+		}
+	}
+	if err := validationContext.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("failed to validate validation context: %w", err)
+	}
+	return validationContext, nil
+}
+
+func makeEnvoySessionTicketKeysSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
+	if len(kubeSecret.Data) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no session ticket keys", kubeSecret.Namespace, kubeSecret.Name)
+	}
+
+	// The first key is used for encryption, all keys are used for decryption.
+	// Keys are sorted by name, so that rotation is controlled by key naming.
+	keyNames := make([]string, 0, len(kubeSecret.Data))
+	for k := range kubeSecret.Data {
+		keyNames = append(keyNames, k)
+	}
+	sort.Strings(keyNames)
+
+	sessionTicketKeys := &tlsv3.TlsSessionTicketKeys{
+		Keys: make([]*corev3.DataSource, 0, len(keyNames)),
+	}
+	for _, k := range keyNames {
+		if len(kubeSecret.Data[k]) != SessionTicketKeyLength {
+			return nil, fmt.Errorf("session ticket key %s of secret %s/%s must be %d bytes, got %d",
+				k, kubeSecret.Namespace, kubeSecret.Name, SessionTicketKeyLength, len(kubeSecret.Data[k]))
+		}
+		sessionTicketKeys.Keys = append(sessionTicketKeys.Keys, &corev3.DataSource{
+			Specifier: &corev3.DataSource_InlineBytes{
+				InlineBytes: kubeSecret.Data[k],
+			},
+		})
+	}
+
+	envoySecret := &tlsv3.Secret{
+		Name: fmt.Sprintf("%s/%s", kubeSecret.Namespace, kubeSecret.Name),
+		Type: &tlsv3.Secret_SessionTicketKeys{
+			SessionTicketKeys: sessionTicketKeys,
+		},
+	}
+	if err := envoySecret.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("failed to validate session ticket keys secret: %w", err)
+	}
+
+	return []*tlsv3.Secret{envoySecret}, nil
+}
+
+func makeEnvoyOpaqueSecret(kubeSecret *v1.Secret) ([]*tlsv3.Secret, error) {
+	secrets := make([]*tlsv3.Secret, 0)
+
+	for k, v := range kubeSecret.Data {
+		envoySecret := &tlsv3.Secret{
+			Name: fmt.Sprintf("%s/%s/%s", kubeSecret.Namespace, kubeSecret.Name, k),
+			Type: &tlsv3.Secret_GenericSecret{
+				GenericSecret: &tlsv3.GenericSecret{
+					Secret: &corev3.DataSource{
+						Specifier: &corev3.DataSource_InlineBytes{
+							InlineBytes: v,
+						},
+					},
+				},
+			},
+		}
+
+		if err := envoySecret.ValidateAll(); err != nil {
+			return nil, fmt.Errorf("cannot validate Envoy Secret: %w", err)
+		}
+
+		secrets = append(secrets, envoySecret)
+	}
+
+	return secrets, nil
+}
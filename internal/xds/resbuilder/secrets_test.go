@@ -0,0 +1,237 @@
+package resbuilder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMakeEnvoySecretFromKubernetesSecret(t *testing.T) {
+	testCases := []struct {
+		name        string
+		secret      *v1.Secret
+		expected    map[string]string
+		expectedErr bool
+	}{
+		{
+			name: "tls",
+			secret: &v1.Secret{
+				Type: v1.SecretTypeTLS,
+				Data: map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key")},
+			},
+			expected: map[string]string{"ns/s": "tls_certificate"},
+		},
+		{
+			name: "tls with ca and ocsp staple",
+			secret: &v1.Secret{
+				Type: v1.SecretTypeTLS,
+				Data: map[string][]byte{
+					v1.TLSCertKey:       []byte("cert"),
+					v1.TLSPrivateKeyKey: []byte("key"),
+					CACertKey:           []byte("ca"),
+					OCSPStapleKey:       []byte("ocsp"),
+				},
+			},
+			expected: map[string]string{"ns/s": "tls_certificate", "ns/s/validation-context": "validation_context"},
+		},
+		{
+			name: "opaque",
+			secret: &v1.Secret{
+				Type: v1.SecretTypeOpaque,
+				Data: map[string][]byte{"a": []byte("1"), "b": []byte("2")},
+			},
+			expected: map[string]string{"ns/s/a": "generic_secret", "ns/s/b": "generic_secret"},
+		},
+		{
+			name: "basic auth",
+			secret: &v1.Secret{
+				Type: v1.SecretTypeBasicAuth,
+				Data: map[string][]byte{v1.BasicAuthUsernameKey: []byte("u"), v1.BasicAuthPasswordKey: []byte("p")},
+			},
+			expected: map[string]string{"ns/s/username": "generic_secret", "ns/s/password": "generic_secret"},
+		},
+		{
+			name: "validation context by annotation",
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationSecretKind: v1alpha1.SecretKindValidationContext}},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{CACertKey: []byte("ca")},
+			},
+			expected: map[string]string{"ns/s": "validation_context"},
+		},
+		{
+			name: "validation context without ca",
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationSecretKind: v1alpha1.SecretKindValidationContext}},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"foo": []byte("bar")},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "session ticket keys by annotation",
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationSecretKind: v1alpha1.SecretKindSessionTicketKeys}},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"1": make([]byte, 80), "2": make([]byte, 80)},
+			},
+			expected: map[string]string{"ns/s": "session_ticket_keys"},
+		},
+		{
+			name: "session ticket key of wrong length",
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationSecretKind: v1alpha1.SecretKindSessionTicketKeys}},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"1": make([]byte, 80), "2": make([]byte, 48)},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "unknown annotation kind",
+			secret: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1alpha1.AnnotationSecretKind: "unknown"}},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"a": []byte("1")},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "unsupported type",
+			secret: &v1.Secret{
+				Type: v1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{v1.DockerConfigJsonKey: []byte("{}")},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.secret.Namespace = "ns"
+			tc.secret.Name = "s"
+			secrets, err := makeEnvoySecretFromKubernetesSecret(tc.secret)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(secrets) != len(tc.expected) {
+				t.Fatalf("expected %d secrets, got %d", len(tc.expected), len(secrets))
+			}
+			for _, secret := range secrets {
+				typ, ok := tc.expected[secret.Name]
+				if !ok {
+					t.Fatalf("unexpected secret %s", secret.Name)
+				}
+				if got := secretTypeName(secret); got != typ {
+					t.Errorf("secret %s: expected type %s, got %s", secret.Name, typ, got)
+				}
+			}
+		})
+	}
+}
+
+func secretTypeName(secret *tlsv3.Secret) string {
+	switch secret.Type.(type) {
+	case *tlsv3.Secret_TlsCertificate:
+		return "tls_certificate"
+	case *tlsv3.Secret_ValidationContext:
+		return "validation_context"
+	case *tlsv3.Secret_SessionTicketKeys:
+		return "session_ticket_keys"
+	case *tlsv3.Secret_GenericSecret:
+		return "generic_secret"
+	}
+	return ""
+}
+
+func TestValidationContext(t *testing.T) {
+	s := store.New()
+	addSecret := func(name string, secretType v1.SecretType, kind string, data map[string][]byte) helpers.NamespacedName {
+		nn := helpers.NamespacedName{Namespace: "ns", Name: name}
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: nn.Namespace, Name: nn.Name}, Type: secretType, Data: data}
+		if kind != "" {
+			secret.Annotations = map[string]string{v1alpha1.AnnotationSecretKind: kind}
+		}
+		s.Secrets[nn] = secret
+		return nn
+	}
+	cert := addSecret("cert", v1.SecretTypeTLS, "", map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key")})
+	certWithCA := addSecret("cert-with-ca", v1.SecretTypeTLS, "", map[string][]byte{v1.TLSCertKey: []byte("cert"), v1.TLSPrivateKeyKey: []byte("key"), CACertKey: []byte("ca")})
+	ca := addSecret("ca", v1.SecretTypeOpaque, v1alpha1.SecretKindValidationContext, map[string][]byte{CACertKey: []byte("ca")})
+	tickets := addSecret("tickets", v1.SecretTypeOpaque, v1alpha1.SecretKindSessionTicketKeys, map[string][]byte{"1": make([]byte, 80)})
+
+	nameTests := []struct {
+		secret      helpers.NamespacedName
+		expected    string
+		expectedErr bool
+	}{
+		{secret: ca, expected: "ns/ca"},
+		{secret: certWithCA, expected: "ns/cert-with-ca/" + ValidationContextSuffix},
+		{secret: cert, expectedErr: true},
+		{secret: tickets, expectedErr: true},
+	}
+	for _, tt := range nameTests {
+		name, err := getValidationContextName(context.Background(), tt.secret, s)
+		if (err != nil) != tt.expectedErr || name != tt.expected {
+			t.Errorf("%s: expected %q (error %v), got %q, %v", tt.secret.String(), tt.expected, tt.expectedErr, name, err)
+		}
+	}
+
+	params := &FilterChainsParams{
+		VSName:                "ns/vs",
+		RouteConfigName:       "ns/vs",
+		StatPrefix:            "ns/vs",
+		IsTLS:                 true,
+		SecretNameToDomains:   map[helpers.NamespacedName][]string{cert: {"example.com"}},
+		ValidationContext:     &certWithCA,
+		ValidationContextName: "ns/cert-with-ca/" + ValidationContextSuffix,
+	}
+	fcs, err := buildFilterChains(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tlsContext tlsv3.DownstreamTlsContext
+	if err := fcs[0].GetTransportSocket().GetTypedConfig().UnmarshalTo(&tlsContext); err != nil {
+		t.Fatal(err)
+	}
+	if got := tlsContext.GetCommonTlsContext().GetValidationContextSdsSecretConfig().GetName(); got != params.ValidationContextName {
+		t.Errorf("expected validation context %s, got %q", params.ValidationContextName, got)
+	}
+	if !tlsContext.GetRequireClientCertificate().GetValue() {
+		t.Error("client certificates must be required")
+	}
+
+	secrets, usedSecrets, err := buildSecrets(context.Background(), nil, params, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string)
+	for _, secret := range secrets {
+		names[secret.Name] = secretTypeName(secret)
+	}
+	expected := map[string]string{"ns/cert": "tls_certificate", params.ValidationContextName: "validation_context"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected secrets %v, got %v", expected, names)
+	}
+	if len(usedSecrets) != 2 {
+		t.Errorf("expected 2 used secrets, got %v", usedSecrets)
+	}
+
+	// only tls certificates are served for domains
+	params.SecretNameToDomains = map[helpers.NamespacedName][]string{tickets: {"example.com"}}
+	if _, _, err := buildSecrets(context.Background(), nil, params, s); err == nil {
+		t.Error("expected error for session ticket keys used as tls certificate")
+	}
+}
@@ -0,0 +1,69 @@
+package resbuilder
+
+import (
+	"testing"
+
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestBuildVHDSRouteConfiguration(t *testing.T) {
+	routes := []*routev3.Route{{
+		Match:  &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+		Action: &routev3.Route_DirectResponse{DirectResponse: &routev3.DirectResponseAction{Status: 200}},
+	}}
+	rc := &routev3.RouteConfiguration{
+		Name: "default/vs",
+		VirtualHosts: []*routev3.VirtualHost{{
+			Name:    "default/vs",
+			Domains: []string{"example.com", "example.com:443", "*.example.com"},
+			Routes:  routes,
+		}},
+	}
+
+	rc, virtualHosts := buildVHDSRouteConfiguration(rc)
+	if rc.Name != "default/vs" || rc.GetVhds().GetConfigSource().GetAds() == nil {
+		t.Fatalf("route configuration must keep its name and use VHDS over ADS: %v", rc)
+	}
+	if len(rc.VirtualHosts) != 1 || len(rc.VirtualHosts[0].Domains) != 1 || rc.VirtualHosts[0].Domains[0] != "*.example.com" {
+		t.Errorf("wildcard domains must stay inline: %v", rc.VirtualHosts)
+	}
+	expected := []string{"default/vs/example.com", "default/vs/example.com:443"}
+	if len(virtualHosts) != len(expected) {
+		t.Fatalf("expected %d VHDS virtual hosts, got %d", len(expected), len(virtualHosts))
+	}
+	for i, name := range expected {
+		if virtualHosts[i].Name != name {
+			t.Errorf("expected virtual host %s, got %s", name, virtualHosts[i].Name)
+		}
+		if err := virtualHosts[i].ValidateAll(); err != nil {
+			t.Errorf("invalid virtual host %s: %v", name, err)
+		}
+	}
+	if err := rc.ValidateAll(); err != nil {
+		t.Errorf("invalid route configuration: %v", err)
+	}
+}
+
+func TestWithOnDemandHTTPFilter(t *testing.T) {
+	router := &hcmv3.HttpFilter{
+		Name: "envoy.filters.http.router",
+		ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: &anypb.Any{
+			TypeUrl: "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router",
+		}},
+	}
+	lua := &hcmv3.HttpFilter{Name: "envoy.filters.http.lua"}
+
+	filters, err := withOnDemandHTTPFilter([]*hcmv3.HttpFilter{lua, router})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(filters))
+	for _, f := range filters {
+		names = append(names, f.Name)
+	}
+	if len(names) != 3 || names[0] != lua.Name || names[1] != "envoy.filters.http.on_demand" || names[2] != router.Name {
+		t.Errorf("on-demand filter must be before the router filter, got %v", names)
+	}
+}
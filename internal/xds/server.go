@@ -1,12 +1,17 @@
 package xds
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -19,12 +24,55 @@ import (
 )
 
 const (
-	grpcKeepaliveTime        = 30 * time.Second
-	grpcKeepaliveTimeout     = 5 * time.Second
-	grpcKeepaliveMinTime     = 30 * time.Second
-	grpcMaxConcurrentStreams = 1000000
+	DefaultKeepaliveTime        = 30 * time.Second
+	DefaultKeepaliveTimeout     = 5 * time.Second
+	DefaultKeepaliveMinTime     = 30 * time.Second
+	DefaultMaxConcurrentStreams = 1000000
+	DefaultDrainTimeout         = 30 * time.Second
 )
 
+// ServerConfig configures listeners and gRPC options of the xDS server.
+type ServerConfig struct {
+	// Port of the TCP listener, the TCP listener is disabled if the port is 0
+	Port int
+	// UnixSocket is the path of the Unix domain socket listener, the listener is disabled if the path is empty
+	UnixSocket string
+
+	KeepaliveTime        time.Duration
+	KeepaliveTimeout     time.Duration
+	KeepaliveMinTime     time.Duration
+	MaxConcurrentStreams uint32
+
+	// DrainTimeout is the time given to open streams to finish on shutdown, after it the server is stopped forcibly
+	DrainTimeout time.Duration
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Port:                 9000,
+		KeepaliveTime:        DefaultKeepaliveTime,
+		KeepaliveTimeout:     DefaultKeepaliveTimeout,
+		KeepaliveMinTime:     DefaultKeepaliveMinTime,
+		MaxConcurrentStreams: DefaultMaxConcurrentStreams,
+		DrainTimeout:         DefaultDrainTimeout,
+	}
+}
+
+// Server is the xDS gRPC server, it implements manager.Runnable
+// and stops gracefully when the context is canceled.
+type Server struct {
+	cfg  ServerConfig
+	srv  server.Server
+	opts []grpc.ServerOption
+}
+
+var _ manager.Runnable = &Server{}
+
+// NewServer creates the xDS server. Additional options are used e.g. to enable TLS.
+func NewServer(srv server.Server, cfg ServerConfig, opts ...grpc.ServerOption) *Server {
+	return &Server{cfg: cfg, srv: srv, opts: opts}
+}
+
 func registerServer(grpcServer *grpc.Server, server server.Server) {
 	// register services
 	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
@@ -36,32 +84,95 @@ func registerServer(grpcServer *grpc.Server, server server.Server) {
 	runtimeservice.RegisterRuntimeDiscoveryServiceServer(grpcServer, server)
 }
 
-// RunServer starts an xDS server at the given port.
-func RunServer(srv server.Server, port int) error {
+// Start serves xDS until the context is canceled.
+func (s *Server) Start(ctx context.Context) error {
+	rlog := log.FromContext(ctx).WithName("xds-server")
+
 	// gRPC golang library sets a very small upper bound for the number gRPC/h2
 	// streams over a single TCP connection. If a proxy multiplexes requests over
 	// a single connection to the management server, then it might lead to
 	// availability problems. Keepalive timeouts based on connection_keepalive parameter https://www.envoyproxy.io/docs/envoy/latest/configuration/overview/examples#dynamic
 	var grpcOptions []grpc.ServerOption
 	grpcOptions = append(grpcOptions,
-		grpc.MaxConcurrentStreams(grpcMaxConcurrentStreams),
+		grpc.MaxConcurrentStreams(s.cfg.MaxConcurrentStreams),
 		grpc.KeepaliveParams(keepalive.ServerParameters{
-			Time:    grpcKeepaliveTime,
-			Timeout: grpcKeepaliveTimeout,
+			Time:    s.cfg.KeepaliveTime,
+			Timeout: s.cfg.KeepaliveTimeout,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
-			MinTime:             grpcKeepaliveMinTime,
+			MinTime:             s.cfg.KeepaliveMinTime,
 			PermitWithoutStream: true,
 		}),
 	)
+	grpcOptions = append(grpcOptions, s.opts...)
 	grpcServer := grpc.NewServer(grpcOptions...)
+	registerServer(grpcServer, s.srv)
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	listeners, err := s.listen()
 	if err != nil {
 		return err
 	}
+	if len(listeners) == 0 {
+		return errors.New("xDS server has no listeners, set port or unix socket")
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, lis := range listeners {
+		rlog.Info("Starting xDS server", "address", lis.Addr().String())
+		go func(lis net.Listener) {
+			errCh <- grpcServer.Serve(lis)
+		}(lis)
+	}
+
+	select {
+	case err := <-errCh:
+		grpcServer.Stop()
+		return fmt.Errorf("xDS server failed: %w", err)
+	case <-ctx.Done():
+	}
 
-	registerServer(grpcServer, srv)
+	rlog.Info("Stopping xDS server", "drainTimeout", s.cfg.DrainTimeout.String())
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(s.cfg.DrainTimeout):
+		rlog.Info("Drain timeout exceeded, closing open streams")
+		grpcServer.Stop()
+	}
+	return nil
+}
+
+func (s *Server) listen() ([]net.Listener, error) {
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, lis := range listeners {
+			_ = lis.Close()
+		}
+	}
 
-	return grpcServer.Serve(lis)
+	if s.cfg.Port > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.Port))
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, lis)
+	}
+	if s.cfg.UnixSocket != "" {
+		// remove the socket left after unclean shutdown
+		if err := os.Remove(s.cfg.UnixSocket); err != nil && !os.IsNotExist(err) {
+			closeAll()
+			return nil, fmt.Errorf("failed to remove unix socket %s: %w", s.cfg.UnixSocket, err)
+		}
+		lis, err := net.Listen("unix", s.cfg.UnixSocket)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, lis)
+	}
+	return listeners, nil
 }
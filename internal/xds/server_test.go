@@ -0,0 +1,59 @@
+package xds
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+)
+
+func TestServerUnixSocketGracefulStop(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.Port = 0
+	cfg.UnixSocket = filepath.Join(t.TempDir(), "xds.sock")
+	cfg.DrainTimeout = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := server.NewServer(ctx, cache.NewSnapshotCache(false, cache.IDHash{}, nil), nil)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewServer(srv, cfg).Start(ctx)
+	}()
+
+	var conn net.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("unix", cfg.UnixSocket); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("failed to connect to unix socket: %v", err)
+	}
+	_ = conn.Close()
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server is not stopped")
+	}
+}
+
+func TestServerWithoutListeners(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.Port = 0
+	if err := NewServer(nil, cfg).Start(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+}
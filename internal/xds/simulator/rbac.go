@@ -0,0 +1,439 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	rbacconfigv3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// RBACRequest describes the simulated request with connection attributes used by RBAC rules.
+type RBACRequest struct {
+	Request
+	// SourceIP is the address of the downstream client, taken from x-forwarded-for by Envoy if configured
+	SourceIP string `json:"source_ip,omitempty"`
+	// DirectRemoteIP is the address of the peer of the connection, SourceIP is used if empty
+	DirectRemoteIP  string `json:"direct_remote_ip,omitempty"`
+	DestinationIP   string `json:"destination_ip,omitempty"`
+	DestinationPort uint32 `json:"destination_port,omitempty"`
+	// Principal is the URI SAN, DNS SAN or subject of the client certificate, empty if the client isn't authenticated
+	Principal string `json:"principal,omitempty"`
+}
+
+// RBACResult is the decision of RBAC filters applied to the request.
+type RBACResult struct {
+	Listener    string `json:"listener"`
+	FilterChain string `json:"filter_chain"`
+	VirtualHost string `json:"virtual_host,omitempty"`
+	Route       string `json:"route,omitempty"`
+	// Allowed is false if any of the RBAC filters denies the request
+	Allowed bool           `json:"allowed"`
+	Filters []RBACDecision `json:"filters"`
+	// Warnings list rules which can't be evaluated and are treated as not matching,
+	// and filters without configs which are treated as denying
+	Warnings []string `json:"warnings,omitempty"`
+	Trace    []string `json:"trace"`
+}
+
+// RBACDecision is the decision of a single RBAC filter.
+type RBACDecision struct {
+	Filter string `json:"filter"`
+	// Source is listener, route_configuration, virtual_host or route depending on where the rules come from
+	Source string `json:"source"`
+	// Action is ALLOW, DENY or LOG, empty if the filter has no rules
+	Action  string `json:"action,omitempty"`
+	Allowed bool   `json:"allowed"`
+	// Disabled is set if the filter is disabled for the route
+	Disabled bool `json:"disabled,omitempty"`
+	// Unknown is set if the config of the filter delivered over ECDS is missing in the snapshot,
+	// the decision is unknown and the request is treated as denied
+	Unknown bool `json:"unknown,omitempty"`
+	// MatchedPolicy is the first matching policy in order of names, the one Envoy reports
+	MatchedPolicy   string   `json:"matched_policy,omitempty"`
+	MatchedPolicies []string `json:"matched_policies,omitempty"`
+	// ShadowMatchedPolicy is the first matching policy of shadow rules, they don't affect the decision
+	ShadowMatchedPolicy string `json:"shadow_matched_policy,omitempty"`
+}
+
+// rbacAttributes are request attributes in the form RBAC rules match them.
+type rbacAttributes struct {
+	sourceIP, directRemoteIP, destinationIP net.IP
+	destinationPort                         uint32
+	serverName, principal, path             string
+	headers                                 map[string]string
+	warnings                                map[string]struct{}
+}
+
+// EvaluateRBAC evaluates RBAC filters of the node with the snapshot for the request the way Envoy does it.
+// Filters are applied in order, the request is denied by the first filter which denies it.
+func EvaluateRBAC(snapshot cache.ResourceSnapshot, req RBACRequest) (*RBACResult, error) {
+	attrs, err := newRBACAttributes(&req)
+	if err != nil {
+		return nil, err
+	}
+	r, trace, err := resolve(snapshot, &req.Request, false)
+	if err != nil {
+		if errors.Is(err, ErrNoMatch) {
+			return &RBACResult{Trace: trace}, err
+		}
+		return nil, err
+	}
+	attrs.serverName = req.SNI
+	if attrs.serverName == "" {
+		attrs.serverName = hostWithoutPort(req.Host)
+	}
+	attrs.path = req.Path
+	attrs.headers = requestHeaders(req.Request)
+
+	result := &RBACResult{
+		Listener:    r.listener.Name,
+		FilterChain: r.filterChain.Name,
+		Route:       r.routeName(),
+		Allowed:     true,
+		Filters:     make([]RBACDecision, 0),
+		Trace:       trace,
+	}
+	if r.virtualHost != nil {
+		result.VirtualHost = r.virtualHost.Name
+	}
+
+	for _, f := range r.hcm.HttpFilters {
+		filterConfig, err := rbacFilterConfig(snapshot, f)
+		missing := errors.Is(err, errMissingRBACConfig)
+		if err != nil && !missing {
+			return nil, err
+		}
+		if filterConfig == nil && !missing {
+			continue
+		}
+		decision := RBACDecision{Filter: f.Name, Source: "listener", Disabled: f.Disabled}
+		perRoute, err := perRouteRBAC(r, f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if perRoute != nil {
+			decision.Source, decision.Disabled = perRoute.source, perRoute.disabled
+			if perRoute.rbac != nil {
+				// the per route config replaces the config of the filter, even if it is missing
+				filterConfig, missing = perRoute.rbac, false
+			}
+		}
+
+		decision.Allowed = true
+		switch {
+		case decision.Disabled:
+		case missing:
+			decision.Allowed, decision.Unknown = false, true
+			attrs.warnings[fmt.Sprintf("config of RBAC filter %s is missing in the snapshot, the request is treated as denied", f.Name)] = struct{}{}
+		default:
+			evaluateRBACFilter(filterConfig, attrs, &decision)
+		}
+		result.Filters = append(result.Filters, decision)
+		if !decision.Allowed {
+			result.Allowed = false
+			break
+		}
+	}
+
+	for warning := range attrs.warnings {
+		result.Warnings = append(result.Warnings, warning)
+	}
+	sort.Strings(result.Warnings)
+	return result, nil
+}
+
+func newRBACAttributes(req *RBACRequest) (*rbacAttributes, error) {
+	attrs := &rbacAttributes{
+		destinationPort: req.DestinationPort,
+		principal:       req.Principal,
+		warnings:        make(map[string]struct{}),
+	}
+	parse := func(name, value string) (net.IP, error) {
+		if value == "" {
+			return nil, nil
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid %s %s", name, value)
+		}
+		return ip, nil
+	}
+	var err error
+	if attrs.sourceIP, err = parse("source ip", req.SourceIP); err != nil {
+		return nil, err
+	}
+	if req.DirectRemoteIP == "" {
+		attrs.directRemoteIP = attrs.sourceIP
+	} else if attrs.directRemoteIP, err = parse("direct remote ip", req.DirectRemoteIP); err != nil {
+		return nil, err
+	}
+	if attrs.destinationIP, err = parse("destination ip", req.DestinationIP); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// errMissingRBACConfig is returned if the config of an RBAC filter delivered over ECDS isn't in the snapshot.
+var errMissingRBACConfig = errors.New("missing config of the RBAC filter")
+
+// rbacFilterConfig returns the config of the RBAC http filter, nil if the filter isn't RBAC.
+// Configs delivered over ECDS are taken from the snapshot, errMissingRBACConfig is returned
+// if the filter discovers RBAC configs and the snapshot has none.
+func rbacFilterConfig(snapshot cache.ResourceSnapshot, f *hcmv3.HttpFilter) (*rbacv3.RBAC, error) {
+	typedConfig := f.GetTypedConfig()
+	if cd := f.GetConfigDiscovery(); cd != nil {
+		res, ok := snapshot.GetResources(resourcev3.ExtensionConfigType)[f.Name]
+		if !ok {
+			if slices.Contains(cd.TypeUrls, rbacTypeURL) {
+				return nil, errMissingRBACConfig
+			}
+			return nil, nil
+		}
+		ec, ok := res.(*corev3.TypedExtensionConfig)
+		if !ok {
+			return nil, nil
+		}
+		typedConfig = ec.TypedConfig
+	}
+	cfg := &rbacv3.RBAC{}
+	if typedConfig == nil || !typedConfig.MessageIs(cfg) {
+		return nil, nil
+	}
+	if err := typedConfig.UnmarshalTo(cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rbac filter %s: %w", f.Name, err)
+	}
+	return cfg, nil
+}
+
+// rbacTypeURL is the type URL of configs of the RBAC http filter.
+var rbacTypeURL = "type.googleapis.com/" + string(proto.MessageName(&rbacv3.RBAC{}))
+
+// perRouteRBACConfig is the per route config of an RBAC filter.
+type perRouteRBACConfig struct {
+	source   string
+	rbac     *rbacv3.RBAC
+	disabled bool
+}
+
+// perRouteRBAC returns the most specific per route config of the filter, from the route, the virtual host
+// or the route configuration, nil if there is none. A config without rules disables the filter.
+func perRouteRBAC(r *resolution, filterName string) (*perRouteRBACConfig, error) {
+	type level struct {
+		source  string
+		configs map[string]*anypb.Any
+	}
+	levels := []level{{source: "route_configuration", configs: r.routeConfig.TypedPerFilterConfig}}
+	if r.virtualHost != nil {
+		levels = append(levels, level{source: "virtual_host", configs: r.virtualHost.TypedPerFilterConfig})
+	}
+	if r.route != nil {
+		levels = append(levels, level{source: "route", configs: r.route.TypedPerFilterConfig})
+	}
+	for i := len(levels) - 1; i >= 0; i-- {
+		cfg, ok := levels[i].configs[filterName]
+		if !ok {
+			continue
+		}
+		filterConfig := &routev3.FilterConfig{}
+		if cfg.MessageIs(filterConfig) {
+			if err := cfg.UnmarshalTo(filterConfig); err != nil {
+				return nil, err
+			}
+			if filterConfig.Disabled || filterConfig.Config == nil {
+				return &perRouteRBACConfig{source: levels[i].source, disabled: filterConfig.Disabled}, nil
+			}
+			cfg = filterConfig.Config
+		}
+		perRoute := &rbacv3.RBACPerRoute{}
+		if err := cfg.UnmarshalTo(perRoute); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal per route config of %s: %w", filterName, err)
+		}
+		return &perRouteRBACConfig{source: levels[i].source, rbac: perRoute.Rbac, disabled: perRoute.Rbac == nil}, nil
+	}
+	return nil, nil
+}
+
+func evaluateRBACFilter(cfg *rbacv3.RBAC, attrs *rbacAttributes, decision *RBACDecision) {
+	if cfg.Matcher != nil {
+		attrs.warn("matcher based rbac is not supported")
+	}
+	if cfg.ShadowRules != nil {
+		if policies := matchingPolicies(cfg.ShadowRules, attrs); len(policies) > 0 {
+			decision.ShadowMatchedPolicy = policies[0]
+		}
+	}
+	if cfg.Rules == nil {
+		// no enforcing rules
+		return
+	}
+	decision.Action = cfg.Rules.Action.String()
+	decision.MatchedPolicies = matchingPolicies(cfg.Rules, attrs)
+	if len(decision.MatchedPolicies) > 0 {
+		decision.MatchedPolicy = decision.MatchedPolicies[0]
+	}
+	switch cfg.Rules.Action {
+	case rbacconfigv3.RBAC_ALLOW:
+		decision.Allowed = len(decision.MatchedPolicies) > 0
+	case rbacconfigv3.RBAC_DENY:
+		decision.Allowed = len(decision.MatchedPolicies) == 0
+	}
+}
+
+// matchingPolicies returns names of policies matching the request, sorted by name.
+func matchingPolicies(rules *rbacconfigv3.RBAC, attrs *rbacAttributes) []string {
+	names := make([]string, 0, len(rules.Policies))
+	for name := range rules.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matched []string
+	for _, name := range names {
+		policy := rules.Policies[name]
+		if policy.Condition != nil || policy.CheckedCondition != nil {
+			attrs.warn(fmt.Sprintf("conditions of policy %s are not supported", name))
+			continue
+		}
+		if anyPermission(policy.Permissions, attrs) && anyPrincipal(policy.Principals, attrs) {
+			matched = append(matched, name)
+		}
+	}
+	return matched
+}
+
+func anyPermission(permissions []*rbacconfigv3.Permission, attrs *rbacAttributes) bool {
+	for _, p := range permissions {
+		if matchPermission(p, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPrincipal(principals []*rbacconfigv3.Principal, attrs *rbacAttributes) bool {
+	for _, p := range principals {
+		if matchPrincipal(p, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPermission(p *rbacconfigv3.Permission, attrs *rbacAttributes) bool {
+	switch rule := p.Rule.(type) {
+	case *rbacconfigv3.Permission_AndRules:
+		for _, r := range rule.AndRules.GetRules() {
+			if !matchPermission(r, attrs) {
+				return false
+			}
+		}
+		return true
+	case *rbacconfigv3.Permission_OrRules:
+		return anyPermission(rule.OrRules.GetRules(), attrs)
+	case *rbacconfigv3.Permission_Any:
+		return rule.Any
+	case *rbacconfigv3.Permission_Header:
+		return matchHeaders([]*routev3.HeaderMatcher{rule.Header}, attrs.headers)
+	case *rbacconfigv3.Permission_UrlPath:
+		return matchURLPath(rule.UrlPath.GetPath(), attrs)
+	case *rbacconfigv3.Permission_DestinationIp:
+		return matchCIDR(rule.DestinationIp, attrs.destinationIP)
+	case *rbacconfigv3.Permission_DestinationPort:
+		return attrs.destinationPort != 0 && attrs.destinationPort == rule.DestinationPort
+	case *rbacconfigv3.Permission_DestinationPortRange:
+		port := int32(attrs.destinationPort)
+		return port != 0 && port >= rule.DestinationPortRange.GetStart() && port < rule.DestinationPortRange.GetEnd()
+	case *rbacconfigv3.Permission_NotRule:
+		return !matchPermission(rule.NotRule, attrs)
+	case *rbacconfigv3.Permission_RequestedServerName:
+		return MatchString(rule.RequestedServerName, attrs.serverName)
+	default:
+		attrs.warn(fmt.Sprintf("permission %T is not supported", p.Rule))
+		return false
+	}
+}
+
+func matchPrincipal(p *rbacconfigv3.Principal, attrs *rbacAttributes) bool {
+	switch id := p.Identifier.(type) {
+	case *rbacconfigv3.Principal_AndIds:
+		for _, i := range id.AndIds.GetIds() {
+			if !matchPrincipal(i, attrs) {
+				return false
+			}
+		}
+		return true
+	case *rbacconfigv3.Principal_OrIds:
+		return anyPrincipal(id.OrIds.GetIds(), attrs)
+	case *rbacconfigv3.Principal_Any:
+		return id.Any
+	case *rbacconfigv3.Principal_Authenticated_:
+		if attrs.principal == "" {
+			return false
+		}
+		if id.Authenticated.GetPrincipalName() == nil {
+			return true
+		}
+		return MatchString(id.Authenticated.PrincipalName, attrs.principal)
+	case *rbacconfigv3.Principal_SourceIp:
+		return matchCIDR(id.SourceIp, attrs.directRemoteIP)
+	case *rbacconfigv3.Principal_DirectRemoteIp:
+		return matchCIDR(id.DirectRemoteIp, attrs.directRemoteIP)
+	case *rbacconfigv3.Principal_RemoteIp:
+		return matchCIDR(id.RemoteIp, attrs.sourceIP)
+	case *rbacconfigv3.Principal_Header:
+		return matchHeaders([]*routev3.HeaderMatcher{id.Header}, attrs.headers)
+	case *rbacconfigv3.Principal_UrlPath:
+		return matchURLPath(id.UrlPath.GetPath(), attrs)
+	case *rbacconfigv3.Principal_NotId:
+		return !matchPrincipal(id.NotId, attrs)
+	default:
+		attrs.warn(fmt.Sprintf("principal %T is not supported", p.Identifier))
+		return false
+	}
+}
+
+// matchURLPath matches the path without the query string, as Envoy does it.
+func matchURLPath(m *matcherv3.StringMatcher, attrs *rbacAttributes) bool {
+	path := attrs.path
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return m != nil && MatchString(m, path)
+}
+
+func matchCIDR(cidr *corev3.CidrRange, ip net.IP) bool {
+	if cidr == nil || ip == nil {
+		return false
+	}
+	prefix := net.ParseIP(cidr.AddressPrefix)
+	if prefix == nil {
+		return false
+	}
+	bits := 32
+	if prefix.To4() == nil {
+		bits = 128
+	}
+	prefixLen := bits
+	if cidr.PrefixLen != nil {
+		prefixLen = int(cidr.PrefixLen.Value)
+	}
+	network := net.IPNet{IP: prefix.Mask(net.CIDRMask(prefixLen, bits)), Mask: net.CIDRMask(prefixLen, bits)}
+	return network.Contains(ip)
+}
+
+func (a *rbacAttributes) warn(warning string) {
+	a.warnings[warning] = struct{}{}
+}
@@ -0,0 +1,261 @@
+package simulator
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacconfigv3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbacv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func newRBACSnapshot(t *testing.T) *cache.Snapshot {
+	t.Helper()
+	anyPermission := &rbacconfigv3.Permission{Rule: &rbacconfigv3.Permission_Any{Any: true}}
+	allow := &rbacv3.RBAC{Rules: &rbacconfigv3.RBAC{
+		Action: rbacconfigv3.RBAC_ALLOW,
+		Policies: map[string]*rbacconfigv3.Policy{
+			"office": {
+				Permissions: []*rbacconfigv3.Permission{anyPermission},
+				Principals: []*rbacconfigv3.Principal{{Identifier: &rbacconfigv3.Principal_RemoteIp{
+					RemoteIp: &corev3.CidrRange{AddressPrefix: "10.0.0.0", PrefixLen: wrapperspb.UInt32(8)},
+				}}},
+			},
+			"admin": {
+				Permissions: []*rbacconfigv3.Permission{{Rule: &rbacconfigv3.Permission_UrlPath{UrlPath: &matcherv3.PathMatcher{
+					Rule: &matcherv3.PathMatcher_Path{Path: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Prefix{Prefix: "/admin"}}},
+				}}}},
+				Principals: []*rbacconfigv3.Principal{{Identifier: &rbacconfigv3.Principal_Authenticated_{
+					Authenticated: &rbacconfigv3.Principal_Authenticated{
+						PrincipalName: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "spiffe://admin"}},
+					},
+				}}},
+			},
+		},
+	}}
+	deny := &rbacv3.RBAC{Rules: &rbacconfigv3.RBAC{
+		Action: rbacconfigv3.RBAC_DENY,
+		Policies: map[string]*rbacconfigv3.Policy{
+			"blocked": {
+				Permissions: []*rbacconfigv3.Permission{{Rule: &rbacconfigv3.Permission_Header{Header: &routev3.HeaderMatcher{
+					Name:                 "x-blocked",
+					HeaderMatchSpecifier: &routev3.HeaderMatcher_PresentMatch{PresentMatch: true},
+				}}}},
+				Principals: []*rbacconfigv3.Principal{{Identifier: &rbacconfigv3.Principal_Any{Any: true}}},
+			},
+		},
+	}}
+
+	hcm := &hcmv3.HttpConnectionManager{
+		RouteSpecifier: &hcmv3.HttpConnectionManager_RouteConfig{RouteConfig: &routev3.RouteConfiguration{
+			Name: "inline",
+			VirtualHosts: []*routev3.VirtualHost{{
+				Name:    "vh",
+				Domains: []string{"example.com"},
+				Routes: []*routev3.Route{
+					{
+						Name:                 "public",
+						Match:                &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/public"}},
+						Action:               routeTo("public"),
+						TypedPerFilterConfig: map[string]*anypb.Any{"allow": mustAny(t, &rbacv3.RBACPerRoute{})},
+					},
+					{
+						Name:   "default",
+						Match:  &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+						Action: routeTo("web"),
+					},
+				},
+			}},
+		}},
+		HttpFilters: []*hcmv3.HttpFilter{
+			{Name: "allow", ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: mustAny(t, allow)}},
+			{Name: "deny", ConfigType: &hcmv3.HttpFilter_TypedConfig{TypedConfig: mustAny(t, deny)}},
+			{Name: "envoy.filters.http.router"},
+		},
+	}
+	listener := &listenerv3.Listener{
+		Name: "http",
+		FilterChains: []*listenerv3.FilterChain{{
+			Name: "any",
+			Filters: []*listenerv3.Filter{{
+				Name:       "envoy.filters.network.http_connection_manager",
+				ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: mustAny(t, hcm)},
+			}},
+		}},
+	}
+	snapshot, err := cache.NewSnapshot("1", map[resourcev3.Type][]types.Resource{resourcev3.ListenerType: {listener}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestEvaluateRBAC(t *testing.T) {
+	snapshot := newRBACSnapshot(t)
+
+	tests := []struct {
+		name          string
+		req           RBACRequest
+		allowed       bool
+		filters       int
+		matchedPolicy string
+	}{
+		{
+			name:          "remote ip",
+			req:           RBACRequest{Request: Request{Host: "example.com", Path: "/"}, SourceIP: "10.1.2.3"},
+			allowed:       true,
+			filters:       2,
+			matchedPolicy: "office",
+		},
+		{
+			name:    "remote ip mismatch",
+			req:     RBACRequest{Request: Request{Host: "example.com", Path: "/"}, SourceIP: "192.168.0.1"},
+			allowed: false,
+			filters: 1,
+		},
+		{
+			name:          "authenticated principal",
+			req:           RBACRequest{Request: Request{Host: "example.com", Path: "/admin/users"}, SourceIP: "192.168.0.1", Principal: "spiffe://admin"},
+			allowed:       true,
+			filters:       2,
+			matchedPolicy: "admin",
+		},
+		{
+			name:    "authenticated principal on other path",
+			req:     RBACRequest{Request: Request{Host: "example.com", Path: "/users"}, SourceIP: "192.168.0.1", Principal: "spiffe://admin"},
+			allowed: false,
+			filters: 1,
+		},
+		{
+			// Envoy matches the path as it is sent, without unescaping
+			name:    "escaped path",
+			req:     RBACRequest{Request: Request{Host: "example.com", Path: "/%61dmin/users"}, SourceIP: "192.168.0.1", Principal: "spiffe://admin"},
+			allowed: false,
+			filters: 1,
+		},
+		{
+			name:    "disabled for route",
+			req:     RBACRequest{Request: Request{Host: "example.com", Path: "/public"}, SourceIP: "192.168.0.1"},
+			allowed: true,
+			filters: 2,
+		},
+		{
+			name:          "denied by header",
+			req:           RBACRequest{Request: Request{Host: "example.com", Path: "/public", Headers: map[string]string{"X-Blocked": "1"}}},
+			allowed:       false,
+			filters:       2,
+			matchedPolicy: "blocked",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EvaluateRBAC(snapshot, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != tt.allowed {
+				t.Errorf("expected allowed %v, got %+v", tt.allowed, result.Filters)
+			}
+			if len(result.Filters) != tt.filters {
+				t.Fatalf("expected %d evaluated filters, got %+v", tt.filters, result.Filters)
+			}
+			var matchedPolicy string
+			for _, f := range result.Filters {
+				if f.MatchedPolicy != "" {
+					matchedPolicy = f.MatchedPolicy
+				}
+			}
+			if matchedPolicy != tt.matchedPolicy {
+				t.Errorf("expected matched policy %q, got %q", tt.matchedPolicy, matchedPolicy)
+			}
+		})
+	}
+}
+
+func TestEvaluateRBACMissingExtensionConfig(t *testing.T) {
+	hcm := &hcmv3.HttpConnectionManager{
+		RouteSpecifier: &hcmv3.HttpConnectionManager_RouteConfig{RouteConfig: &routev3.RouteConfiguration{
+			Name: "inline",
+			VirtualHosts: []*routev3.VirtualHost{{
+				Name:    "vh",
+				Domains: []string{"example.com"},
+				Routes: []*routev3.Route{
+					{
+						Name:   "open",
+						Match:  &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/open"}},
+						Action: routeTo("web"),
+						TypedPerFilterConfig: map[string]*anypb.Any{"ecds-rbac": mustAny(t, &rbacv3.RBACPerRoute{Rbac: &rbacv3.RBAC{Rules: &rbacconfigv3.RBAC{
+							Action: rbacconfigv3.RBAC_ALLOW,
+							Policies: map[string]*rbacconfigv3.Policy{
+								"any": {
+									Permissions: []*rbacconfigv3.Permission{{Rule: &rbacconfigv3.Permission_Any{Any: true}}},
+									Principals:  []*rbacconfigv3.Principal{{Identifier: &rbacconfigv3.Principal_Any{Any: true}}},
+								},
+							},
+						}}})},
+					},
+					{
+						Name:   "default",
+						Match:  &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+						Action: routeTo("web"),
+					},
+				},
+			}},
+		}},
+		HttpFilters: []*hcmv3.HttpFilter{
+			{Name: "ecds-rbac", ConfigType: &hcmv3.HttpFilter_ConfigDiscovery{ConfigDiscovery: &corev3.ExtensionConfigSource{
+				ConfigSource: &corev3.ConfigSource{ConfigSourceSpecifier: &corev3.ConfigSource_Ads{}},
+				TypeUrls:     []string{rbacTypeURL},
+			}}},
+			{Name: "envoy.filters.http.router"},
+		},
+	}
+	listener := &listenerv3.Listener{
+		Name: "http",
+		FilterChains: []*listenerv3.FilterChain{{
+			Name: "any",
+			Filters: []*listenerv3.Filter{{
+				Name:       "envoy.filters.network.http_connection_manager",
+				ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: mustAny(t, hcm)},
+			}},
+		}},
+	}
+	snapshot, err := cache.NewSnapshot("1", map[resourcev3.Type][]types.Resource{resourcev3.ListenerType: {listener}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := EvaluateRBAC(snapshot, RBACRequest{Request: Request{Host: "example.com", Path: "/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Error("request must not be allowed without the config of the RBAC filter")
+	}
+	if len(result.Filters) != 1 || !result.Filters[0].Unknown {
+		t.Errorf("expected the unknown decision of the filter, got %+v", result.Filters)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("expected a warning, got %v", result.Warnings)
+	}
+
+	// the per route config replaces the missing config of the filter
+	result, err = EvaluateRBAC(snapshot, RBACRequest{Request: Request{Host: "example.com", Path: "/open"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || len(result.Filters) != 1 || result.Filters[0].Unknown {
+		t.Errorf("request must be allowed by the per route config, got %+v", result.Filters)
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", result.Warnings)
+	}
+}
@@ -0,0 +1,690 @@
+// Package simulator walks a node snapshot the way Envoy routes a request,
+// to explain which filter chain, virtual host, route and cluster handle it.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var ErrNoMatch = errors.New("no match")
+
+// Request describes the simulated request.
+type Request struct {
+	// Listener is the name of the listener, all listeners are tried if empty
+	Listener string `json:"listener,omitempty"`
+	Host     string `json:"host"`
+	// SNI is the server name of the TLS connection, the host without port is used if empty
+	SNI     string            `json:"sni,omitempty"`
+	Path    string            `json:"path"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Result describes how the request is handled.
+type Result struct {
+	Listener           string `json:"listener"`
+	FilterChain        string `json:"filter_chain"`
+	Filter             string `json:"filter"`
+	RouteConfiguration string `json:"route_configuration"`
+	VirtualHost        string `json:"virtual_host"`
+	// Route is the name of the matched route, or its index in the virtual host if it has no name
+	Route      string `json:"route"`
+	RouteIndex int    `json:"route_index"`
+	// Action is one of route, redirect, direct_response or filter_action
+	Action string `json:"action"`
+	// Cluster is the cluster of the route, empty for weighted clusters and other actions
+	Cluster          string            `json:"cluster,omitempty"`
+	WeightedClusters []WeightedCluster `json:"weighted_clusters,omitempty"`
+	// ClusterHeader is the header the cluster name is taken from
+	ClusterHeader  string          `json:"cluster_header,omitempty"`
+	Rewrites       *Rewrites       `json:"rewrites,omitempty"`
+	Redirect       *Redirect       `json:"redirect,omitempty"`
+	DirectResponse *DirectResponse `json:"direct_response,omitempty"`
+	HTTPFilters    []HTTPFilter    `json:"http_filters"`
+	// Trace explains the decisions of each step
+	Trace []string `json:"trace"`
+}
+
+type WeightedCluster struct {
+	Name   string `json:"name"`
+	Weight uint32 `json:"weight"`
+}
+
+type Rewrites struct {
+	Prefix     string `json:"prefix,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	Substitute string `json:"substitute,omitempty"`
+	Host       string `json:"host,omitempty"`
+	AutoHost   bool   `json:"auto_host,omitempty"`
+	HostHeader string `json:"host_header,omitempty"`
+	ResultPath string `json:"result_path,omitempty"`
+	ResultHost string `json:"result_host,omitempty"`
+}
+
+type Redirect struct {
+	Host         string `json:"host,omitempty"`
+	Path         string `json:"path,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	ResponseCode string `json:"response_code"`
+}
+
+type DirectResponse struct {
+	Status uint32 `json:"status"`
+}
+
+// HTTPFilter is an http filter of the matched filter chain.
+type HTTPFilter struct {
+	Name string `json:"name"`
+	// Enabled is false if the filter is disabled in the listener, the virtual host or the route
+	Enabled bool `json:"enabled"`
+	// PerRouteConfig is set if the virtual host or the route override the filter config
+	PerRouteConfig bool `json:"per_route_config,omitempty"`
+	// Discovered is set if the filter config is delivered over ECDS
+	Discovered bool `json:"discovered,omitempty"`
+}
+
+// Simulate returns how the request is handled by the node with the snapshot.
+func Simulate(snapshot cache.ResourceSnapshot, req Request) (*Result, error) {
+	r, trace, err := resolve(snapshot, &req, true)
+	if err != nil {
+		if errors.Is(err, ErrNoMatch) {
+			return &Result{Trace: trace}, err
+		}
+		return nil, err
+	}
+	result := &Result{
+		Listener:           r.listener.Name,
+		FilterChain:        r.filterChain.Name,
+		Filter:             r.filter,
+		RouteConfiguration: r.routeConfig.Name,
+		VirtualHost:        r.virtualHost.Name,
+		Route:              r.routeName(),
+		RouteIndex:         r.routeIndex,
+		Trace:              trace,
+	}
+	if err := applyAction(r.route, req, result); err != nil {
+		return nil, err
+	}
+	result.HTTPFilters = httpFilters(r.hcm.HttpFilters, r.virtualHost, r.route)
+	return result, nil
+}
+
+// resolution is the part of the snapshot handling a request.
+type resolution struct {
+	listener    *listenerv3.Listener
+	filterChain *listenerv3.FilterChain
+	filter      string
+	hcm         *hcmv3.HttpConnectionManager
+	routeConfig *routev3.RouteConfiguration
+	// virtualHost and route are nil if none matched and the route wasn't required
+	virtualHost *routev3.VirtualHost
+	route       *routev3.Route
+	routeIndex  int
+}
+
+func (r *resolution) routeName() string {
+	if r.route == nil {
+		return ""
+	}
+	if r.route.Name != "" {
+		return r.route.Name
+	}
+	return strconv.Itoa(r.routeIndex)
+}
+
+// resolve finds the listener, filter chain, virtual host and route handling the request, filling its defaults.
+// Listeners are tried in order of names, the first one handling the request is returned.
+func resolve(snapshot cache.ResourceSnapshot, req *Request, requireRoute bool) (*resolution, []string, error) {
+	if req.Host == "" {
+		return nil, nil, errors.New("host is required")
+	}
+	if req.Path == "" {
+		req.Path = "/"
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	sni := req.SNI
+	if sni == "" {
+		sni = hostWithoutPort(req.Host)
+	}
+
+	listeners := make([]*listenerv3.Listener, 0)
+	for name, res := range snapshot.GetResources(resourcev3.ListenerType) {
+		if req.Listener != "" && name != req.Listener {
+			continue
+		}
+		listeners = append(listeners, res.(*listenerv3.Listener))
+	}
+	if len(listeners) == 0 {
+		if req.Listener != "" {
+			return nil, nil, fmt.Errorf("listener %s not found", req.Listener)
+		}
+		return nil, nil, errors.New("node has no listeners")
+	}
+	sort.Slice(listeners, func(i, j int) bool { return listeners[i].Name < listeners[j].Name })
+
+	var trace []string
+	for _, listener := range listeners {
+		r, listenerTrace, err := resolveListener(snapshot, listener, *req, sni, requireRoute)
+		if err != nil {
+			trace = append(trace, fmt.Sprintf("listener %s: %v", listener.Name, err))
+			continue
+		}
+		return r, append(trace, listenerTrace...), nil
+	}
+	return nil, trace, ErrNoMatch
+}
+
+func resolveListener(snapshot cache.ResourceSnapshot, listener *listenerv3.Listener, req Request, sni string, requireRoute bool) (*resolution, []string, error) {
+	var trace []string
+	filterChain := FilterChainByServerName(listener, sni)
+	switch {
+	case filterChain != nil:
+		trace = append(trace, fmt.Sprintf("filter chain %s matched server name %s", filterChain.Name, sni))
+	default:
+		filterChain = filterChainWithoutServerNames(listener)
+		if filterChain == nil {
+			return nil, nil, fmt.Errorf("no filter chain for server name %s", sni)
+		}
+		trace = append(trace, fmt.Sprintf("filter chain %s has no server names and is used for %s", filterChain.Name, sni))
+	}
+
+	for _, filter := range filterChain.Filters {
+		hcm := resourcev3.GetHTTPConnectionManager(filter)
+		if hcm == nil {
+			continue
+		}
+		r := &resolution{listener: listener, filterChain: filterChain, filter: filter.Name, hcm: hcm}
+
+		routeConfig, err := routeConfiguration(snapshot, hcm)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.routeConfig = routeConfig
+
+		vh := matchVirtualHost(routeConfig.VirtualHosts, req.Host)
+		if vh == nil {
+			err := fmt.Errorf("no virtual host in route configuration %s for host %s", routeConfig.Name, req.Host)
+			if requireRoute {
+				return nil, nil, err
+			}
+			return r, append(trace, err.Error()), nil
+		}
+		r.virtualHost = vh
+		trace = append(trace, fmt.Sprintf("virtual host %s matched host %s", vh.Name, req.Host))
+
+		idx, err := matchRoute(vh.Routes, req)
+		if err != nil {
+			err = fmt.Errorf("virtual host %s: %w", vh.Name, err)
+			if requireRoute {
+				return nil, nil, err
+			}
+			return r, append(trace, err.Error()), nil
+		}
+		r.route, r.routeIndex = vh.Routes[idx], idx
+		trace = append(trace, fmt.Sprintf("route %s matched %s %s", r.routeName(), req.Method, req.Path))
+		return r, trace, nil
+	}
+	return nil, nil, fmt.Errorf("filter chain %s has no http connection manager", filterChain.Name)
+}
+
+// FilterChainByServerName returns the filter chain with the longest server name matching the domain.
+// Filter chains without a filter chain match are ignored.
+func FilterChainByServerName(listener *listenerv3.Listener, domain string) *listenerv3.FilterChain {
+	var lastFindServerName string
+
+	var findFilterChain *listenerv3.FilterChain
+
+	for _, filterChain := range listener.FilterChains {
+		if filterChain.FilterChainMatch == nil {
+			continue
+		}
+
+		serversNames := filterChain.FilterChainMatch.GetServerNames()
+
+		if len(serversNames) > 0 {
+			findServerName := findServerNameForDomain(serversNames, domain)
+			if findServerName == "" {
+				continue
+			}
+			if len(findServerName) >= len(lastFindServerName) {
+				findFilterChain = filterChain
+				lastFindServerName = findServerName
+			}
+		}
+	}
+
+	return findFilterChain
+}
+
+// findServerNameForDomain checks if server name exist in server names list
+// ServerName can be wildcard (https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/listener/v3/listener_components.proto#config-listener-v3-filterchainmatch)
+func findServerNameForDomain(serverNames []string, domain string) string {
+	var findServerName string
+
+	domainParts := strings.Split(domain, ".")
+
+	// Reverse domain parts
+	for i, j := 0, len(domainParts)-1; i < j; i, j = i+1, j-1 {
+		domainParts[i], domainParts[j] = domainParts[j], domainParts[i]
+	}
+
+L1:
+	for _, serverName := range serverNames {
+		serverNameParts := strings.Split(serverName, ".")
+
+		if len(serverNameParts) > len(domainParts) {
+			continue
+		}
+
+		// Reverse server name parts
+		for i, j := 0, len(serverNameParts)-1; i < j; i, j = i+1, j-1 {
+			serverNameParts[i], serverNameParts[j] = serverNameParts[j], serverNameParts[i]
+		}
+
+		// Check if server name parts equal domain parts
+		for i, serverNamePart := range serverNameParts {
+			if serverNameParts[i] == "*" {
+				if len(serverName) >= len(findServerName) {
+					findServerName = serverName
+				}
+			}
+			if serverNamePart != domainParts[i] {
+				continue L1
+			}
+
+			if i == len(serverNameParts)-1 {
+				if len(serverName) >= len(findServerName) {
+					findServerName = serverName
+				}
+			}
+		}
+
+	}
+
+	return findServerName
+}
+
+// filterChainWithoutServerNames returns the filter chain matching any server name.
+func filterChainWithoutServerNames(listener *listenerv3.Listener) *listenerv3.FilterChain {
+	for _, filterChain := range listener.FilterChains {
+		if len(filterChain.GetFilterChainMatch().GetServerNames()) == 0 {
+			return filterChain
+		}
+	}
+	return listener.DefaultFilterChain
+}
+
+// routeConfiguration returns the inline or RDS route configuration of the http connection manager.
+// Virtual hosts delivered over VHDS are added to the route configuration.
+func routeConfiguration(snapshot cache.ResourceSnapshot, hcm *hcmv3.HttpConnectionManager) (*routev3.RouteConfiguration, error) {
+	switch hcm.RouteSpecifier.(type) {
+	case *hcmv3.HttpConnectionManager_RouteConfig:
+		return hcm.GetRouteConfig(), nil
+	case *hcmv3.HttpConnectionManager_Rds:
+		name := hcm.GetRds().GetRouteConfigName()
+		res, ok := snapshot.GetResources(resourcev3.RouteType)[name]
+		if !ok {
+			return nil, fmt.Errorf("route configuration %s not found", name)
+		}
+		routeConfig := res.(*routev3.RouteConfiguration)
+		if routeConfig.Vhds == nil {
+			return routeConfig, nil
+		}
+		withVHDS := &routev3.RouteConfiguration{Name: routeConfig.Name, VirtualHosts: routeConfig.VirtualHosts}
+		names := make([]string, 0)
+		virtualHosts := snapshot.GetResources(resourcev3.VirtualHostType)
+		for vhName := range virtualHosts {
+			if strings.HasPrefix(vhName, routeConfig.Name+"/") {
+				names = append(names, vhName)
+			}
+		}
+		sort.Strings(names)
+		for _, vhName := range names {
+			withVHDS.VirtualHosts = append(withVHDS.VirtualHosts, virtualHosts[vhName].(*routev3.VirtualHost))
+		}
+		return withVHDS, nil
+	default:
+		return nil, errors.New("scoped routes are not supported")
+	}
+}
+
+// matchVirtualHost returns the virtual host for the host the way Envoy does it:
+// exact domains first, then the longest suffix wildcard, the longest prefix wildcard and "*".
+func matchVirtualHost(virtualHosts []*routev3.VirtualHost, host string) *routev3.VirtualHost {
+	host = strings.ToLower(host)
+	var (
+		suffixMatch, prefixMatch, anyMatch *routev3.VirtualHost
+		suffixLen, prefixLen               int
+	)
+	for _, vh := range virtualHosts {
+		for _, domain := range vh.Domains {
+			domain = strings.ToLower(domain)
+			switch {
+			case domain == "*":
+				if anyMatch == nil {
+					anyMatch = vh
+				}
+			case domain == host:
+				return vh
+			case strings.HasPrefix(domain, "*"):
+				if strings.HasSuffix(host, domain[1:]) && len(host) > len(domain)-1 && len(domain) > suffixLen {
+					suffixMatch, suffixLen = vh, len(domain)
+				}
+			case strings.HasSuffix(domain, "*"):
+				if strings.HasPrefix(host, domain[:len(domain)-1]) && len(host) > len(domain)-1 && len(domain) > prefixLen {
+					prefixMatch, prefixLen = vh, len(domain)
+				}
+			}
+		}
+	}
+	switch {
+	case suffixMatch != nil:
+		return suffixMatch
+	case prefixMatch != nil:
+		return prefixMatch
+	}
+	return anyMatch
+}
+
+// matchRoute returns the index of the first route matching the request.
+func matchRoute(routes []*routev3.Route, req Request) (int, error) {
+	path, query := req.Path, url.Values{}
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		query, _ = url.ParseQuery(path[i+1:])
+		path = path[:i]
+	}
+	headers := requestHeaders(req)
+
+	for i, route := range routes {
+		match := route.GetMatch()
+		if match == nil {
+			continue
+		}
+		ok, err := matchPath(match, path)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if !matchHeaders(match.Headers, headers) || !matchQueryParameters(match.QueryParameters, query) {
+			continue
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("no route for %s %s", req.Method, req.Path)
+}
+
+// requestHeaders returns lowercase headers of the request with pseudo-headers.
+func requestHeaders(req Request) map[string]string {
+	headers := make(map[string]string, len(req.Headers)+3)
+	for k, v := range req.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	headers[":method"] = req.Method
+	headers[":path"] = req.Path
+	headers[":authority"] = req.Host
+	return headers
+}
+
+func matchPath(match *routev3.RouteMatch, path string) (bool, error) {
+	caseSensitive := match.GetCaseSensitive() == nil || match.GetCaseSensitive().GetValue()
+	cmp := path
+	fold := func(s string) string {
+		if caseSensitive {
+			return s
+		}
+		return strings.ToLower(s)
+	}
+	cmp = fold(cmp)
+
+	switch spec := match.PathSpecifier.(type) {
+	case *routev3.RouteMatch_Prefix:
+		return strings.HasPrefix(cmp, fold(spec.Prefix)), nil
+	case *routev3.RouteMatch_Path:
+		return cmp == fold(spec.Path), nil
+	case *routev3.RouteMatch_PathSeparatedPrefix:
+		prefix := fold(spec.PathSeparatedPrefix)
+		return cmp == prefix || strings.HasPrefix(cmp, prefix+"/"), nil
+	case *routev3.RouteMatch_SafeRegex:
+		re, err := regexp.Compile("^(?:" + spec.SafeRegex.GetRegex() + ")$")
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(path), nil
+	default:
+		return false, nil
+	}
+}
+
+func matchHeaders(matchers []*routev3.HeaderMatcher, headers map[string]string) bool {
+	for _, m := range matchers {
+		value, present := headers[strings.ToLower(m.Name)]
+		if !present && m.TreatMissingHeaderAsEmpty {
+			present = true
+		}
+		var ok bool
+		switch spec := m.HeaderMatchSpecifier.(type) {
+		case *routev3.HeaderMatcher_PresentMatch:
+			ok = present == spec.PresentMatch
+		case *routev3.HeaderMatcher_ExactMatch:
+			ok = present && value == spec.ExactMatch
+		case *routev3.HeaderMatcher_PrefixMatch:
+			ok = present && strings.HasPrefix(value, spec.PrefixMatch)
+		case *routev3.HeaderMatcher_SuffixMatch:
+			ok = present && strings.HasSuffix(value, spec.SuffixMatch)
+		case *routev3.HeaderMatcher_ContainsMatch:
+			ok = present && strings.Contains(value, spec.ContainsMatch)
+		case *routev3.HeaderMatcher_SafeRegexMatch:
+			ok = present && matchRegex(spec.SafeRegexMatch.GetRegex(), value)
+		case *routev3.HeaderMatcher_RangeMatch:
+			n, err := strconv.ParseInt(value, 10, 64)
+			ok = present && err == nil && n >= spec.RangeMatch.Start && n < spec.RangeMatch.End
+		case *routev3.HeaderMatcher_StringMatch:
+			ok = present && MatchString(spec.StringMatch, value)
+		default:
+			ok = present
+		}
+		if ok == m.InvertMatch {
+			return false
+		}
+	}
+	return true
+}
+
+func matchQueryParameters(matchers []*routev3.QueryParameterMatcher, query url.Values) bool {
+	for _, m := range matchers {
+		values, present := query[m.Name]
+		switch spec := m.QueryParameterMatchSpecifier.(type) {
+		case *routev3.QueryParameterMatcher_PresentMatch:
+			if present != spec.PresentMatch {
+				return false
+			}
+		case *routev3.QueryParameterMatcher_StringMatch:
+			if !present || !MatchString(spec.StringMatch, values[0]) {
+				return false
+			}
+		default:
+			if !present {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MatchString returns true if the value matches the string matcher.
+func MatchString(m *matcherv3.StringMatcher, value string) bool {
+	fold := func(s string) string {
+		if m.GetIgnoreCase() {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch p := m.GetMatchPattern().(type) {
+	case *matcherv3.StringMatcher_Exact:
+		return fold(value) == fold(p.Exact)
+	case *matcherv3.StringMatcher_Prefix:
+		return strings.HasPrefix(fold(value), fold(p.Prefix))
+	case *matcherv3.StringMatcher_Suffix:
+		return strings.HasSuffix(fold(value), fold(p.Suffix))
+	case *matcherv3.StringMatcher_Contains:
+		return strings.Contains(fold(value), fold(p.Contains))
+	case *matcherv3.StringMatcher_SafeRegex:
+		return matchRegex(p.SafeRegex.GetRegex(), value)
+	}
+	return false
+}
+
+func matchRegex(expr, value string) bool {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	return err == nil && re.MatchString(value)
+}
+
+func applyAction(route *routev3.Route, req Request, result *Result) error {
+	switch action := route.Action.(type) {
+	case *routev3.Route_Route:
+		result.Action = "route"
+		ra := action.Route
+		switch cs := ra.ClusterSpecifier.(type) {
+		case *routev3.RouteAction_Cluster:
+			result.Cluster = cs.Cluster
+		case *routev3.RouteAction_ClusterHeader:
+			result.ClusterHeader = cs.ClusterHeader
+			// header names are case-insensitive, the request headers are lowercase
+			if v, ok := requestHeaders(req)[strings.ToLower(cs.ClusterHeader)]; ok {
+				result.Cluster = v
+			}
+		case *routev3.RouteAction_WeightedClusters:
+			for _, wc := range cs.WeightedClusters.GetClusters() {
+				result.WeightedClusters = append(result.WeightedClusters, WeightedCluster{Name: wc.Name, Weight: wc.GetWeight().GetValue()})
+			}
+		}
+		rw, err := rewrites(route, ra, req)
+		if err != nil {
+			return err
+		}
+		result.Rewrites = rw
+	case *routev3.Route_Redirect:
+		result.Action = "redirect"
+		r := action.Redirect
+		result.Redirect = &Redirect{
+			Host:         r.GetHostRedirect(),
+			Path:         r.GetPathRedirect(),
+			Scheme:       r.GetSchemeRedirect(),
+			ResponseCode: r.GetResponseCode().String(),
+		}
+		if r.GetHttpsRedirect() {
+			result.Redirect.Scheme = "https"
+		}
+	case *routev3.Route_DirectResponse:
+		result.Action = "direct_response"
+		result.DirectResponse = &DirectResponse{Status: action.DirectResponse.GetStatus()}
+	default:
+		result.Action = "filter_action"
+	}
+	return nil
+}
+
+func rewrites(route *routev3.Route, ra *routev3.RouteAction, req Request) (*Rewrites, error) {
+	rw := &Rewrites{
+		Prefix:   ra.GetPrefixRewrite(),
+		Host:     ra.GetHostRewriteLiteral(),
+		AutoHost: ra.GetAutoHostRewrite().GetValue(),
+	}
+	if h, ok := ra.HostRewriteSpecifier.(*routev3.RouteAction_HostRewriteHeader); ok {
+		rw.HostHeader = h.HostRewriteHeader
+	}
+	path := req.Path
+	if rw.Prefix != "" {
+		if prefix := route.GetMatch().GetPrefix(); prefix != "" && strings.HasPrefix(path, prefix) {
+			path = rw.Prefix + path[len(prefix):]
+		} else if prefix := route.GetMatch().GetPathSeparatedPrefix(); prefix != "" {
+			path = rw.Prefix + path[len(prefix):]
+		}
+	}
+	if re := ra.GetRegexRewrite(); re != nil {
+		rw.Regex = re.GetPattern().GetRegex()
+		rw.Substitute = re.GetSubstitution()
+		// Envoy uses RE2, some of its expressions aren't supported by Go
+		compiled, err := regexp.Compile(rw.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex rewrite %q: %w", rw.Regex, err)
+		}
+		substitution, err := convertSubstitution(rw.Substitute)
+		if err != nil {
+			return nil, err
+		}
+		path = compiled.ReplaceAllString(path, substitution)
+	}
+	if path != req.Path {
+		rw.ResultPath = path
+	}
+	if rw.Host != "" {
+		rw.ResultHost = rw.Host
+	} else if rw.HostHeader != "" {
+		rw.ResultHost = requestHeaders(req)[strings.ToLower(rw.HostHeader)]
+	}
+	if *rw == (Rewrites{}) {
+		return nil, nil
+	}
+	return rw, nil
+}
+
+// convertSubstitution converts \1 references of Envoy substitutions to ${1} of Go.
+func convertSubstitution(s string) (string, error) {
+	re, err := regexp.Compile(`\\(\d+)`)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(s, `$${$1}`), nil
+}
+
+// httpFilters returns http filters of the connection manager, enabled or disabled for the virtual host and the route.
+func httpFilters(filters []*hcmv3.HttpFilter, vh *routev3.VirtualHost, route *routev3.Route) []HTTPFilter {
+	result := make([]HTTPFilter, 0, len(filters))
+	for _, f := range filters {
+		hf := HTTPFilter{Name: f.Name, Enabled: !f.Disabled, Discovered: f.GetConfigDiscovery() != nil}
+		// the route overrides the virtual host
+		for _, perFilter := range []map[string]*anypb.Any{vh.TypedPerFilterConfig, route.TypedPerFilterConfig} {
+			cfg, ok := perFilter[f.Name]
+			if !ok {
+				continue
+			}
+			filterConfig := &routev3.FilterConfig{}
+			if cfg.MessageIs(filterConfig) && cfg.UnmarshalTo(filterConfig) == nil {
+				hf.Enabled = !filterConfig.Disabled
+				hf.PerRouteConfig = filterConfig.Config != nil
+				continue
+			}
+			hf.Enabled = true
+			hf.PerRouteConfig = true
+		}
+		result = append(result, hf)
+	}
+	return result
+}
+
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
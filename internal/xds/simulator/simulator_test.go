@@ -0,0 +1,314 @@
+package simulator
+
+import (
+	"errors"
+	"testing"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func mustAny(t *testing.T, msg proto.Message) *anypb.Any {
+	t.Helper()
+	a, err := anypb.New(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func hcmFilterChain(t *testing.T, name, routeConfig string, serverNames ...string) *listenerv3.FilterChain {
+	t.Helper()
+	hcm := &hcmv3.HttpConnectionManager{
+		RouteSpecifier: &hcmv3.HttpConnectionManager_Rds{Rds: &hcmv3.Rds{RouteConfigName: routeConfig}},
+		HttpFilters: []*hcmv3.HttpFilter{
+			{Name: "envoy.filters.http.rbac"},
+			{Name: "envoy.filters.http.router"},
+		},
+	}
+	fc := &listenerv3.FilterChain{
+		Name: name,
+		Filters: []*listenerv3.Filter{{
+			Name:       "envoy.filters.network.http_connection_manager",
+			ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: mustAny(t, hcm)},
+		}},
+	}
+	if len(serverNames) > 0 {
+		fc.FilterChainMatch = &listenerv3.FilterChainMatch{ServerNames: serverNames}
+	}
+	return fc
+}
+
+func routeTo(cluster string) *routev3.Route_Route {
+	return &routev3.Route_Route{Route: &routev3.RouteAction{
+		ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: cluster},
+	}}
+}
+
+func newTestSnapshot(t *testing.T) *cache.Snapshot {
+	t.Helper()
+	listener := &listenerv3.Listener{
+		Name: "default/https",
+		FilterChains: []*listenerv3.FilterChain{
+			hcmFilterChain(t, "exact", "exact", "example.com"),
+			hcmFilterChain(t, "wildcard", "wildcard", "*.example.org"),
+			hcmFilterChain(t, "fallback", "fallback"),
+		},
+	}
+	disableRBAC := mustAny(t, &routev3.FilterConfig{Disabled: true})
+	exact := &routev3.RouteConfiguration{
+		Name: "exact",
+		VirtualHosts: []*routev3.VirtualHost{{
+			Name:    "exact",
+			Domains: []string{"example.com", "example.com:443"},
+			Routes: []*routev3.Route{
+				{
+					Name: "api-post",
+					Match: &routev3.RouteMatch{
+						PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/api"},
+						Headers: []*routev3.HeaderMatcher{{
+							Name:                 ":method",
+							HeaderMatchSpecifier: &routev3.HeaderMatcher_ExactMatch{ExactMatch: "POST"},
+						}},
+					},
+					Action: routeTo("api-write"),
+				},
+				{
+					Name:  "api",
+					Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/api"}},
+					Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+						ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: "api"},
+						PrefixRewrite:    "/v2",
+						HostRewriteSpecifier: &routev3.RouteAction_HostRewriteLiteral{
+							HostRewriteLiteral: "api.internal",
+						},
+					}},
+				},
+				{
+					Match: &routev3.RouteMatch{
+						PathSpecifier: &routev3.RouteMatch_Path{Path: "/health"},
+						QueryParameters: []*routev3.QueryParameterMatcher{{
+							Name: "verbose",
+							QueryParameterMatchSpecifier: &routev3.QueryParameterMatcher_StringMatch{
+								StringMatch: &matcherv3.StringMatcher{MatchPattern: &matcherv3.StringMatcher_Exact{Exact: "1"}},
+							},
+						}},
+					},
+					Action: &routev3.Route_DirectResponse{DirectResponse: &routev3.DirectResponseAction{Status: 200}},
+				},
+				{
+					Name:  "dynamic",
+					Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/dynamic"}},
+					Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+						ClusterSpecifier: &routev3.RouteAction_ClusterHeader{ClusterHeader: "X-Cluster"},
+						RegexRewrite: &matcherv3.RegexMatchAndSubstitute{
+							Pattern:      &matcherv3.RegexMatcher{Regex: "^/dynamic/(.*)$"},
+							Substitution: "/\\1",
+						},
+					}},
+				},
+				{
+					Name:  "unsupported",
+					Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/unsupported"}},
+					Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+						ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: "web"},
+						// \C is supported by RE2, but not by Go
+						RegexRewrite: &matcherv3.RegexMatchAndSubstitute{
+							Pattern:      &matcherv3.RegexMatcher{Regex: "\\C"},
+							Substitution: "x",
+						},
+					}},
+				},
+				{
+					Name:                 "default",
+					Match:                &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+					Action:               routeTo("web"),
+					TypedPerFilterConfig: map[string]*anypb.Any{"envoy.filters.http.rbac": disableRBAC},
+				},
+			},
+		}},
+	}
+	wildcard := &routev3.RouteConfiguration{
+		Name: "wildcard",
+		VirtualHosts: []*routev3.VirtualHost{
+			{
+				Name:    "any",
+				Domains: []string{"*"},
+				Routes:  []*routev3.Route{{Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}}, Action: routeTo("any")}},
+			},
+			{
+				Name:    "suffix",
+				Domains: []string{"*.example.org"},
+				Routes: []*routev3.Route{{
+					Match: &routev3.RouteMatch{
+						PathSpecifier: &routev3.RouteMatch_SafeRegex{SafeRegex: &matcherv3.RegexMatcher{Regex: "/users/[0-9]+"}},
+						Headers: []*routev3.HeaderMatcher{{
+							Name:                 "x-canary",
+							HeaderMatchSpecifier: &routev3.HeaderMatcher_PresentMatch{PresentMatch: true},
+						}},
+					},
+					Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+						ClusterSpecifier: &routev3.RouteAction_WeightedClusters{WeightedClusters: &routev3.WeightedCluster{}},
+					}},
+				}},
+			},
+		},
+	}
+	fallback := &routev3.RouteConfiguration{
+		Name: "fallback",
+		VirtualHosts: []*routev3.VirtualHost{{
+			Name:    "redirect",
+			Domains: []string{"*"},
+			Routes: []*routev3.Route{{
+				Match:  &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+				Action: &routev3.Route_Redirect{Redirect: &routev3.RedirectAction{SchemeRewriteSpecifier: &routev3.RedirectAction_HttpsRedirect{HttpsRedirect: true}}},
+			}},
+		}},
+	}
+
+	snapshot, err := cache.NewSnapshot("1", map[resourcev3.Type][]types.Resource{
+		resourcev3.ListenerType: {listener},
+		resourcev3.RouteType:    {exact, wildcard, fallback},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestSimulate(t *testing.T) {
+	snapshot := newTestSnapshot(t)
+
+	tests := []struct {
+		name        string
+		req         Request
+		filterChain string
+		virtualHost string
+		route       string
+		action      string
+		cluster     string
+		resultPath  string
+		rbacEnabled bool
+		err         error
+	}{
+		{
+			name:        "header match",
+			req:         Request{Host: "example.com", Path: "/api/items", Method: "POST"},
+			filterChain: "exact", virtualHost: "exact", route: "api-post", action: "route", cluster: "api-write",
+			rbacEnabled: true,
+		},
+		{
+			name:        "prefix rewrite",
+			req:         Request{Host: "example.com:443", Path: "/api/items?x=1"},
+			filterChain: "exact", virtualHost: "exact", route: "api", action: "route", cluster: "api",
+			resultPath: "/v2/items?x=1", rbacEnabled: true,
+		},
+		{
+			name:        "cluster header and regex rewrite",
+			req:         Request{Host: "example.com", Path: "/dynamic/items", Headers: map[string]string{"x-cluster": "blue"}},
+			filterChain: "exact", virtualHost: "exact", route: "dynamic", action: "route", cluster: "blue",
+			resultPath: "/items", rbacEnabled: true,
+		},
+		{
+			name:        "query parameter",
+			req:         Request{Host: "example.com", Path: "/health?verbose=1"},
+			filterChain: "exact", virtualHost: "exact", route: "2", action: "direct_response",
+			rbacEnabled: true,
+		},
+		{
+			name:        "query parameter mismatch and disabled filter",
+			req:         Request{Host: "example.com", Path: "/health?verbose=0"},
+			filterChain: "exact", virtualHost: "exact", route: "default", action: "route", cluster: "web",
+		},
+		{
+			name:        "wildcard server name and suffix domain",
+			req:         Request{Host: "a.example.org", Path: "/users/42", Headers: map[string]string{"X-Canary": "1"}},
+			filterChain: "wildcard", virtualHost: "suffix", route: "0", action: "route",
+			rbacEnabled: true,
+		},
+		{
+			name:        "any domain",
+			req:         Request{Host: "example.org", Path: "/users/42", SNI: "a.example.org"},
+			filterChain: "wildcard", virtualHost: "any", route: "0", action: "route", cluster: "any",
+			rbacEnabled: true,
+		},
+		{
+			name:        "fallback filter chain",
+			req:         Request{Host: "other.net"},
+			filterChain: "fallback", virtualHost: "redirect", route: "0", action: "redirect",
+			rbacEnabled: true,
+		},
+		{
+			name: "no route",
+			req:  Request{Host: "a.example.org", Path: "/users/42"},
+			err:  ErrNoMatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Simulate(snapshot, tt.req)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.FilterChain != tt.filterChain || result.VirtualHost != tt.virtualHost || result.Route != tt.route {
+				t.Errorf("expected %s/%s/%s, got %s/%s/%s", tt.filterChain, tt.virtualHost, tt.route,
+					result.FilterChain, result.VirtualHost, result.Route)
+			}
+			if result.Action != tt.action || result.Cluster != tt.cluster {
+				t.Errorf("expected %s to %q, got %s to %q", tt.action, tt.cluster, result.Action, result.Cluster)
+			}
+			var resultPath string
+			if result.Rewrites != nil {
+				resultPath = result.Rewrites.ResultPath
+			}
+			if resultPath != tt.resultPath {
+				t.Errorf("expected rewritten path %q, got %q", tt.resultPath, resultPath)
+			}
+			if len(result.HTTPFilters) != 2 || result.HTTPFilters[0].Enabled != tt.rbacEnabled {
+				t.Errorf("expected rbac enabled %v, got %+v", tt.rbacEnabled, result.HTTPFilters)
+			}
+		})
+	}
+}
+
+func TestSimulateUnsupportedRegexRewrite(t *testing.T) {
+	_, err := Simulate(newTestSnapshot(t), Request{Host: "example.com", Path: "/unsupported"})
+	if err == nil {
+		t.Error("expected error for the regex unsupported by Go")
+	}
+}
+
+func TestFilterChainByServerName(t *testing.T) {
+	listener := &listenerv3.Listener{FilterChains: []*listenerv3.FilterChain{
+		{Name: "wildcard", FilterChainMatch: &listenerv3.FilterChainMatch{ServerNames: []string{"*.example.com"}}},
+		{Name: "exact", FilterChainMatch: &listenerv3.FilterChainMatch{ServerNames: []string{"api.example.com"}}},
+		{Name: "any"},
+	}}
+	tests := map[string]string{
+		"api.example.com": "exact",
+		"web.example.com": "wildcard",
+		"example.com":     "",
+	}
+	for domain, expected := range tests {
+		var name string
+		if fc := FilterChainByServerName(listener, domain); fc != nil {
+			name = fc.Name
+		}
+		if name != expected {
+			t.Errorf("%s: expected filter chain %q, got %q", domain, expected, name)
+		}
+	}
+}
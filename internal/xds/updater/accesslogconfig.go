@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertAccessLogConfig(ctx context.Context, alc *v1alpha1.AccessLogConfig) error {
+	ctx = withCause(ctx, "upsert", "AccessLogConfig", alc.Namespace, alc.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevALC := c.store.AccessLogs[helpers.NamespacedName{Namespace: alc.Namespace, Name: alc.Name}]
@@ -24,6 +25,7 @@ func (c *CacheUpdater) UpsertAccessLogConfig(ctx context.Context, alc *v1alpha1.
 }
 
 func (c *CacheUpdater) DeleteAccessLogConfig(ctx context.Context, alc types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "AccessLogConfig", alc.Namespace, alc.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.AccessLogs[helpers.NamespacedName{Namespace: alc.Namespace, Name: alc.Name}] == nil {
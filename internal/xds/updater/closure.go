@@ -0,0 +1,211 @@
+package updater
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcpproxyv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// references are names of resources referenced by other resources over ADS, by resource type.
+type references map[resource.Type]map[string]struct{}
+
+func (r references) add(typ resource.Type, name string) {
+	if name == "" {
+		return
+	}
+	if r[typ] == nil {
+		r[typ] = make(map[string]struct{})
+	}
+	r[typ][name] = struct{}{}
+}
+
+// referenceCollector collects references of resources. Results are cached by resource,
+// because the same resources are usually shared by snapshots of many nodes.
+type referenceCollector struct {
+	cache map[types.Resource]references
+	// specClusters are clusters produced by the controller by their names, references to other clusters,
+	// e.g. static clusters of the Envoy bootstrap, are not checked
+	specClusters map[string]*v1alpha1.Cluster
+}
+
+func newReferenceCollector(specClusters map[string]*v1alpha1.Cluster) *referenceCollector {
+	return &referenceCollector{cache: make(map[types.Resource]references), specClusters: specClusters}
+}
+
+func (c *referenceCollector) collect(res types.Resource) references {
+	if refs, ok := c.cache[res]; ok {
+		return refs
+	}
+	refs := make(references)
+	walkMessage(res.ProtoReflect(), func(msg proto.Message) {
+		switch m := msg.(type) {
+		case *hcmv3.Rds:
+			if isADS(m.GetConfigSource()) {
+				refs.add(resource.RouteType, m.GetRouteConfigName())
+			}
+		case *hcmv3.HttpFilter:
+			if cd := m.GetConfigDiscovery(); cd != nil && isADS(cd.GetConfigSource()) {
+				refs.add(resource.ExtensionConfigType, m.GetName())
+			}
+		case *tlsv3.SdsSecretConfig:
+			if isADS(m.GetSdsConfig()) {
+				refs.add(resource.SecretType, m.GetName())
+			}
+		case *routev3.RouteAction:
+			refs.add(resource.ClusterType, m.GetCluster())
+		case *routev3.WeightedCluster_ClusterWeight:
+			refs.add(resource.ClusterType, m.GetName())
+		case *tcpproxyv3.TcpProxy:
+			refs.add(resource.ClusterType, m.GetCluster())
+		case *tcpproxyv3.TcpProxy_WeightedCluster_ClusterWeight:
+			refs.add(resource.ClusterType, m.GetName())
+		}
+	})
+	c.cache[res] = refs
+	return refs
+}
+
+// missingReferences returns names of referenced resources absent in the node resources, by resource type.
+// Clusters which aren't produced by the controller are expected to be defined statically.
+func (c *referenceCollector) missingReferences(nodeResources map[resource.Type][]types.Resource) map[resource.Type][]string {
+	available := make(references)
+	for typ, resources := range nodeResources {
+		for _, res := range resources {
+			available.add(typ, getName(res))
+		}
+	}
+
+	missing := make(map[resource.Type][]string)
+	seen := make(references)
+	for _, resources := range nodeResources {
+		for _, res := range resources {
+			for typ, names := range c.collect(res) {
+				for name := range names {
+					if _, ok := available[typ][name]; ok {
+						continue
+					}
+					if _, ok := c.specClusters[name]; !ok && typ == resource.ClusterType {
+						continue
+					}
+					if _, ok := seen[typ][name]; ok {
+						continue
+					}
+					seen.add(typ, name)
+					missing[typ] = append(missing[typ], name)
+				}
+			}
+		}
+	}
+	for _, names := range missing {
+		sort.Strings(names)
+	}
+	return missing
+}
+
+// offendingVirtualServices returns virtual services which resources reference the missing resources.
+func (c *referenceCollector) offendingVirtualServices(
+	missing map[resource.Type][]string,
+	vsResources map[helpers.NamespacedName]*resbuilder.Resources,
+) []string {
+	var offending []string
+	for nn, res := range vsResources {
+		if c.referencesAny(missing, resourcesOf(res)...) {
+			offending = append(offending, nn.String())
+		}
+	}
+	sort.Strings(offending)
+	return offending
+}
+
+func (c *referenceCollector) referencesAny(missing map[resource.Type][]string, resources ...types.Resource) bool {
+	for _, res := range resources {
+		refs := c.collect(res)
+		for typ, names := range missing {
+			for _, name := range names {
+				if _, ok := refs[typ][name]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func resourcesOf(res *resbuilder.Resources) []types.Resource {
+	result := make([]types.Resource, 0, len(res.FilterChain)+len(res.Clusters)+len(res.VirtualHosts)+1)
+	for _, fc := range res.FilterChain {
+		result = append(result, fc)
+	}
+	if res.RouteConfig != nil {
+		result = append(result, res.RouteConfig)
+	}
+	for _, vh := range res.VirtualHosts {
+		result = append(result, vh)
+	}
+	for _, cl := range res.Clusters {
+		result = append(result, cl)
+	}
+	return result
+}
+
+func formatMissingReferences(missing map[resource.Type][]string) string {
+	typeURLs := make([]string, 0, len(missing))
+	for typ := range missing {
+		typeURLs = append(typeURLs, typ)
+	}
+	sort.Strings(typeURLs)
+	parts := make([]string, 0, len(typeURLs))
+	for _, typ := range typeURLs {
+		parts = append(parts, fmt.Sprintf("%s: %s", strings.TrimPrefix(typ, resource.APITypePrefix), strings.Join(missing[typ], ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func isADS(cs *corev3.ConfigSource) bool {
+	_, ok := cs.GetConfigSourceSpecifier().(*corev3.ConfigSource_Ads)
+	return ok
+}
+
+// walkMessage calls visit for the message and all nested messages, including the ones packed into Any.
+func walkMessage(m protoreflect.Message, visit func(proto.Message)) {
+	if a, ok := m.Interface().(*anypb.Any); ok {
+		inner, err := a.UnmarshalNew()
+		if err != nil {
+			return
+		}
+		walkMessage(inner.ProtoReflect(), visit)
+		return
+	}
+	visit(m.Interface())
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walkMessage(list.Get(i).Message(), visit)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				walkMessage(mv.Message(), visit)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			walkMessage(v.Message(), visit)
+		}
+		return true
+	})
+}
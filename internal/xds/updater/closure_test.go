@@ -0,0 +1,152 @@
+package updater
+
+import (
+	"reflect"
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMissingReferences(t *testing.T) {
+	adsConfigSource := &corev3.ConfigSource{ConfigSourceSpecifier: &corev3.ConfigSource_Ads{}}
+
+	hcm, _ := anypb.New(&hcmv3.HttpConnectionManager{
+		RouteSpecifier: &hcmv3.HttpConnectionManager_Rds{
+			Rds: &hcmv3.Rds{ConfigSource: adsConfigSource, RouteConfigName: "default/vs"},
+		},
+	})
+	tlsContext, _ := anypb.New(&tlsv3.DownstreamTlsContext{
+		CommonTlsContext: &tlsv3.CommonTlsContext{
+			TlsCertificateSdsSecretConfigs: []*tlsv3.SdsSecretConfig{{Name: "default/cert", SdsConfig: adsConfigSource}},
+		},
+	})
+	filterChain := &listenerv3.FilterChain{
+		Name:    "default/vs",
+		Filters: []*listenerv3.Filter{{Name: "hcm", ConfigType: &listenerv3.Filter_TypedConfig{TypedConfig: hcm}}},
+		TransportSocket: &corev3.TransportSocket{
+			Name:       "tls",
+			ConfigType: &corev3.TransportSocket_TypedConfig{TypedConfig: tlsContext},
+		},
+	}
+	listener := &listenerv3.Listener{Name: "default/https", FilterChains: []*listenerv3.FilterChain{filterChain}}
+	routeConfig := &routev3.RouteConfiguration{
+		Name: "default/vs",
+		VirtualHosts: []*routev3.VirtualHost{{
+			Name: "default/vs",
+			Routes: []*routev3.Route{{
+				Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+					ClusterSpecifier: &routev3.RouteAction_WeightedClusters{WeightedClusters: &routev3.WeightedCluster{
+						Clusters: []*routev3.WeightedCluster_ClusterWeight{{Name: "backend"}, {Name: "canary"}},
+					}},
+				}},
+			}},
+		}},
+	}
+	// the route configuration of a listener uses the cluster defined in the Envoy bootstrap
+	staticRouteConfig := &routev3.RouteConfiguration{
+		Name: "default/static",
+		VirtualHosts: []*routev3.VirtualHost{{
+			Name: "default/static",
+			Routes: []*routev3.Route{{
+				Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+					ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: "bootstrap"},
+				}},
+			}},
+		}},
+	}
+	backend := &clusterv3.Cluster{Name: "backend"}
+	specClusters := map[string]*v1alpha1.Cluster{"backend": {}, "canary": {}}
+	secret := &tlsv3.Secret{Name: "default/cert"}
+
+	vsResources := map[helpers.NamespacedName]*resbuilder.Resources{
+		{Namespace: "default", Name: "vs"}: {
+			FilterChain: []*listenerv3.FilterChain{filterChain},
+			RouteConfig: routeConfig,
+			Clusters:    []*clusterv3.Cluster{backend},
+		},
+		{Namespace: "default", Name: "other"}: {
+			Clusters: []*clusterv3.Cluster{backend},
+		},
+	}
+
+	testCases := []struct {
+		name              string
+		resources         map[resource.Type][]types.Resource
+		expectedMissing   map[resource.Type][]string
+		expectedOffending []string
+	}{
+		{
+			name: "consistent",
+			resources: map[resource.Type][]types.Resource{
+				resource.ListenerType: {listener},
+				resource.RouteType:    {routeConfig},
+				resource.ClusterType:  {backend, &clusterv3.Cluster{Name: "canary"}},
+				resource.SecretType:   {secret},
+			},
+			expectedMissing: map[resource.Type][]string{},
+		},
+		{
+			name: "missing route, secret and cluster",
+			resources: map[resource.Type][]types.Resource{
+				resource.ListenerType: {listener},
+				resource.ClusterType:  {backend},
+			},
+			expectedMissing: map[resource.Type][]string{
+				resource.RouteType:  {"default/vs"},
+				resource.SecretType: {"default/cert"},
+			},
+			expectedOffending: []string{"default/vs"},
+		},
+		{
+			name: "missing weighted cluster",
+			resources: map[resource.Type][]types.Resource{
+				resource.ListenerType: {listener},
+				resource.RouteType:    {routeConfig},
+				resource.ClusterType:  {backend},
+				resource.SecretType:   {secret},
+			},
+			expectedMissing: map[resource.Type][]string{
+				resource.ClusterType: {"canary"},
+			},
+			expectedOffending: []string{"default/vs"},
+		},
+		{
+			name: "static cluster",
+			resources: map[resource.Type][]types.Resource{
+				resource.ListenerType: {listener},
+				resource.RouteType:    {routeConfig, staticRouteConfig},
+				resource.ClusterType:  {backend, &clusterv3.Cluster{Name: "canary"}},
+				resource.SecretType:   {secret},
+			},
+			expectedMissing: map[resource.Type][]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newReferenceCollector(specClusters)
+			missing := c.missingReferences(tc.resources)
+			if !reflect.DeepEqual(missing, tc.expectedMissing) {
+				t.Fatalf("expected missing %v, got %v", tc.expectedMissing, missing)
+			}
+			if len(missing) == 0 {
+				return
+			}
+			offending := c.offendingVirtualServices(missing, vsResources)
+			if !reflect.DeepEqual(offending, tc.expectedOffending) {
+				t.Errorf("expected offending virtual services %v, got %v", tc.expectedOffending, offending)
+			}
+		})
+	}
+}
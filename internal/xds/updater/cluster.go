@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertCluster(ctx context.Context, cl *v1alpha1.Cluster) error {
+	ctx = withCause(ctx, "upsert", "Cluster", cl.Namespace, cl.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevCluster := c.store.Clusters[helpers.NamespacedName{Namespace: cl.Namespace, Name: cl.Name}]
@@ -26,6 +27,7 @@ func (c *CacheUpdater) UpsertCluster(ctx context.Context, cl *v1alpha1.Cluster)
 }
 
 func (c *CacheUpdater) DeleteCluster(ctx context.Context, cl types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "Cluster", cl.Namespace, cl.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.Clusters[helpers.NamespacedName{Namespace: cl.Namespace, Name: cl.Name}] == nil {
@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertHTTPFilter(ctx context.Context, httpFilter *v1alpha1.HttpFilter) error {
+	ctx = withCause(ctx, "upsert", "HttpFilter", httpFilter.Namespace, httpFilter.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevHTTPFilter := c.store.HTTPFilters[helpers.NamespacedName{Namespace: httpFilter.Namespace, Name: httpFilter.Name}]
@@ -24,6 +25,7 @@ func (c *CacheUpdater) UpsertHTTPFilter(ctx context.Context, httpFilter *v1alpha
 }
 
 func (c *CacheUpdater) DeleteHTTPFilter(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "HttpFilter", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.HTTPFilters[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
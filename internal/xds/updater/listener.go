@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertListener(ctx context.Context, listener *v1alpha1.Listener) error {
+	ctx = withCause(ctx, "upsert", "Listener", listener.Namespace, listener.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevListener := c.store.Listeners[helpers.NamespacedName{Namespace: listener.Namespace, Name: listener.Name}]
@@ -24,6 +25,7 @@ func (c *CacheUpdater) UpsertListener(ctx context.Context, listener *v1alpha1.Li
 }
 
 func (c *CacheUpdater) DeleteListener(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "Listener", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.Listeners[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
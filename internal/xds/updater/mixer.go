@@ -1,24 +1,33 @@
 package updater
 
 import (
+	"fmt"
+
 	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/kaasops/envoy-xds-controller/internal/helpers"
 	"github.com/kaasops/envoy-xds-controller/internal/store"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
 )
 
+// NodeProvenance contains origins of resources of a node by type URL and resource name.
+type NodeProvenance map[resource.Type]map[string][]resbuilder.ResourceOrigin
+
 type Mixer struct {
 	listeners map[helpers.NamespacedName]map[string][]*listenerv3.FilterChain
 	data      map[string]map[resource.Type][]types.Resource
 	nodeIDs   map[string]struct{}
+	// provenance contains origins of resources by node ID
+	provenance map[string]NodeProvenance
 }
 
 func NewMixer() *Mixer {
 	return &Mixer{
-		data:      make(map[string]map[resource.Type][]types.Resource),
-		listeners: make(map[helpers.NamespacedName]map[string][]*listenerv3.FilterChain),
-		nodeIDs:   make(map[string]struct{}),
+		data:       make(map[string]map[resource.Type][]types.Resource),
+		listeners:  make(map[helpers.NamespacedName]map[string][]*listenerv3.FilterChain),
+		nodeIDs:    make(map[string]struct{}),
+		provenance: make(map[string]NodeProvenance),
 	}
 }
 
@@ -41,11 +50,38 @@ func (m *Mixer) AddListenerParams(listenerNamespacedName helpers.NamespacedName,
 	m.nodeIDs[nodeID] = struct{}{}
 }
 
+// AddProvenance adds origins of resources built from a virtual service for the node.
+func (m *Mixer) AddProvenance(nodeID string, p *resbuilder.Provenance) {
+	if p == nil {
+		return
+	}
+	if m.provenance[nodeID] == nil {
+		m.provenance[nodeID] = make(NodeProvenance)
+	}
+	for typeURL, objects := range p.Objects {
+		if m.provenance[nodeID][typeURL] == nil {
+			m.provenance[nodeID][typeURL] = make(map[string][]resbuilder.ResourceOrigin)
+		}
+		for name := range objects {
+			origin, _ := p.Origin(typeURL, name)
+			m.provenance[nodeID][typeURL][name] = append(m.provenance[nodeID][typeURL][name], origin)
+		}
+	}
+}
+
+// Provenance returns origins of resources of the node.
+func (m *Mixer) Provenance(nodeID string) NodeProvenance {
+	return m.provenance[nodeID]
+}
+
 func (m *Mixer) Mix(store *store.Store) (map[string]map[resource.Type][]types.Resource, error) {
 	result := make(map[string]map[resource.Type][]types.Resource)
 
 	for listenerNamespacedName, data := range m.listeners {
 		listener := store.Listeners[listenerNamespacedName]
+		if listener == nil {
+			return nil, fmt.Errorf("listener %s not found", listenerNamespacedName.String())
+		}
 		for nodeID, fcs := range data {
 			lv3, err := listener.UnmarshalV3()
 			if err != nil {
@@ -67,6 +103,29 @@ func (m *Mixer) Mix(store *store.Store) (map[string]map[resource.Type][]types.Re
 		result[nodeID][resource.SecretType] = resources[resource.SecretType]
 		result[nodeID][resource.ClusterType] = resources[resource.ClusterType]
 		result[nodeID][resource.RouteType] = resources[resource.RouteType]
+		if virtualHosts := resources[resource.VirtualHostType]; len(virtualHosts) > 0 {
+			// virtual hosts of common virtual services are added to nodes which may already have them
+			result[nodeID][resource.VirtualHostType] = uniqueByName(virtualHosts)
+		}
+		if extensionConfigs := resources[resource.ExtensionConfigType]; len(extensionConfigs) > 0 {
+			// the same HttpFilter is usually shared by many virtual services
+			result[nodeID][resource.ExtensionConfigType] = uniqueByName(extensionConfigs)
+		}
 	}
 	return result, nil
 }
+
+// uniqueByName returns resources without duplicates of names, the first resource with the name is kept.
+func uniqueByName(resources []types.Resource) []types.Resource {
+	seen := make(map[string]struct{}, len(resources))
+	result := make([]types.Resource, 0, len(resources))
+	for _, r := range resources {
+		name := getName(r)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		result = append(result, r)
+	}
+	return result
+}
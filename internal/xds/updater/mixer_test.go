@@ -0,0 +1,35 @@
+package updater
+
+import (
+	"testing"
+
+	listenerv3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+)
+
+func TestMixUniqueVirtualHosts(t *testing.T) {
+	s, _ := newTestStore()
+	m := NewMixer()
+	listener := helpers.NamespacedName{Namespace: "default", Name: "http"}
+	m.AddListenerParams(listener, []*listenerv3.FilterChain{{Name: "default/vs"}}, "node1")
+	// the virtual host of a common virtual service is added to the node which already has it
+	for i := 0; i < 2; i++ {
+		m.Add("node1", resource.VirtualHostType, &routev3.VirtualHost{Name: "default/vs/example.com", Domains: []string{"example.com"}})
+	}
+	m.Add("node1", resource.VirtualHostType, &routev3.VirtualHost{Name: "default/other/example.org", Domains: []string{"example.org"}})
+
+	result, err := m.Mix(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if virtualHosts := result["node1"][resource.VirtualHostType]; len(virtualHosts) != 2 {
+		t.Errorf("expected 2 unique virtual hosts, got %d", len(virtualHosts))
+	}
+
+	m.AddListenerParams(helpers.NamespacedName{Namespace: "default", Name: "missing"}, nil, "node1")
+	if _, err := m.Mix(s); err == nil {
+		t.Error("expected error for the missing listener")
+	}
+}
@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertPolicy(ctx context.Context, policy *v1alpha1.Policy) error {
+	ctx = withCause(ctx, "upsert", "Policy", policy.Namespace, policy.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevPolicy := c.store.Policies[helpers.NamespacedName{Namespace: policy.Namespace, Name: policy.Name}]
@@ -24,6 +25,7 @@ func (c *CacheUpdater) UpsertPolicy(ctx context.Context, policy *v1alpha1.Policy
 }
 
 func (c *CacheUpdater) DeletePolicy(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "Policy", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.Policies[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertRoute(ctx context.Context, route *v1alpha1.Route) error {
+	ctx = withCause(ctx, "upsert", "Route", route.Namespace, route.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevRoute := c.store.Routes[helpers.NamespacedName{Namespace: route.Namespace, Name: route.Name}]
@@ -24,6 +25,7 @@ func (c *CacheUpdater) UpsertRoute(ctx context.Context, route *v1alpha1.Route) e
 }
 
 func (c *CacheUpdater) DeleteRoute(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "Route", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.Routes[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
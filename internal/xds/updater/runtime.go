@@ -0,0 +1,36 @@
+package updater
+
+import (
+	"context"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func (c *CacheUpdater) UpsertRuntime(ctx context.Context, runtime *v1alpha1.Runtime) error {
+	ctx = withCause(ctx, "upsert", "Runtime", runtime.Namespace, runtime.Name)
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	prevRuntime := c.store.Runtimes[helpers.NamespacedName{Namespace: runtime.Namespace, Name: runtime.Name}]
+	if prevRuntime == nil {
+		c.store.Runtimes[helpers.NamespacedName{Namespace: runtime.Namespace, Name: runtime.Name}] = runtime
+		return c.buildCache(ctx)
+	}
+	if prevRuntime.IsEqual(runtime) {
+		return nil
+	}
+	c.store.Runtimes[helpers.NamespacedName{Namespace: runtime.Namespace, Name: runtime.Name}] = runtime
+	return c.buildCache(ctx)
+}
+
+func (c *CacheUpdater) DeleteRuntime(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "Runtime", nn.Namespace, nn.Name)
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.store.Runtimes[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
+		return nil
+	}
+	delete(c.store.Runtimes, helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name})
+	return c.buildCache(ctx)
+}
@@ -2,45 +2,182 @@ package updater
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
+	"go.uber.org/multierr"
+	"golang.org/x/exp/maps"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/kaasops/envoy-xds-controller/internal/helpers"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 )
 
 func (c *CacheUpdater) UpsertSecret(ctx context.Context, secret *v1.Secret) error {
+	ctx = withCause(ctx, "upsert", "Secret", secret.Namespace, secret.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
-	prevSecret := c.store.Secrets[helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}]
+	nn := helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+	c.store.ForgetMissingSecret(nn)
+	prevSecret := c.store.Secrets[nn]
 	if prevSecret == nil {
-		c.store.Secrets[helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = secret
+		c.store.Secrets[nn] = secret
 		c.store.UpdateDomainSecretsMap()
+		c.keepReferences = equalSecretDomains(nil, secret)
 		return c.buildCache(ctx)
 	}
 	if checkSecretsEqual(prevSecret, secret) {
 		return nil
 	}
-	c.store.Secrets[helpers.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}] = secret
+	c.store.Secrets[nn] = secret
 	c.store.UpdateDomainSecretsMap()
+	if checkSecretsMetaEqual(prevSecret, secret) {
+		// only data is changed (e.g. certificate rotation), push new secrets to nodes which use them
+		pushed, err := c.pushSecret(ctx, nn, prevSecret, secret)
+		if pushed {
+			return err
+		}
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to push secret, rebuilding cache", "secret", nn.String())
+		}
+	}
+	c.keepReferences = equalSecretDomains(prevSecret, secret)
 	return c.buildCache(ctx)
 }
 
-func (c *CacheUpdater) DeleteSecret(ctx context.Context, nn types.NamespacedName) error {
+func (c *CacheUpdater) DeleteSecret(ctx context.Context, nn k8stypes.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "Secret", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
-	if c.store.Secrets[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
+	key := helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}
+	c.store.ForgetMissingSecret(key)
+	prevSecret := c.store.Secrets[key]
+	if prevSecret == nil {
 		return nil
 	}
-	delete(c.store.Secrets, helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name})
+	delete(c.store.Secrets, key)
 	c.store.UpdateDomainSecretsMap()
+	c.keepReferences = equalSecretDomains(prevSecret, nil)
 	return c.buildCache(ctx)
 }
 
+// equalSecretDomains returns true if both secrets have the same domains of auto-discovered certificates,
+// nil is a missing secret. Other changes of secrets don't affect references between objects.
+func equalSecretDomains(a, b *v1.Secret) bool {
+	domains := func(secret *v1.Secret) string {
+		if secret == nil {
+			return ""
+		}
+		return secret.Annotations[v1alpha1.AnnotationSecretDomains]
+	}
+	return domains(a) == domains(b)
+}
+
+// pushSecret replaces Envoy secrets built from the Kubernetes Secret in snapshots of nodes which use it,
+// only the version of secrets is changed, other resources are kept as is.
+// Returns false if the targeted update is impossible (e.g. the set of Envoy secrets is changed)
+// and the cache must be rebuilt.
+func (c *CacheUpdater) pushSecret(ctx context.Context, nn helpers.NamespacedName, prevSecret, secret *v1.Secret) (bool, error) {
+	if kubeSecret, err := c.store.SecretProvider.GetSecret(ctx, nn.Namespace, nn.Name); err != nil || kubeSecret != secret {
+		// the secret is shadowed by another provider or not served at all
+		return false, nil
+	}
+
+	prevEnvoySecrets, err := resbuilder.BuildSecret(prevSecret)
+	if err != nil {
+		return false, nil
+	}
+	envoySecrets, err := resbuilder.BuildSecret(secret)
+	if err != nil {
+		return false, err
+	}
+	if !equalSecretNames(prevEnvoySecrets, envoySecrets) {
+		return false, nil
+	}
+
+	var errs []error
+	for nodeID := range c.secretNodeIDs[nn] {
+		prevSnapshot, err := c.snapshotCache.GetLatestSnapshot(nodeID)
+		if err != nil {
+			continue
+		}
+		snapshot, hasChanges, err := updateSnapshotSecrets(prevSnapshot, envoySecrets)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to update secrets of node %s: %w", nodeID, err))
+			continue
+		}
+		if !hasChanges {
+			continue
+		}
+		if err := c.snapshotCache.SetSnapshot(ctx, nodeID, snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set snapshot of node %s: %w", nodeID, err))
+		}
+	}
+	return true, multierr.Combine(errs...)
+}
+
+// updateSnapshotSecrets returns a copy of the snapshot with replaced secrets and the incremented secrets version.
+// Secrets which are absent in the snapshot are skipped.
+func updateSnapshotSecrets(prevSnapshot cache.ResourceSnapshot, secrets []*tlsv3.Secret) (*cache.Snapshot, bool, error) {
+	prev, ok := prevSnapshot.(*cache.Snapshot)
+	if !ok || prev == nil {
+		return nil, false, errors.New("unsupported snapshot")
+	}
+
+	prevSecrets := prev.Resources[types.Secret]
+	items := maps.Clone(prevSecrets.Items)
+	hasChanges := false
+	for _, secret := range secrets {
+		item, ok := items[secret.Name]
+		if !ok || proto.Equal(item.Resource, secret) {
+			continue
+		}
+		items[secret.Name] = types.ResourceWithTTL{Resource: secret, TTL: item.TTL}
+		hasChanges = true
+	}
+	if !hasChanges {
+		return prev, false, nil
+	}
+
+	version, _ := strconv.Atoi(prevSecrets.Version)
+	snapshot := &cache.Snapshot{Resources: prev.Resources}
+	snapshot.Resources[types.Secret] = cache.Resources{Version: strconv.Itoa(version + 1), Items: items}
+	// versions of resources are used by delta xDS, they are rebuilt for the changed secrets
+	if prev.VersionMap != nil {
+		if err := snapshot.ConstructVersionMap(); err != nil {
+			return nil, false, err
+		}
+	}
+	return snapshot, true, nil
+}
+
+func equalSecretNames(a, b []*tlsv3.Secret) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]struct{}, len(a))
+	for _, secret := range a {
+		names[secret.Name] = struct{}{}
+	}
+	for _, secret := range b {
+		if _, ok := names[secret.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func checkSecretsEqual(a, b *v1.Secret) bool {
 	if a.Data == nil && b.Data == nil {
-		return true
+		return checkSecretsMetaEqual(a, b)
 	}
 	if a.Data == nil || b.Data == nil {
 		return false
@@ -56,10 +193,20 @@ func checkSecretsEqual(a, b *v1.Secret) bool {
 			return false
 		}
 	}
-	valA, okA := a.Annotations[v1alpha1.AnnotationSecretDomains]
-	valB, okB := b.Annotations[v1alpha1.AnnotationSecretDomains]
-	if okA != okB || valA != valB {
+	return checkSecretsMetaEqual(a, b)
+}
+
+// checkSecretsMetaEqual compares fields of Secrets, which affect resources other than Envoy secrets.
+func checkSecretsMetaEqual(a, b *v1.Secret) bool {
+	if a.Type != b.Type {
 		return false
 	}
+	for _, annotation := range []string{v1alpha1.AnnotationSecretDomains, v1alpha1.AnnotationSecretKind} {
+		valA, okA := a.Annotations[annotation]
+		valB, okB := b.Annotations[annotation]
+		if okA != okB || valA != valB {
+			return false
+		}
+	}
 	return true
 }
@@ -0,0 +1,77 @@
+package updater
+
+import (
+	"testing"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUpdateSnapshotSecrets(t *testing.T) {
+	prevSnapshot, err := cache.NewSnapshot("1", map[resource.Type][]types.Resource{
+		resource.ClusterType: {&clusterv3.Cluster{Name: "cluster"}},
+		resource.SecretType:  {genericSecret("ns/a", "1"), genericSecret("ns/b", "1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prevSnapshot.ConstructVersionMap(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, hasChanges, err := updateSnapshotSecrets(prevSnapshot, []*tlsv3.Secret{genericSecret("ns/a", "1")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hasChanges || snapshot.GetVersion(resource.SecretType) != "1" {
+		t.Errorf("expected no changes")
+	}
+
+	snapshot, hasChanges, err = updateSnapshotSecrets(prevSnapshot, []*tlsv3.Secret{genericSecret("ns/a", "2"), genericSecret("ns/c", "2")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !hasChanges {
+		t.Fatalf("expected changes")
+	}
+	if v := snapshot.GetVersion(resource.SecretType); v != "2" {
+		t.Errorf("expected secrets version 2, got %s", v)
+	}
+	if v := snapshot.GetVersion(resource.ClusterType); v != "1" {
+		t.Errorf("expected clusters version 1, got %s", v)
+	}
+	secrets := snapshot.GetResources(resource.SecretType)
+	if len(secrets) != 2 {
+		t.Fatalf("expected 2 secrets, got %d", len(secrets))
+	}
+	if !proto.Equal(secrets["ns/a"], genericSecret("ns/a", "2")) {
+		t.Errorf("expected updated secret ns/a")
+	}
+	if !proto.Equal(secrets["ns/b"], genericSecret("ns/b", "1")) {
+		t.Errorf("expected unchanged secret ns/b")
+	}
+	prevVersions, versions := prevSnapshot.GetVersionMap(resource.SecretType), snapshot.GetVersionMap(resource.SecretType)
+	if len(versions) != 2 || versions["ns/a"] == prevVersions["ns/a"] || versions["ns/b"] != prevVersions["ns/b"] {
+		t.Errorf("expected version map with the changed version of ns/a, got %v, previous %v", versions, prevVersions)
+	}
+	if snapshot.GetVersionMap(resource.ClusterType)["cluster"] == "" {
+		t.Errorf("expected versions of clusters")
+	}
+	if !proto.Equal(prevSnapshot.GetResources(resource.SecretType)["ns/a"], genericSecret("ns/a", "1")) {
+		t.Errorf("previous snapshot must not be changed")
+	}
+}
+
+func genericSecret(name, value string) *tlsv3.Secret {
+	return &tlsv3.Secret{
+		Name: name,
+		Type: &tlsv3.Secret_GenericSecret{GenericSecret: &tlsv3.GenericSecret{
+			Secret: &corev3.DataSource{Specifier: &corev3.DataSource_InlineString{InlineString: value}},
+		}},
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
@@ -19,34 +22,61 @@ import (
 	"go.uber.org/multierr"
 	"golang.org/x/exp/maps"
 	"google.golang.org/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type CacheUpdater struct {
-	mx            sync.RWMutex
-	snapshotCache *wrapped.SnapshotCache
-	store         *store.Store
-	usedSecrets   map[helpers.NamespacedName]helpers.NamespacedName
+	mx                sync.RWMutex
+	snapshotCache     *wrapped.SnapshotCache
+	store             *store.Store
+	usedSecrets       map[helpers.NamespacedName]helpers.NamespacedName
+	referencedSecrets map[helpers.NamespacedName]struct{}
+	// secretNodeIDs contains node IDs which snapshots include resources built from the Secret,
+	// it is used to push rotated secrets without full rebuild of the cache
+	secretNodeIDs map[helpers.NamespacedName]map[string]struct{}
+	// reader is used for lazy fetching of Secrets referenced by VirtualServices
+	reader client.Reader
+	// ready is set when the first snapshots are built
+	ready atomic.Bool
+	// provenance contains origins of resources of published snapshots by node ID
+	provenance map[string]NodeProvenance
+	// keepReferences is set by changes of secrets which don't affect references between objects,
+	// so the next build of the cache doesn't rebuild the reverse index
+	keepReferences bool
 }
 
 func NewCacheUpdater(wsc *wrapped.SnapshotCache, store *store.Store) *CacheUpdater {
 	return &CacheUpdater{snapshotCache: wsc, usedSecrets: make(map[helpers.NamespacedName]helpers.NamespacedName), store: store}
 }
 
-func (c *CacheUpdater) Init(ctx context.Context, cl client.Client) error {
+func (c *CacheUpdater) Init(ctx context.Context, cl client.Reader) error {
 	c.mx.Lock()
 	defer c.mx.Unlock()
 
+	c.reader = cl
+	ctx = wrapped.WithCause(ctx, "init")
+
 	if err := c.store.Fill(ctx, cl); err != nil {
 		return fmt.Errorf("failed to fill store: %w", err)
 	}
 
-	return c.buildCache(ctx)
+	if err := c.buildCache(ctx); err != nil {
+		return err
+	}
+	c.ready.Store(true)
+	return nil
+}
+
+// IsReady returns true when the first snapshots are built.
+func (c *CacheUpdater) IsReady() bool {
+	return c.ready.Load()
 }
 
-func (c *CacheUpdater) UpdateCache(ctx context.Context, cl client.Client) error {
+func (c *CacheUpdater) UpdateCache(ctx context.Context, cl client.Reader) error {
 	c.mx.Lock()
 	defer c.mx.Unlock()
+	ctx = wrapped.WithCause(ctx, "update")
 
 	if err := c.store.Fill(ctx, cl); err != nil { // TODO: remove
 		return fmt.Errorf("failed to fill store: %w", err)
@@ -54,17 +84,46 @@ func (c *CacheUpdater) UpdateCache(ctx context.Context, cl client.Client) error
 	return c.buildCache(ctx)
 }
 
+// RebuildCache rebuilds snapshots from the current store, e.g. after secrets of an external provider are changed.
+func (c *CacheUpdater) RebuildCache(ctx context.Context) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	ctx = wrapped.WithCause(ctx, "rebuild")
+	return c.buildCache(ctx)
+}
+
 func (c *CacheUpdater) buildCache(ctx context.Context) error {
 	errs := make([]error, 0)
 
 	mixer := NewMixer()
 
+	// objects of the store are changed in place by upserts and deletes
+	if !c.keepReferences {
+		c.store.UpdateReferences()
+	}
+	c.keepReferences = false
+
+	referencedSecrets, err := c.store.SyncReferencedSecrets(ctx, c.reader)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	c.referencedSecrets = referencedSecrets
+
 	// ---------------------------------------------
 
 	usedSecrets := make(map[helpers.NamespacedName]helpers.NamespacedName)
+	secretNodeIDs := make(map[helpers.NamespacedName]map[string]struct{})
 
 	nodeIDsForCleanup := c.snapshotCache.GetNodeIDsAsMap()
 	var commonVirtualServices []*v1alpha1.VirtualService
+	// resources of virtual services by node ID, used to report virtual services breaking consistency of snapshots
+	nodeVSResources := make(map[string]map[helpers.NamespacedName]*resbuilder.Resources)
+	addNodeVSResources := func(nodeID string, vs *v1alpha1.VirtualService, vsRes *resbuilder.Resources) {
+		if nodeVSResources[nodeID] == nil {
+			nodeVSResources[nodeID] = make(map[helpers.NamespacedName]*resbuilder.Resources)
+		}
+		nodeVSResources[nodeID][helpers.NamespacedName{Namespace: vs.Namespace, Name: vs.Name}] = vsRes
+	}
 
 	for _, vs := range c.store.VirtualServices {
 		vsNodeIDs := vs.GetNodeIDs()
@@ -78,7 +137,7 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 			continue
 		}
 
-		vsRes, vsUsedSecrets, err := resbuilder.BuildResources(vs, c.store)
+		vsRes, vsUsedSecrets, err := resbuilder.BuildResources(ctx, vs, c.store)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -86,6 +145,7 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 
 		for _, secret := range vsUsedSecrets {
 			usedSecrets[secret] = helpers.NamespacedName{Name: vs.Name, Namespace: vs.Namespace}
+			addSecretNodeIDs(secretNodeIDs, secret, vsNodeIDs...)
 		}
 
 		for _, nodeID := range vsNodeIDs {
@@ -98,19 +158,28 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 			for _, secret := range vsRes.Secrets {
 				mixer.Add(nodeID, resource.SecretType, secret)
 			}
+			for _, ec := range vsRes.ExtensionConfigs {
+				mixer.Add(nodeID, resource.ExtensionConfigType, ec)
+			}
+			for _, vh := range vsRes.VirtualHosts {
+				mixer.Add(nodeID, resource.VirtualHostType, vh)
+			}
 			mixer.AddListenerParams(vsRes.Listener, vsRes.FilterChain, nodeID)
+			addNodeVSResources(nodeID, vs, vsRes)
+			mixer.AddProvenance(nodeID, vsRes.Provenance)
 		}
 	}
 
 	if len(commonVirtualServices) > 0 {
 		for _, vs := range commonVirtualServices {
-			vsRes, vsUsedSecrets, err := resbuilder.BuildResources(vs, c.store)
+			vsRes, vsUsedSecrets, err := resbuilder.BuildResources(ctx, vs, c.store)
 			if err != nil {
 				errs = append(errs, err)
 				continue
 			}
 			for _, secret := range vsUsedSecrets {
 				usedSecrets[secret] = helpers.NamespacedName{Name: vs.Name, Namespace: vs.Namespace}
+				addSecretNodeIDs(secretNodeIDs, secret, maps.Keys(mixer.nodeIDs)...)
 			}
 
 			for nodeID := range mixer.nodeIDs {
@@ -123,12 +192,21 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 				for _, secret := range vsRes.Secrets {
 					mixer.Add(nodeID, resource.SecretType, secret)
 				}
+				for _, ec := range vsRes.ExtensionConfigs {
+					mixer.Add(nodeID, resource.ExtensionConfigType, ec)
+				}
+				for _, vh := range vsRes.VirtualHosts {
+					mixer.Add(nodeID, resource.VirtualHostType, vh)
+				}
 				mixer.AddListenerParams(vsRes.Listener, vsRes.FilterChain, nodeID)
+				addNodeVSResources(nodeID, vs, vsRes)
+				mixer.AddProvenance(nodeID, vsRes.Provenance)
 			}
 		}
 	}
 
 	c.usedSecrets = usedSecrets
+	c.secretNodeIDs = secretNodeIDs
 
 	tmp, err := mixer.Mix(c.store)
 	if err != nil {
@@ -136,11 +214,33 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 		return multierr.Combine(errs...)
 	}
 
+	if err := c.addRuntimes(tmp); err != nil {
+		errs = append(errs, err)
+	}
+
+	refCollector := newReferenceCollector(c.store.SpecClusters)
+	provenance := make(map[string]NodeProvenance, len(tmp))
+
 	for nodeID, resMap := range tmp {
 		var snapshot *cache.Snapshot
 		var err error
 		var hasChanges bool
-		prevSnapshot, _ := c.snapshotCache.GetSnapshot(nodeID)
+		// the latest snapshot differs from the served one if the node is pinned
+		prevSnapshot, _ := c.snapshotCache.GetLatestSnapshot(nodeID)
+
+		// Envoy can't warm resources with dangling references, the previous snapshot of the node is kept
+		if missing := refCollector.missingReferences(resMap); len(missing) > 0 {
+			errs = append(errs, fmt.Errorf("snapshot of node %s is inconsistent and is not published, missing %s, virtual services: %s",
+				nodeID, formatMissingReferences(missing),
+				strings.Join(refCollector.offendingVirtualServices(missing, nodeVSResources[nodeID]), ", ")))
+			delete(nodeIDsForCleanup, nodeID)
+			// the previous snapshot is still served
+			if prev, ok := c.provenance[nodeID]; ok {
+				provenance[nodeID] = prev
+			}
+			continue
+		}
+
 		if prevSnapshot != nil {
 			snapshot, hasChanges, err = updateSnapshot(prevSnapshot, resMap)
 			if err != nil {
@@ -163,7 +263,9 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 			}
 		}
 		delete(nodeIDsForCleanup, nodeID)
+		provenance[nodeID] = mixer.Provenance(nodeID)
 	}
+	c.provenance = provenance
 
 	for nodeID := range nodeIDsForCleanup {
 		c.snapshotCache.ClearSnapshot(nodeID)
@@ -176,12 +278,76 @@ func (c *CacheUpdater) buildCache(ctx context.Context) error {
 	return nil
 }
 
+// withCause sets the change of the resource as the cause of snapshots in the snapshot history.
+func withCause(ctx context.Context, action, kind, namespace, name string) context.Context {
+	return wrapped.WithObjectCause(ctx, fmt.Sprintf("%s %s %s/%s", action, kind, namespace, name), namespace)
+}
+
 func (c *CacheUpdater) GetUsedSecrets() map[helpers.NamespacedName]helpers.NamespacedName {
 	c.mx.RLock()
 	defer c.mx.RUnlock()
 	return maps.Clone(c.usedSecrets)
 }
 
+// GetProvenance returns origins of resources of the node snapshot, false if the node is unknown.
+func (c *CacheUpdater) GetProvenance(nodeID string) (NodeProvenance, bool) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	p, ok := c.provenance[nodeID]
+	return p, ok
+}
+
+// GetReferences returns objects referencing the object, directly or through templates, Routes, HttpFilters
+// and Listeners, and node IDs with snapshots built from it.
+func (c *CacheUpdater) GetReferences(key store.ObjectKey) ([]store.Referrer, []string) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	referrers := c.store.GetReferrers(key)
+
+	virtualServices := make([]helpers.NamespacedName, 0, len(referrers)+1)
+	if key.Kind == store.KindVirtualService {
+		virtualServices = append(virtualServices, helpers.NamespacedName{Namespace: key.Namespace, Name: key.Name})
+	}
+	for _, r := range referrers {
+		if r.Kind == store.KindVirtualService {
+			virtualServices = append(virtualServices, helpers.NamespacedName{Namespace: r.Namespace, Name: r.Name})
+		}
+	}
+
+	nodeIDs := make(map[string]struct{})
+	for _, nn := range virtualServices {
+		vs := c.store.VirtualServices[nn]
+		if vs == nil {
+			continue
+		}
+		vsNodeIDs := vs.GetNodeIDs()
+		if isCommonVirtualService(vsNodeIDs) {
+			vsNodeIDs = c.snapshotCache.GetNodeIDs()
+		}
+		for _, nodeID := range vsNodeIDs {
+			nodeIDs[nodeID] = struct{}{}
+		}
+	}
+	result := maps.Keys(nodeIDs)
+	sort.Strings(result)
+	return referrers, result
+}
+
+// IsSecretReferenced returns true if the Secret is referenced by at least one VirtualService
+// and is loaded lazily, regardless of the secret selector.
+func (c *CacheUpdater) IsSecretReferenced(nn helpers.NamespacedName) bool {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	_, ok := c.referencedSecrets[nn]
+	return ok
+}
+
+// GetSecretSelector returns the selector used to pick Secrets for the store.
+func (c *CacheUpdater) GetSecretSelector() *store.SecretSelector {
+	return c.store.SecretSelector
+}
+
+// GetMarshaledStore returns objects of the store as JSON, values of Secrets are redacted.
 func (c *CacheUpdater) GetMarshaledStore() ([]byte, error) {
 	c.mx.RLock()
 	defer c.mx.RUnlock()
@@ -196,6 +362,7 @@ func (c *CacheUpdater) GetMarshaledStore() ([]byte, error) {
 	data["accessLogConfigs"] = make(map[string]any)
 	data["httpFilters"] = make(map[string]any)
 	data["policies"] = make(map[string]any)
+	data["runtimes"] = make(map[string]any)
 	data["domainToSecret"] = make(map[string]any)
 
 	for key, vs := range c.store.VirtualServices {
@@ -205,7 +372,7 @@ func (c *CacheUpdater) GetMarshaledStore() ([]byte, error) {
 		data["clusters"][key.String()] = cl
 	}
 	for key, secret := range c.store.Secrets {
-		data["secrets"][key.String()] = secret
+		data["secrets"][key.String()] = redactSecret(secret)
 	}
 	for key, route := range c.store.Routes {
 		data["routes"][key.String()] = route
@@ -225,8 +392,11 @@ func (c *CacheUpdater) GetMarshaledStore() ([]byte, error) {
 	for key, policy := range c.store.Policies {
 		data["policies"][key.String()] = policy
 	}
+	for key, runtime := range c.store.Runtimes {
+		data["runtimes"][key.String()] = runtime
+	}
 	for ds, s := range c.store.DomainToSecretMap {
-		data["domainToSecret"][ds] = s
+		data["domainToSecret"][ds] = redactSecret(&s)
 	}
 	for specCluster, cl := range c.store.SpecClusters {
 		data["specClusters"][specCluster] = cl
@@ -234,6 +404,20 @@ func (c *CacheUpdater) GetMarshaledStore() ([]byte, error) {
 	return json.MarshalIndent(data, "", "\t")
 }
 
+// redactSecret returns a copy of the Secret with redacted values, keys are kept.
+// The last applied configuration is removed as it contains values too.
+func redactSecret(secret *v1.Secret) *v1.Secret {
+	secret = secret.DeepCopy()
+	for key := range secret.Data {
+		secret.Data[key] = []byte(wrapped.RedactedValue)
+	}
+	for key := range secret.StringData {
+		secret.StringData[key] = wrapped.RedactedValue
+	}
+	delete(secret.Annotations, v1.LastAppliedConfigAnnotation)
+	return secret
+}
+
 func updateSnapshot(prevSnapshot cache.ResourceSnapshot, resources map[resource.Type][]types.Resource) (*cache.Snapshot, bool, error) {
 	if prevSnapshot == nil {
 		return nil, false, errors.New("snapshot is nil")
@@ -291,6 +475,48 @@ func getName(msg proto.Message) string {
 	return ""
 }
 
+// addRuntimes adds RTDS resources to resources of nodes.
+// Runtimes with node ID "*" are added to all nodes.
+func (c *CacheUpdater) addRuntimes(nodeResources map[string]map[resource.Type][]types.Resource) error {
+	var commonRuntimes []*v1alpha1.Runtime
+	nodeRuntimes := make(map[string][]*v1alpha1.Runtime)
+	for _, rt := range c.store.Runtimes {
+		nodeIDs := rt.GetNodeIDs()
+		if isCommonVirtualService(nodeIDs) {
+			commonRuntimes = append(commonRuntimes, rt)
+			continue
+		}
+		for _, nodeID := range nodeIDs {
+			nodeRuntimes[nodeID] = append(nodeRuntimes[nodeID], rt)
+			if _, ok := nodeResources[nodeID]; !ok {
+				nodeResources[nodeID] = make(map[resource.Type][]types.Resource)
+			}
+		}
+	}
+
+	var errs []error
+	for nodeID, resources := range nodeResources {
+		runtimes, err := resbuilder.BuildRuntimes(append(nodeRuntimes[nodeID], commonRuntimes...))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", nodeID, err))
+		}
+		resources[resource.RuntimeType] = make([]types.Resource, 0, len(runtimes))
+		for _, rt := range runtimes {
+			resources[resource.RuntimeType] = append(resources[resource.RuntimeType], rt)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+func addSecretNodeIDs(secretNodeIDs map[helpers.NamespacedName]map[string]struct{}, secret helpers.NamespacedName, nodeIDs ...string) {
+	if secretNodeIDs[secret] == nil {
+		secretNodeIDs[secret] = make(map[string]struct{}, len(nodeIDs))
+	}
+	for _, nodeID := range nodeIDs {
+		secretNodeIDs[secret][nodeID] = struct{}{}
+	}
+}
+
 func isCommonVirtualService(nodeIDs []string) bool {
 	return len(nodeIDs) == 1 && nodeIDs[0] == "*"
 }
@@ -0,0 +1,172 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
+	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	wrapped "github.com/kaasops/envoy-xds-controller/internal/xds/cache"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGetMarshaledStoreRedactsSecrets(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "cert",
+			Annotations: map[string]string{v1.LastAppliedConfigAnnotation: `{"data":{"tls.key":"c2VjcmV0LWtleQ=="}}`},
+		},
+		Data: map[string][]byte{"tls.key": []byte("secret-key")},
+	}
+	s := store.New()
+	s.Secrets[helpers.NamespacedName{Namespace: "default", Name: "cert"}] = secret
+	s.DomainToSecretMap = map[string]v1.Secret{"example.com": *secret}
+
+	data, err := NewCacheUpdater(wrapped.NewSnapshotCache(), s).GetMarshaledStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("c2VjcmV0LWtleQ==")) {
+		t.Errorf("secret data must be redacted: %s", data)
+	}
+	if !bytes.Contains(data, []byte(`"tls.key"`)) {
+		t.Errorf("secret keys must be kept: %s", data)
+	}
+	if string(secret.Data["tls.key"]) != "secret-key" {
+		t.Error("store secret must not be modified")
+	}
+}
+
+// newTestStore returns a store with the http Listener and the api Route used by test virtual services.
+func newTestStore() (*store.Store, *v1alpha1.Route) {
+	s := store.New()
+	s.Listeners[helpers.NamespacedName{Namespace: "default", Name: "http"}] = &v1alpha1.Listener{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "http"},
+		Spec:       &runtime.RawExtension{Raw: []byte(`{"name":"http","address":{"socket_address":{"address":"0.0.0.0","port_value":80}}}`)},
+	}
+	route := &v1alpha1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+		Spec:       []*runtime.RawExtension{{Raw: []byte(`{"match":{"prefix":"/api"},"direct_response":{"status":200}}`)}},
+	}
+	s.Routes[helpers.NamespacedName{Namespace: "default", Name: "api"}] = route
+	return s, route
+}
+
+// addTestVirtualService adds a virtual service of the http Listener with the api Route to the store.
+func addTestVirtualService(s *store.Store, name, nodeIDs, virtualHost string) *v1alpha1.VirtualService {
+	vs := &v1alpha1.VirtualService{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        name,
+		Annotations: map[string]string{v1alpha1.AnnotationKeyEnvoyKaaSopsIoNodeID: nodeIDs},
+	}}
+	vs.Spec.Listener = &v1alpha1.ResourceRef{Name: "http"}
+	vs.Spec.VirtualHost = &runtime.RawExtension{Raw: []byte(virtualHost)}
+	vs.Spec.AdditionalRoutes = []*v1alpha1.ResourceRef{{Name: "api"}}
+	vs.Spec.HTTPFilters = []*runtime.RawExtension{{Raw: []byte(`{"name":"envoy.filters.http.router","typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"}}`)}}
+	s.VirtualServices[helpers.NamespacedName{Namespace: vs.Namespace, Name: vs.Name}] = vs
+	return vs
+}
+
+func TestProvenanceOrigins(t *testing.T) {
+	s, _ := newTestStore()
+	addTestVirtualService(s, "web", "node1", `{"name":"web","domains":["example.com"],"routes":[{"match":{"prefix":"/"},"direct_response":{"status":200}}]}`)
+	addTestVirtualService(s, "common", "*", `{"name":"common","domains":["common.example.com"],"routes":[{"match":{"prefix":"/"},"direct_response":{"status":200}}]}`)
+	c := NewCacheUpdater(wrapped.NewSnapshotCache(), s)
+	if err := c.RebuildCache(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	provenance, ok := c.GetProvenance("node1")
+	if !ok {
+		t.Fatal("expected provenance of node1")
+	}
+	origins := make(map[string]int)
+	for _, resources := range provenance {
+		for _, resourceOrigins := range resources {
+			for _, origin := range resourceOrigins {
+				origins[origin.VirtualService.Name]++
+			}
+		}
+	}
+	if origins["web"] == 0 || origins["web"] != origins["common"] {
+		t.Errorf("expected the same number of origins of both virtual services, got %v", origins)
+	}
+	for typeURL, resources := range provenance {
+		for name, resourceOrigins := range resources {
+			seen := make(map[string]struct{})
+			for _, origin := range resourceOrigins {
+				if _, ok := seen[origin.VirtualService.Name]; ok {
+					t.Errorf("duplicate origin %s of %s %s", origin.VirtualService.Name, typeURL, name)
+				}
+				seen[origin.VirtualService.Name] = struct{}{}
+			}
+		}
+	}
+}
+
+func TestValidateDependentVirtualServices(t *testing.T) {
+	s, route := newTestStore()
+	addTestVirtualService(s, "web", "node1", `{"name":"web","domains":["example.com"],"routes":[{"match":{"prefix":"/"},"direct_response":{"status":200}}]}`)
+	// the virtual service is broken regardless of the route
+	addTestVirtualService(s, "broken", "node1", `{"name":"broken","domains":["broken.example.com"],"routes":[{"match":{"prefix":"/"},"route":{"cluster":"missing"}}]}`)
+	s.UpdateReferences()
+	c := NewCacheUpdater(wrapped.NewSnapshotCache(), s)
+
+	if err := c.ValidateVirtualService(context.Background(), s.VirtualServices[helpers.NamespacedName{Namespace: "default", Name: "web"}]); err != nil {
+		t.Fatalf("virtual service must be valid: %v", err)
+	}
+
+	updated := route.DeepCopy()
+	updated.Spec[0].Raw = []byte(`{"match":{"prefix":"/api"},"direct_response":{"status":204}}`)
+	if err := c.ValidateDependentVirtualServices(context.Background(), updated); err != nil {
+		t.Errorf("valid update must be allowed: %v", err)
+	}
+
+	updated.Spec[0].Raw = []byte(`{"match":{"prefix":"/api"},"route":{"cluster":"backend"}}`)
+	err := c.ValidateDependentVirtualServices(context.Background(), updated)
+	if err == nil || !strings.Contains(err.Error(), "virtual service default/web") {
+		t.Errorf("expected failure of default/web, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "default/broken") {
+		t.Errorf("already broken virtual services must not be reported: %v", err)
+	}
+	if s.Routes[helpers.NamespacedName{Namespace: "default", Name: "api"}] != route {
+		t.Error("store must not be changed")
+	}
+}
+
+func TestSecretChangesKeepReferences(t *testing.T) {
+	s, _ := newTestStore()
+	addTestVirtualService(s, "web", "node1", `{"name":"web","domains":["example.com"],"routes":[{"match":{"prefix":"/"},"direct_response":{"status":200}}]}`)
+	c := NewCacheUpdater(wrapped.NewSnapshotCache(), s)
+	ctx := context.Background()
+	if err := c.RebuildCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	references := reflect.ValueOf(s.References).Pointer()
+
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"}, Type: v1.SecretTypeOpaque}
+	if err := c.UpsertSecret(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(s.References).Pointer() != references {
+		t.Error("references must not be rebuilt for secrets without domains")
+	}
+
+	// domains of auto-discovered certificates change references of virtual services
+	secret = secret.DeepCopy()
+	secret.Annotations = map[string]string{v1alpha1.AnnotationSecretDomains: "example.com"}
+	if err := c.UpsertSecret(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if reflect.ValueOf(s.References).Pointer() == references {
+		t.Error("references must be rebuilt if domains of the secret are changed")
+	}
+}
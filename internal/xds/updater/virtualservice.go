@@ -2,13 +2,23 @@ package updater
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/kaasops/envoy-xds-controller/api/v1alpha1"
 	"github.com/kaasops/envoy-xds-controller/internal/helpers"
+	"github.com/kaasops/envoy-xds-controller/internal/store"
+	"github.com/kaasops/envoy-xds-controller/internal/xds/resbuilder"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func (c *CacheUpdater) UpsertVirtualService(ctx context.Context, vs *v1alpha1.VirtualService) error {
+	ctx = withCause(ctx, "upsert", "VirtualService", vs.Namespace, vs.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevVS := c.store.VirtualServices[helpers.NamespacedName{Namespace: vs.Namespace, Name: vs.Name}]
@@ -24,6 +34,7 @@ func (c *CacheUpdater) UpsertVirtualService(ctx context.Context, vs *v1alpha1.Vi
 }
 
 func (c *CacheUpdater) DeleteVirtualService(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "VirtualService", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.VirtualServices[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {
@@ -32,3 +43,95 @@ func (c *CacheUpdater) DeleteVirtualService(ctx context.Context, nn types.Namesp
 	delete(c.store.VirtualServices, helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name})
 	return c.buildCache(ctx)
 }
+
+// ValidateVirtualService builds resources of the virtual service with a view of the store overlaid with it,
+// neither the store nor the cache is changed.
+func (c *CacheUpdater) ValidateVirtualService(ctx context.Context, vs *v1alpha1.VirtualService) error {
+	if len(vs.GetNodeIDs()) == 0 {
+		return errors.New("nodeIDs is required")
+	}
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	view := c.store.View()
+	if err := view.Put(vs); err != nil {
+		return err
+	}
+	if err := c.putReferencedSecrets(ctx, view, vs); err != nil {
+		return err
+	}
+	_, _, err := resbuilder.BuildResources(ctx, vs, view)
+	return err
+}
+
+// putReferencedSecrets puts Secrets referenced by the virtual service which are missing in the store into the view.
+// In the referenced mode a Secret is loaded into the store only after a stored virtual service references it.
+func (c *CacheUpdater) putReferencedSecrets(ctx context.Context, view *store.Store, vs *v1alpha1.VirtualService) error {
+	if !view.SecretSelector.Referenced || c.reader == nil {
+		return nil
+	}
+	for nn := range view.GetVirtualServiceSecrets(vs) {
+		if _, ok := view.Secrets[nn]; ok {
+			continue
+		}
+		var secret v1.Secret
+		if err := c.reader.Get(ctx, types.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get secret %s: %w", nn.String(), err)
+		}
+		if !store.IsSupportedSecret(&secret) {
+			continue
+		}
+		if err := view.Put(&secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateDependentVirtualServices builds virtual services which use the object with a view of the store
+// overlaid with it, e.g. with an updated Listener or Route. Virtual services which can't be built
+// with the current store aren't reported, so an update fixing some of them isn't rejected.
+func (c *CacheUpdater) ValidateDependentVirtualServices(ctx context.Context, obj client.Object) error {
+	kind, ok := store.ObjectKind(obj)
+	if !ok {
+		return fmt.Errorf("unsupported object type %T", obj)
+	}
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	view := c.store.View()
+	if err := view.Put(obj); err != nil {
+		return err
+	}
+
+	// referrers of the object don't depend on its spec, so the index of the store is used
+	dependents := make(map[helpers.NamespacedName]struct{})
+	for _, referrer := range c.store.GetReferrers(store.ObjectKey{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}) {
+		if referrer.Kind == store.KindVirtualService {
+			dependents[helpers.NamespacedName{Namespace: referrer.Namespace, Name: referrer.Name}] = struct{}{}
+		}
+	}
+	names := make([]helpers.NamespacedName, 0, len(dependents))
+	for nn := range dependents {
+		names = append(names, nn)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].String() < names[j].String()
+	})
+
+	var errs []error
+	for _, nn := range names {
+		vs := c.store.VirtualServices[nn]
+		if vs == nil || len(vs.GetNodeIDs()) == 0 {
+			continue
+		}
+		if _, _, err := resbuilder.BuildResources(ctx, vs, view); err != nil {
+			if _, _, prevErr := resbuilder.BuildResources(ctx, vs, c.store); prevErr != nil {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("virtual service %s: %w", nn.String(), err))
+		}
+	}
+	return multierr.Combine(errs...)
+}
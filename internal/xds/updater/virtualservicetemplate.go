@@ -9,6 +9,7 @@ import (
 )
 
 func (c *CacheUpdater) UpsertVirtualServiceTemplate(ctx context.Context, vst *v1alpha1.VirtualServiceTemplate) error {
+	ctx = withCause(ctx, "upsert", "VirtualServiceTemplate", vst.Namespace, vst.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	prevVST := c.store.VirtualServiceTemplates[helpers.NamespacedName{Namespace: vst.Namespace, Name: vst.Name}]
@@ -24,6 +25,7 @@ func (c *CacheUpdater) UpsertVirtualServiceTemplate(ctx context.Context, vst *v1
 }
 
 func (c *CacheUpdater) DeleteVirtualServiceTemplate(ctx context.Context, nn types.NamespacedName) error {
+	ctx = withCause(ctx, "delete", "VirtualServiceTemplate", nn.Namespace, nn.Name)
 	c.mx.Lock()
 	defer c.mx.Unlock()
 	if c.store.VirtualServiceTemplates[helpers.NamespacedName{Namespace: nn.Namespace, Name: nn.Name}] == nil {